@@ -0,0 +1,266 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/pablothedeveloper/formly"
+)
+
+// Sizes of the charts in terminal cells.
+const (
+	barWidth    = 40
+	lineHeight  = 10
+	maxBarRows  = 20
+	histogramAt = 12
+	heatmapDays = 52 * 7
+)
+
+// barPartials draws the last eighths of a bar.
+var barPartials = []string{"", "▏", "▎", "▍", "▌", "▋", "▊", "▉"}
+
+// heatmapShades goes from no submission to the busiest day.
+var heatmapShades = []string{"·", "░", "▒", "▓", "█"}
+
+type barRow struct {
+	name  string
+	count int
+}
+
+// barChart draws a horizontal bar per row, scaled to the largest count.
+func barChart(w io.Writer, rows []barRow) {
+	max, width := 0, 0
+	for _, row := range rows {
+		if row.count > max {
+			max = row.count
+		}
+		if n := utf8.RuneCountInString(row.name); n > width {
+			width = n
+		}
+	}
+	for _, row := range rows {
+		eighths := 0
+		if max != 0 {
+			eighths = int(math.Round(float64(row.count) / float64(max) * barWidth * 8))
+		}
+		bar := strings.Repeat("█", eighths/8) + barPartials[eighths%8]
+		padding := strings.Repeat(" ", width-utf8.RuneCountInString(row.name))
+		fmt.Fprintf(w, "%s%s │%s %v\n", row.name, padding, bar, row.count)
+	}
+}
+
+// valueBars counts the values of a label. Numbers are binned when they take
+// too many values, enum and bool labels show every choice and other labels
+// their most frequent values.
+func valueBars(label formly.Label, values []string) []barRow {
+	counts := map[string]int{}
+	for _, value := range values {
+		counts[value]++
+	}
+	rows := []barRow{}
+	switch label.Type {
+	case formly.IntLabel, formly.FloatLabel:
+		return numberBars(values)
+	case formly.EnumLabel:
+		for _, choice := range label.Choices {
+			rows = append(rows, barRow{choice, counts[choice]})
+		}
+		return rows
+	case formly.BoolLabel:
+		return []barRow{{"true", counts["true"]}, {"false", counts["false"]}}
+	}
+	for value, count := range counts {
+		rows = append(rows, barRow{tableText(value), count})
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].count != rows[j].count {
+			return rows[i].count > rows[j].count
+		}
+		return rows[i].name < rows[j].name
+	})
+	if len(rows) > maxBarRows {
+		rows = rows[:maxBarRows]
+	}
+	return rows
+}
+
+func numberBars(values []string) []barRow {
+	numbers := []float64{}
+	counts := map[float64]int{}
+	for _, value := range values {
+		number, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			continue
+		}
+		if counts[number] == 0 {
+			numbers = append(numbers, number)
+		}
+		counts[number]++
+	}
+	sort.Float64s(numbers)
+	rows := []barRow{}
+	if len(numbers) <= histogramAt {
+		for _, number := range numbers {
+			rows = append(rows, barRow{formatNumber(number), counts[number]})
+		}
+		return rows
+	}
+	// too many values for a bar each, they are binned into equal ranges
+	bins := histogramAt
+	min, max := numbers[0], numbers[len(numbers)-1]
+	size := (max - min) / float64(bins)
+	for i := 0; i < bins; i++ {
+		from := min + float64(i)*size
+		rows = append(rows, barRow{name: formatNumber(from) + "–" + formatNumber(from+size)})
+	}
+	for _, number := range numbers {
+		i := int((number - min) / size)
+		if i == bins {
+			i--
+		}
+		rows[i].count += counts[number]
+	}
+	return rows
+}
+
+func formatNumber(number float64) string {
+	return strconv.FormatFloat(math.Round(number*100)/100, 'f', -1, 64)
+}
+
+// linePoint is a point of a line chart, a nil value leaves a gap.
+type linePoint struct {
+	start time.Time
+	value *float64
+}
+
+// lineChart plots the points left to right, two cells apart, with the
+// lowest and highest value on the axis.
+func lineChart(w io.Writer, points []linePoint, period formly.StatsPeriod) {
+	min, max := math.Inf(1), math.Inf(-1)
+	for _, point := range points {
+		if point.value != nil {
+			min, max = math.Min(min, *point.value), math.Max(max, *point.value)
+		}
+	}
+	if math.IsInf(min, 1) {
+		fmt.Fprintln(w, "no values to chart")
+		return
+	}
+	span := max - min
+	if span == 0 {
+		span = 1
+	}
+	rows := make([]int, len(points))
+	grid := make([][]string, lineHeight)
+	for i := range grid {
+		grid[i] = make([]string, len(points)*2)
+		for j := range grid[i] {
+			grid[i][j] = " "
+		}
+	}
+	previous := -1
+	for i, point := range points {
+		rows[i] = -1
+		if point.value == nil {
+			previous = -1
+			continue
+		}
+		rows[i] = int(math.Round((*point.value - min) / span * (lineHeight - 1)))
+		grid[rows[i]][i*2] = "●"
+		if previous == -1 {
+			previous = i
+			continue
+		}
+		// the cell between two points joins them
+		from, to := rows[previous], rows[i]
+		if previous == i-1 && from == to {
+			grid[to][i*2-1] = "─"
+		}
+		if previous == i-1 && from != to {
+			if from > to {
+				from, to = to, from
+			}
+			for row := from + 1; row < to; row++ {
+				grid[row][i*2-1] = "│"
+			}
+		}
+		previous = i
+	}
+	labels := []string{formatNumber(max), formatNumber(min)}
+	width := utf8.RuneCountInString(labels[0])
+	if n := utf8.RuneCountInString(labels[1]); n > width {
+		width = n
+	}
+	for row := lineHeight - 1; row >= 0; row-- {
+		label := ""
+		switch row {
+		case lineHeight - 1:
+			label = labels[0]
+		case 0:
+			label = labels[1]
+		}
+		fmt.Fprintf(w, "%*s ┤%s\n", width, label, strings.TrimRight(strings.Join(grid[row], ""), " "))
+	}
+	fmt.Fprintf(w, "%*s └%s\n", width, "", strings.Repeat("─", len(points)*2))
+	first, last := points[0].start.Format(formly.DateLayout), points[len(points)-1].start.Format(formly.DateLayout)
+	axis := first
+	if gap := len(points)*2 - len(first) - len(last); len(points) > 1 && gap > 0 {
+		axis += strings.Repeat(" ", gap) + last
+	} else if len(points) > 1 {
+		axis += " – " + last
+	}
+	fmt.Fprintf(w, "%*s  %s\n", width, "", axis)
+	fmt.Fprintf(w, "%*s  one point per %s\n", width, "", period)
+}
+
+// heatmap draws a cell per day from since to until, a column per week and
+// a row per weekday, shaded by how many submissions were made that day.
+func heatmap(w io.Writer, counts map[time.Time]int, since, until time.Time) {
+	start := since.AddDate(0, 0, -(int(since.Weekday())+6)%7)
+	weeks := int(until.Sub(start).Hours()/24)/7 + 1
+	max := 0
+	for _, count := range counts {
+		if count > max {
+			max = count
+		}
+	}
+	// month names go above the week they start in, when there is room
+	months := []rune(strings.Repeat(" ", weeks+4))
+	for week := 0; week < weeks; week++ {
+		day := start.AddDate(0, 0, week*7)
+		if week == 0 || day.Month() != day.AddDate(0, 0, -7).Month() {
+			name := day.Format("Jan")
+			if week+len(name) <= len(months) && (week == 0 || months[week-1] == ' ') {
+				copy(months[week:], []rune(name))
+			}
+		}
+	}
+	fmt.Fprintf(w, "    %s\n", strings.TrimRight(string(months), " "))
+	for weekday := 0; weekday < 7; weekday++ {
+		name := ""
+		if weekday%2 == 0 {
+			name = start.AddDate(0, 0, weekday).Format("Mon")
+		}
+		row := strings.Builder{}
+		for week := 0; week < weeks; week++ {
+			day := start.AddDate(0, 0, week*7+weekday)
+			if day.Before(since) || day.After(until) {
+				row.WriteString(" ")
+				continue
+			}
+			shade := 0
+			if counts[day] != 0 {
+				shade = 1 + (counts[day]*(len(heatmapShades)-1)-1)/max
+			}
+			row.WriteString(heatmapShades[shade])
+		}
+		fmt.Fprintf(w, "%-3s %s\n", name, strings.TrimRight(row.String(), " "))
+	}
+	fmt.Fprintf(w, "    less %s more, busiest day %v\n", strings.Join(heatmapShades, ""), max)
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pablothedeveloper/formly"
+)
+
+func TestBarChart(t *testing.T) {
+	b := bytes.Buffer{}
+	barChart(&b, []barRow{{"yes", 4}, {"no", 1}, {"maybe", 0}})
+	want := "yes   │" + strings.Repeat("█", barWidth) + " 4\n" +
+		"no    │" + strings.Repeat("█", barWidth/4) + " 1\n" +
+		"maybe │ 0\n"
+	if b.String() != want {
+		t.Errorf("barChart =\n%s\nwant\n%s", b.String(), want)
+	}
+}
+
+func TestValueBars(t *testing.T) {
+	enum := formly.Label{Type: formly.EnumLabel, Choices: []string{"home", "office", "train"}}
+	numbers := []string{}
+	for i := 0; i < 100; i++ {
+		numbers = append(numbers, fmt.Sprint(i))
+	}
+	for _, tt := range []struct {
+		name   string
+		label  formly.Label
+		values []string
+		want   string
+	}{
+		{"enum", enum, []string{"office", "home", "office"}, "[{home 1} {office 2} {train 0}]"},
+		{"bool", formly.Label{Type: formly.BoolLabel}, []string{"false"}, "[{true 0} {false 1}]"},
+		{"text", formly.Label{Type: formly.TextLabel}, []string{"b", "a", "b"}, "[{b 2} {a 1}]"},
+		{"few numbers", formly.Label{Type: formly.IntLabel}, []string{"10", "2", "10"}, "[{2 1} {10 2}]"},
+		{"float", formly.Label{Type: formly.FloatLabel}, []string{"0.333", "1.5"}, "[{0.33 1} {1.5 1}]"},
+	} {
+		if got := fmt.Sprint(valueBars(tt.label, tt.values)); got != tt.want {
+			t.Errorf("valueBars of %s = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+	rows := valueBars(formly.Label{Type: formly.IntLabel}, numbers)
+	total := 0
+	for _, row := range rows {
+		total += row.count
+	}
+	if len(rows) != histogramAt || total != len(numbers) || rows[0].name != "0–8.25" {
+		t.Errorf("valueBars of %v numbers = %v, want them binned into %v rows", len(numbers), rows, histogramAt)
+	}
+}
+
+func TestLineChart(t *testing.T) {
+	day := time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC)
+	value := func(v float64) *float64 {
+		return &v
+	}
+	b := bytes.Buffer{}
+	lineChart(&b, []linePoint{
+		{day, value(1)},
+		{day.AddDate(0, 0, 1), value(1)},
+		{day.AddDate(0, 0, 2), nil},
+		{day.AddDate(0, 0, 3), value(10)},
+	}, formly.DayPeriod)
+	lines := strings.Split(b.String(), "\n")
+	if len(lines) != lineHeight+4 {
+		t.Fatalf("lineChart draws %v lines, want %v:\n%s", len(lines), lineHeight+4, b.String())
+	}
+	for i, want := range map[int]string{
+		0:              "10 ┤      ●",
+		lineHeight - 1: " 1 ┤●─●",
+		lineHeight:     "   └────────",
+		lineHeight + 1: "    2021-03-01 – 2021-03-04",
+		lineHeight + 2: "    one point per day",
+	} {
+		if lines[i] != want {
+			t.Errorf("line %v of lineChart = %q, want %q", i, lines[i], want)
+		}
+	}
+	b.Reset()
+	lineChart(&b, []linePoint{{day, nil}}, formly.DayPeriod)
+	if b.String() != "no values to chart\n" {
+		t.Errorf("lineChart without values = %q", b.String())
+	}
+}
+
+func TestHeatmap(t *testing.T) {
+	// 2021-03-01 is a monday
+	since := time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC)
+	until := since.AddDate(0, 0, 9)
+	b := bytes.Buffer{}
+	heatmap(&b, map[time.Time]int{since: 4, since.AddDate(0, 0, 1): 1, since.AddDate(0, 0, 8): 2}, since, until)
+	want := "    Mar\n" +
+		"Mon █·\n" +
+		"    ░▒\n" +
+		"Wed ··\n" +
+		"    ·\n" +
+		"Fri ·\n" +
+		"    ·\n" +
+		"Sun ·\n" +
+		"    less ·░▒▓█ more, busiest day 4\n"
+	if b.String() != want {
+		t.Errorf("heatmap =\n%s\nwant\n%s", b.String(), want)
+	}
+}
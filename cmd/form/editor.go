@@ -0,0 +1,186 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/pablothedeveloper/formly"
+)
+
+// The editor template has a section per label, started by a header line
+// like ==> label-name (hints) <==. The values of repeatable labels are
+// separated by a line holding only the separator.
+const (
+	editorHeaderStart = "==> "
+	editorHeaderEnd   = " <=="
+	editorSeparator   = "--"
+)
+
+var errEditorCanceled = errors.New("the file was left empty, nothing was saved")
+
+// editValues lets the user edit values, keyed by label id, in their editor
+// until validate accepts them.
+func editValues(
+	form formly.Form, labels []formly.Label, values map[int64][]string, validate func(map[int64][]string) error,
+) (map[int64][]string, error) {
+	problem := ""
+	for {
+		txt, err := openEditor(editorTemplate(form, labels, values, problem))
+		if err != nil {
+			return nil, err
+		}
+		if strings.TrimSpace(txt) == "" {
+			return nil, errEditorCanceled
+		}
+		edited, err := parseEditorTemplate(txt, labels)
+		if err == nil {
+			err = validate(edited)
+		}
+		if err == nil {
+			return edited, nil
+		}
+		// the next round starts from what was written, with the problem on top
+		fmt.Fprintln(os.Stderr, err)
+		problem = err.Error()
+		if edited != nil {
+			values = edited
+		}
+	}
+}
+
+func editorTemplate(form formly.Form, labels []formly.Label, values map[int64][]string, problem string) string {
+	b := strings.Builder{}
+	if problem != "" {
+		fmt.Fprintf(&b, "# error: %s\n", problem)
+	}
+	fmt.Fprintf(&b, "# form '%s': %s\n", form.Name, form.Usage)
+	b.WriteString("# Write the value of each label below its " + editorHeaderStart + "label" + editorHeaderEnd + " line and\n")
+	b.WriteString("# separate the values of a repeatable label with a line holding only " + editorSeparator + ".\n")
+	b.WriteString("# Labels left empty are skipped. Everything above the first label is\n")
+	b.WriteString("# ignored, save an empty file to cancel.\n")
+	for _, label := range labels {
+		fmt.Fprintf(&b, "\n%s%s%s%s\n", editorHeaderStart, label.Name, promptHint(label), editorHeaderEnd)
+		b.WriteString(strings.Join(values[label.ID], "\n"+editorSeparator+"\n"))
+		if len(values[label.ID]) != 0 {
+			b.WriteString("\n")
+		}
+	}
+	return b.String()
+}
+
+// parseEditorTemplate reads the values of the labels back, keyed by label
+// id. Labels without a section in txt are left out.
+func parseEditorTemplate(txt string, labels []formly.Label) (map[int64][]string, error) {
+	values := map[int64][]string{}
+	var current *formly.Label
+	lines := []string{}
+	flush := func() {
+		if current == nil {
+			return
+		}
+		section := []string{}
+		for _, value := range splitLines(lines) {
+			if value != "" {
+				section = append(section, value)
+			}
+		}
+		values[current.ID] = section
+	}
+	for _, line := range strings.Split(strings.ReplaceAll(txt, "\r\n", "\n"), "\n") {
+		if !strings.HasPrefix(line, editorHeaderStart) || !strings.HasSuffix(line, editorHeaderEnd) {
+			lines = append(lines, line)
+			continue
+		}
+		header := strings.TrimSuffix(strings.TrimPrefix(line, editorHeaderStart), editorHeaderEnd)
+		label, ok := headerLabel(header, labels)
+		if !ok {
+			return nil, fmt.Errorf("'%s' is not a label of the form", header)
+		}
+		flush()
+		if _, ok := values[label.ID]; ok {
+			return nil, fmt.Errorf("label '%s' has more than one section", label.Name)
+		}
+		current = &label
+		lines = []string{}
+	}
+	flush()
+	return values, nil
+}
+
+// headerLabel finds the label of a section header, which is the label's
+// name optionally followed by hints in parentheses.
+func headerLabel(header string, labels []formly.Label) (formly.Label, bool) {
+	for _, label := range labels {
+		if header == label.Name {
+			return label, true
+		}
+	}
+	for _, label := range labels {
+		if strings.HasPrefix(header, label.Name+" (") && strings.HasSuffix(header, ")") {
+			return label, true
+		}
+	}
+	return formly.Label{}, false
+}
+
+// splitLines splits the lines of a section at separator lines and trims
+// the blank lines around each value.
+func splitLines(lines []string) []string {
+	values := []string{}
+	value := []string{}
+	add := func() {
+		for len(value) != 0 && strings.TrimSpace(value[0]) == "" {
+			value = value[1:]
+		}
+		for len(value) != 0 && strings.TrimSpace(value[len(value)-1]) == "" {
+			value = value[:len(value)-1]
+		}
+		values = append(values, strings.Join(value, "\n"))
+		value = []string{}
+	}
+	for _, line := range lines {
+		if line == editorSeparator {
+			add()
+			continue
+		}
+		value = append(value, line)
+	}
+	add()
+	return values
+}
+
+// openEditor lets the user edit txt in $VISUAL or $EDITOR and returns the
+// saved text.
+func openEditor(txt string) (string, error) {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+	}
+	file, err := ioutil.TempFile("", "form-*.txt")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(file.Name())
+	if _, err := file.WriteString(txt); err != nil {
+		file.Close()
+		return "", err
+	}
+	if err := file.Close(); err != nil {
+		return "", err
+	}
+	args := strings.Fields(editor)
+	cmd := exec.Command(args[0], append(args[1:], file.Name())...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("editor '%s' failed: %v", editor, err)
+	}
+	saved, err := ioutil.ReadFile(file.Name())
+	return string(saved), err
+}
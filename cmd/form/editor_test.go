@@ -0,0 +1,34 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/pablothedeveloper/formly"
+)
+
+func TestEditorTemplate(t *testing.T) {
+	form := formly.Form{ID: 1, Name: "journal", Usage: "daily journal"}
+	labels := []formly.Label{
+		{ID: 1, Name: "entry", Repeatable: true, Type: formly.TextLabel},
+		{ID: 2, Name: "mood", Type: formly.IntLabel, Required: true},
+		{ID: 3, Name: "weather", Type: formly.TextLabel},
+	}
+	values := map[int64][]string{1: {"a paragraph\n\nover two", "another"}, 2: {"7"}}
+	got, err := parseEditorTemplate(editorTemplate(form, labels, values, "a problem"), labels)
+	if err != nil {
+		t.Fatal(err)
+	}
+	values[3] = []string{}
+	if !reflect.DeepEqual(got, values) {
+		t.Errorf("parsing the template of %q = %q", values, got)
+	}
+	for _, tt := range []struct{ name, txt string }{
+		{"an unknown label", "==> unknown <==\nvalue\n"},
+		{"a label twice", "==> mood <==\n7\n==> mood (int, required) <==\n8\n"},
+	} {
+		if _, err := parseEditorTemplate(tt.txt, labels); err == nil {
+			t.Errorf("parsing a file with %s succeeded", tt.name)
+		}
+	}
+}
@@ -2,20 +2,36 @@ package main
 
 import (
 	"bufio"
+	"context"
+	"database/sql"
 	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"log"
+	"net"
+	"net/http"
 	"os"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/pablothedeveloper/formly"
+	"github.com/pablothedeveloper/formly/server"
 )
 
-const defaultCommandUsage string = `usage: form [--help] [command] [--help] [<args>]
+const defaultCommandUsage string = `usage: form [--help] [--db <path>] [--workspace <name>] [--output table|json|yaml] [command] [--help] [<args>]
 description: the form cli tool create and manages forms on the terminal.
 
+options:
+	--db
+		- database file to use, defaults to $FORMLY_DB
+	--workspace
+		- named workspace to use, defaults to $FORMLY_WORKSPACE
+		  workspaces live in $XDG_DATA_HOME/formly/workspaces (~/.local/share/formly/workspaces)
+	--output
+		- table, json or yaml, defaults to table
+
 commands:
 	create
 		- creates a form
@@ -28,19 +44,52 @@ commands:
 	submit
 		- submits an existing form
 	submissions
-		- views prior submissions of a form
+		- views prior submissions of a form, edits or deletes one of them
+	stats
+		- summarizes the submissions of a form
+	chart
+		- draws the submissions of a form over time
+	history
+		- lists the versions of a form's labels
+	export
+		- exports the submissions of a form as csv, jsonl, markdown or html
+	import
+		- imports submissions of a form from csv or jsonl
+	search
+		- searches the entries of all submissions
+	hook
+		- runs commands before or after a form is submitted
+	webhooks
+		- posts the submissions of a form to urls and logs the deliveries
+	serve
+		- serves the forms over a json rest api and as html pages
 	modify
 		- modifies a form or a form's label
+	apply
+		- creates or updates a form from a definition file
+	plan
+		- shows what applying a definition file would change
+	export-schema
+		- writes the definition of an existing form
+	workspaces
+		- lists the named workspaces
+	db
+		- manages the database itself, e.g. its schema migrations
 `
 
 func main() {
-	env, err := formly.NewLocalSqLiteEnv()
-	if err != nil {
-		log.Fatal(err)
-	}
-	defer env.Close()
+	dbPath := flag.String("db", "", "database file to use")
+	workspace := flag.String("workspace", "", "named workspace to use")
+	outputName := flag.String("output", string(tableOutput), "format of the output: table, json or yaml")
+	var env *formly.Env
 	flag.CommandLine.Usage = func() {
-		fmt.Println(defaultCommandUsage)
+		fmt.Print(defaultCommandUsage)
+		if env == nil {
+			var err error
+			if env, err = openEnv(*dbPath, *workspace); err != nil {
+				log.Fatal("main:", err)
+			}
+		}
 		forms, err := env.FormModel.GetAll()
 		if err != nil {
 			log.Fatal("main:", err)
@@ -54,10 +103,45 @@ func main() {
 		}
 	}
 	flag.Parse()
-	if flag.NArg() == 0 && flag.NFlag() == 0 {
-		fmt.Println(defaultCommandUsage)
+	if flag.NArg() == 0 {
+		fmt.Print(defaultCommandUsage)
+		return
+	}
+	out, err := parseOutput(*outputName)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	outputSet := false
+	flag.Visit(func(f *flag.Flag) {
+		outputSet = outputSet || f.Name == "output"
+	})
+	switch flag.Arg(0) {
+	case "workspaces":
+		if err := workspaces(out); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	case "db":
+		if err := db(*dbPath, *workspace, flag.Args()[1:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
 		return
 	}
+	if env == nil {
+		var err error
+		if env, err = openEnv(*dbPath, *workspace); err != nil {
+			log.Fatal(err)
+		}
+	}
+	defer env.Close()
+	// every run sends what is due on its way out, webhooks deliver does so
+	// itself with a timeout of its own
+	if flag.Arg(0) != "webhooks" || flag.Arg(1) != "deliver" {
+		defer drainWebhooks(env)
+	}
 	cmd := flag.NewFlagSet(flag.Arg(0), flag.ExitOnError)
 	cmd.Usage = func() {
 		switch cmd.Name() {
@@ -66,38 +150,181 @@ func main() {
 		case "delete":
 			fmt.Println("usage: form delete <form-name> [--label]")
 		case "label":
-			fmt.Println("usage: form label <form-name> [--repeatable] <label-name> <label-usage>")
+			fmt.Println(
+				"usage: form label <form-name> [--repeatable] [--required] [--default <value>]" +
+					" [--type text|int|float|date|bool|enum] [--choice <choice>...] [--choices a,b,c] [--pattern <regex>]" +
+					" [--min-length <n>] [--max-length <n>] [--min <n>] [--max <n>] <label-name> <label-usage>",
+			)
 		case "review":
 			fmt.Println("usage: form review <form-name>")
 		case "submit":
 			fmt.Println("usage: form submit <form-name> <...form-labels-as-flags>")
+			fmt.Println("       form submit <form-name> --json <file>")
+			fmt.Println("       form submit <form-name> --editor <...form-labels-as-flags>")
+			fmt.Println("--json reads a json object with a value per label, or an array for repeatable labels, - for stdin")
+			fmt.Println("--editor opens $VISUAL or $EDITOR with a section per label, prefilled with the label flags")
 		case "submissions":
-			fmt.Println("usage: form submissions <form-name>")
+			fmt.Println("usage: form submissions <form-name> [--where <condition>] [--since <date>] [--until <date>] [--limit <n>]")
+			fmt.Println("       form submissions <form-name> edit <submission-id> <...changed-labels-as-flags>")
+			fmt.Println("       form submissions <form-name> edit <submission-id> --editor")
+			fmt.Println("       form submissions <form-name> delete <submission-id>")
+			fmt.Println("       form submissions <form-name> changes <submission-id>")
+			fmt.Println("conditions compare labels with =, !=, <, <=, >, >= or contains and combine with and, or, not and ()")
+			fmt.Println("dates are YYYY-MM-DD or RFC 3339 times in utc, --until includes the whole day of a date")
+		case "stats":
+			fmt.Println("usage: form stats <form-name> [--by day|week|month] [--where <condition>] [--since <date>] [--until <date>]")
+			fmt.Println("dates are YYYY-MM-DD or RFC 3339 times in utc, --until includes the whole day of a date")
+		case "chart":
+			fmt.Println(
+				"usage: form chart <form-name> [<label-name>] [--kind line|bar|heatmap] [--bucket day|week|month]" +
+					" [--where <condition>] [--since <date>] [--until <date>]",
+			)
+			fmt.Println("line charts plot the mean of a number per bucket, or the submissions per bucket without a label")
+			fmt.Println("bar charts count the values of a label, heatmaps the submissions per day of the last year")
+		case "history":
+			fmt.Println("usage: form history <form-name>")
+		case "export":
+			fmt.Println(
+				"usage: form export <form-name> [--format " + strings.Join(formly.Exporters(), "|") + "]" +
+					" [--since <date>] [--until <date>] [--expand]",
+			)
+			fmt.Println("dates are YYYY-MM-DD or RFC 3339 times in utc, --until includes the whole day of a date")
+			fmt.Println("--expand writes a row for every combination of the values of the repeatable labels")
+		case "import":
+			fmt.Println("usage: form import <form-name> [--format csv|jsonl] [--dry-run] [--no-hooks] <file>")
+			fmt.Println("columns are matched to labels by name, created_at keeps the original time of a submission")
+			fmt.Println("every row goes through the form's hooks and webhooks like form submit, unless --no-hooks is given")
 		case "modify":
 			fmt.Println(
 				"usage: form modify <form-name> [--name] [--usage]" +
-					"<label-name> [--name] [--usage] [--position] [--repeatable]",
+					" <label-name> [--name] [--usage] [--position] [--repeatable] [--required] [--default] [--type] [--choice...] [--choices]" +
+					" [--pattern] [--min-length] [--max-length] [--min] [--max]",
 			)
+		case "apply":
+			fmt.Println("usage: form apply -f <definition-file.yaml|json>")
+		case "plan":
+			fmt.Println("usage: form plan -f <definition-file.yaml|json>")
+			fmt.Println("exits with 2 when the stored form differs from the definition")
+		case "export-schema":
+			fmt.Println("usage: form export-schema <form-name> [--format yaml|json]")
+		case "search":
+			fmt.Println("usage: form search <query> [--form <form-name>] [--label <label-name>]")
+			fmt.Println("finds the entries holding every word of the query, a word ending in * matches as a prefix")
+		case "hook":
+			fmt.Println("usage: form hook add <form-name> [--pre] [--timeout <duration>] [--on-failure abort|ignore] <command>")
+			fmt.Println("       form hook list <form-name>")
+			fmt.Println("       form hook delete <form-name> <hook-id>")
+			fmt.Println("commands run with sh, the submission as json on stdin and $FORMLY_FORM and $FORMLY_SUBMISSION_ID set")
+			fmt.Println("--pre hooks run before the submission is stored and reject it when they fail, unless --on-failure ignore")
+			fmt.Println("the other hooks run once it is stored and only warn when they fail, unless --on-failure abort")
+		case "webhooks":
+			fmt.Println("usage: form webhooks add <form-name> <url> [--secret <secret>]")
+			fmt.Println("       form webhooks list <form-name>")
+			fmt.Println("       form webhooks delete <form-name> <webhook-id>")
+			fmt.Println("       form webhooks log <form-name> [--limit <n>]")
+			fmt.Println("       form webhooks deliver [--timeout <duration>]")
+			fmt.Println("every submission is posted as json, signed with the secret in the X-Formly-Signature header")
+			fmt.Println("deliveries are sent by every run of form for up to " + drainTimeout.String() + ", while form serve is up or by form webhooks deliver")
+			fmt.Println("failed ones are retried with a growing delay")
+		case "serve":
+			fmt.Println("usage: form serve [--addr <host:port>] [--token <token>]")
+			fmt.Println("--token defaults to $FORMLY_TOKEN, requests then need it as a bearer token")
+			fmt.Println("the api is described by the OpenAPI document at /openapi.json")
+			fmt.Println("forms can be filled in from a browser at /ui, with the token as the password")
 		default:
 			flag.CommandLine.Usage()
 		}
 	}
+	switch cmd.Name() {
+	case "apply":
+		file := cmd.String("f", "", "definition file to apply, - for stdin")
+		cmd.Parse(flag.Args()[1:])
+		if *file == "" {
+			fatalUsage(env, cmd.Usage, "Must specify a definition file")
+		}
+		if err := apply(env, out, *file); err != nil {
+			fatal(env, err)
+		}
+		return
+	case "plan":
+		file := cmd.String("f", "", "definition file to compare, - for stdin")
+		cmd.Parse(flag.Args()[1:])
+		if *file == "" {
+			fatalUsage(env, cmd.Usage, "Must specify a definition file")
+		}
+		drift, err := plan(env, out, *file)
+		if err != nil {
+			fatal(env, err)
+		}
+		if drift {
+			env.Close()
+			os.Exit(2)
+		}
+		return
+	case "export-schema":
+		format := cmd.String("format", "yaml", "format of the definition: yaml or json")
+		cmd.Parse(flag.Args()[1:])
+		if cmd.NArg() < 1 {
+			fatalUsage(env, cmd.Usage, "Must specify a form name")
+		}
+		// flags may also follow the form name
+		name := cmd.Arg(0)
+		cmd.Parse(cmd.Args()[1:])
+		if err := exportSchema(env, name, *format); err != nil {
+			fatal(env, err)
+		}
+		return
+	case "search":
+		form := cmd.String("form", "", "only search the submissions of this form")
+		label := cmd.String("label", "", "only search the entries of labels with this name")
+		// the words of the query and the flags may come in any order
+		words := []string{}
+		for args := flag.Args()[1:]; ; args = cmd.Args()[1:] {
+			cmd.Parse(args)
+			if cmd.NArg() == 0 {
+				break
+			}
+			words = append(words, cmd.Arg(0))
+		}
+		if len(words) == 0 {
+			fatalUsage(env, cmd.Usage, "Must specify what to search for")
+		}
+		if err := search(env, out, strings.Join(words, " "), *form, *label); err != nil {
+			fatal(env, err)
+		}
+		return
+	case "hook":
+		if err := hook(env, out, cmd, flag.Args()[1:]); err != nil {
+			fatal(env, err)
+		}
+		return
+	case "webhooks":
+		if err := webhooks(env, out, cmd, flag.Args()[1:]); err != nil {
+			fatal(env, err)
+		}
+		return
+	case "serve":
+		addr := cmd.String("addr", "127.0.0.1:8080", "address to listen on")
+		token := cmd.String("token", os.Getenv("FORMLY_TOKEN"), "bearer token requests have to carry")
+		cmd.Parse(flag.Args()[1:])
+		if err := serve(env, *addr, *token); err != nil {
+			fatal(env, err)
+		}
+		return
+	}
 	cmd.Parse(flag.Args()[1:])
 	switch cmd.Name() {
 	case "create":
 		if cmd.NArg() < 2 {
-			fmt.Println("fatal: Must specify a form name and form usage")
-			cmd.Usage()
-			return
+			fatalUsage(env, cmd.Usage, "Must specify a form name and form usage")
 		}
-		if err := create(env, cmd.Arg(0), cmd.Arg(1)); err != nil {
-			fmt.Println(err)
+		if err := create(env, out, cmd.Arg(0), cmd.Arg(1)); err != nil {
+			fatal(env, err)
 		}
 	case "delete":
 		subcmd, err := newSubCommand(env, cmd, cmd.Args()...)
 		if err != nil {
-			fmt.Println(err)
-			return
+			fatal(env, err)
 		}
 		labelName := subcmd.fs.String("label", "", "for deleting a specific label name")
 		subcmd.parse()
@@ -109,77 +336,224 @@ func main() {
 			}
 		}
 		if labelID == -1 && *labelName != "" {
-			fmt.Printf("label '%s' for form '%s' not found\n", *labelName, subcmd.fs.Name())
-			return
+			fatal(env, fmt.Errorf("label '%s' for form '%s' not found", *labelName, subcmd.fs.Name()))
 		}
-		if err := delete(env, subcmd.form.ID, labelID); err != nil {
-			fmt.Println(err)
+		if err := delete(env, out, subcmd.form, subcmd.labels, labelID); err != nil {
+			fatal(env, err)
 		}
 	case "label":
 		subcmd, err := newSubCommand(env, cmd, cmd.Args()...)
 		if err != nil {
-			fmt.Println(err)
-			return
+			fatal(env, err)
 		}
 		repeatable := subcmd.fs.Bool("repeatable", false, "whether label repeats")
+		required := subcmd.fs.Bool("required", false, "whether label must be filled in on submit")
+		def := subcmd.fs.String("default", "", "value used when the label is left empty on submit")
+		typ := subcmd.fs.String("type", string(formly.TextLabel), "type of the label's entries")
+		choices := setupChoiceFlags(subcmd.fs, nil)
+		constraints := setupConstraintFlags(subcmd.fs, formly.Constraints{})
 		subcmd.parse()
 		name := subcmd.fs.Arg(0)
 		usage := subcmd.fs.Arg(1)
 		if subcmd.fs.NArg() < 2 {
-			fmt.Println("fatal: Must specify a label name and label usage")
-			cmd.Usage()
-			return
+			fatalUsage(env, cmd.Usage, "Must specify a label name and label usage")
+		}
+		labelConstraints, err := constraints()
+		if err != nil {
+			fatal(env, err)
 		}
-		if err := label(env, subcmd.form.ID, int64(len(subcmd.labels)+1), *repeatable, name, usage); err != nil {
-			fmt.Println(err)
+		if err := label(env, out, formly.Label{
+			FormID:      subcmd.form.ID,
+			Position:    int64(len(subcmd.labels) + 1),
+			Repeatable:  *repeatable,
+			Required:    *required,
+			Type:        formly.LabelType(*typ),
+			Choices:     choices(),
+			Constraints: labelConstraints,
+			Name:        name,
+			Usage:       usage,
+			Default:     *def,
+		}); err != nil {
+			fatal(env, err)
 		}
 	case "review":
 		subcmd, err := newSubCommand(env, cmd, cmd.Args()...)
 		if err != nil {
-			fmt.Println(err)
-			return
+			fatal(env, err)
+		}
+		if err := out.print(newFormDocument(subcmd.form, subcmd.labels), func(w io.Writer) {
+			printForm(w, newFormDocument(subcmd.form, subcmd.labels))
+		}); err != nil {
+			fatal(env, err)
 		}
-		fmt.Printf("form created: %v\n", subcmd.form)
-		fmt.Printf("labels created: %v\n", subcmd.labels)
 	case "submit":
 		subcmd, err := newSubCommand(env, cmd, cmd.Args()...)
 		if err != nil {
-			fmt.Println(err)
-			return
+			fatal(env, err)
 		}
 		subcmd.setupFormFlags()
+		// a label of a database from before json and editor were reserved
+		// keeps its flag
+		jsonPath := new(string)
+		if subcmd.fs.Lookup("json") == nil {
+			jsonPath = subcmd.fs.String("json", "", "json file with the submission, - for stdin")
+		}
+		editor := new(bool)
+		if subcmd.fs.Lookup("editor") == nil {
+			editor = subcmd.fs.Bool("editor", false, "write the submission in $VISUAL or $EDITOR")
+		}
 		if err := subcmd.parseFormFlags(); err != nil {
-			fmt.Println(err)
+			fatal(env, err)
+		}
+		if *editor && *jsonPath != "" {
+			fatal(env, errors.New("--json can not be combined with --editor"))
+		}
+		if *editor {
+			if err := subcmd.submitEditor(env, out); err != nil {
+				fatal(env, err)
+			}
 			return
 		}
-		if err := subcmd.submitForm(env); err != nil {
-			fmt.Println(err)
+		if *jsonPath != "" {
+			if !outputSet {
+				out = jsonOutput
+			}
+			if err := subcmd.submitJSON(env, out, *jsonPath); err != nil {
+				fatal(env, err)
+			}
 			return
 		}
+		if err := subcmd.submitForm(env, out); err != nil {
+			fatal(env, err)
+		}
 	case "submissions":
 		subcmd, err := newSubCommand(env, cmd, cmd.Args()...)
 		if err != nil {
-			fmt.Println(err)
+			fatal(env, err)
+		}
+		where := subcmd.fs.String("where", "", `only list submissions matching a condition, e.g. 'mood >= 7 and tags contains "gym"'`)
+		since := subcmd.fs.String("since", "", "only list submissions created at or after this date")
+		until := subcmd.fs.String("until", "", "only list submissions created up to this date")
+		limit := subcmd.fs.Int("limit", 0, "list at most this many submissions")
+		subcmd.fs.Usage = cmd.Usage
+		subcmd.parse()
+		if subcmd.fs.NArg() != 0 {
+			if err := changeSubmission(env, out, subcmd.form, subcmd.fs.Args(), cmd.Usage); err != nil {
+				fatal(env, err)
+			}
 			return
 		}
-		if err := submissions(env, subcmd.form.ID); err != nil {
-			fmt.Println(err)
+		query, err := submissionQuery(subcmd.form.ID, *where, *since, *until, *limit)
+		if err == nil {
+			err = submissions(env, out, subcmd.form, query)
+		}
+		if err != nil {
+			fatal(env, err)
+		}
+	case "stats":
+		subcmd, err := newSubCommand(env, cmd, cmd.Args()...)
+		if err != nil {
+			fatal(env, err)
+		}
+		by := subcmd.fs.String("by", "week", "period to count submissions in: day, week or month")
+		where := subcmd.fs.String("where", "", "only count submissions matching a condition")
+		since := subcmd.fs.String("since", "", "only count submissions created at or after this date")
+		until := subcmd.fs.String("until", "", "only count submissions created up to this date")
+		subcmd.fs.Usage = cmd.Usage
+		subcmd.parse()
+		query, err := submissionQuery(subcmd.form.ID, *where, *since, *until, 0)
+		if err == nil {
+			err = stats(env, out, query, formly.StatsPeriod(*by))
+		}
+		if err != nil {
+			fatal(env, err)
+		}
+	case "chart":
+		subcmd, err := newSubCommand(env, cmd, cmd.Args()...)
+		if err != nil {
+			fatal(env, err)
+		}
+		kind := subcmd.fs.String("kind", "", "line, bar or heatmap, defaults to line for numbers, bar for other labels and heatmap without a label")
+		bucket := subcmd.fs.String("bucket", "day", "period a point of a line chart stands for: day, week or month")
+		where := subcmd.fs.String("where", "", "only chart submissions matching a condition")
+		since := subcmd.fs.String("since", "", "only chart submissions created at or after this date")
+		until := subcmd.fs.String("until", "", "only chart submissions created up to this date")
+		subcmd.fs.Usage = cmd.Usage
+		subcmd.parse()
+		// flags may also follow the label
+		labelName := ""
+		if subcmd.fs.NArg() != 0 {
+			labelName = subcmd.fs.Arg(0)
+			subcmd.fs.Parse(subcmd.fs.Args()[1:])
+		}
+		query, err := submissionQuery(subcmd.form.ID, *where, *since, *until, 0)
+		if err == nil {
+			err = chart(env, out, subcmd.form, subcmd.labels, labelName, *kind, formly.StatsPeriod(*bucket), query)
+		}
+		if err != nil {
+			fatal(env, err)
+		}
+	case "history":
+		subcmd, err := newSubCommand(env, cmd, cmd.Args()...)
+		if err != nil {
+			fatal(env, err)
+		}
+		if err := history(env, out, subcmd.form); err != nil {
+			fatal(env, err)
+		}
+	case "export":
+		subcmd, err := newSubCommand(env, cmd, cmd.Args()...)
+		if err != nil {
+			fatal(env, err)
+		}
+		format := subcmd.fs.String("format", "csv", "format of the export: "+strings.Join(formly.Exporters(), ", "))
+		since := subcmd.fs.String("since", "", "only export submissions created at or after this date")
+		until := subcmd.fs.String("until", "", "only export submissions created up to this date")
+		expand := subcmd.fs.Bool("expand", false, "put every value of a repeatable label on its own row")
+		subcmd.parse()
+		if err := export(env, subcmd.form.ID, *format, *since, *until, *expand); err != nil {
+			fatal(env, err)
+		}
+	case "import":
+		subcmd, err := newSubCommand(env, cmd, cmd.Args()...)
+		if err != nil {
+			fatal(env, err)
+		}
+		format := subcmd.fs.String("format", "", "format of the file: csv or jsonl, guessed from its extension by default")
+		dryRun := subcmd.fs.Bool("dry-run", false, "only validate the file, without importing anything")
+		noHooks := subcmd.fs.Bool("no-hooks", false, "import without running the form's hooks or queueing its webhooks")
+		subcmd.parse()
+		if subcmd.fs.NArg() < 1 {
+			fatalUsage(env, cmd.Usage, "Must specify a file to import, - for stdin")
+		}
+		// flags may also follow the file
+		path := subcmd.fs.Arg(0)
+		subcmd.fs.Parse(subcmd.fs.Args()[1:])
+		ok, err := importSubmissions(env, out, subcmd.form, subcmd.labels, path, *format, *dryRun, !*noHooks)
+		if err != nil {
+			fatal(env, err)
+		}
+		if !ok {
+			env.Close()
+			os.Exit(1)
 		}
 	case "modify":
 		subcmd, err := newSubCommand(env, cmd, cmd.Args()...)
 		if err != nil {
-			fmt.Println(err)
-			return
+			fatal(env, err)
 		}
 		newName := subcmd.fs.String("name", subcmd.form.Name, "new name for form")
 		newUsage := subcmd.fs.String("usage", subcmd.form.Usage, "new usage for form")
 		subcmd.parse()
 
-		if err := modify(env, subcmd.form.ID, *newName, *newUsage); err != nil {
-			fmt.Println(err)
-			return
-		}
+		form := formly.Form{ID: subcmd.form.ID, Name: *newName, Usage: *newUsage}
 		if subcmd.fs.NArg() == 0 {
+			if err := modify(env, form, subcmd.labels); err != nil {
+				fatal(env, err)
+			}
+			if err := modified(env, out, subcmd.form.ID); err != nil {
+				fatal(env, err)
+			}
 			return
 		}
 		found := -1
@@ -190,39 +564,200 @@ func main() {
 			}
 		}
 		if found == -1 {
-			fmt.Printf("label '%s' for form '%s' not found\n", subcmd.fs.Arg(0), subcmd.fs.Name())
-			return
+			fatal(env, fmt.Errorf("label '%s' for form '%s' not found", subcmd.fs.Arg(0), subcmd.fs.Name()))
 		}
 		subsubcmd := flag.NewFlagSet(subcmd.fs.Arg(0), flag.ExitOnError)
 		newLabelName := subsubcmd.String("name", subcmd.labels[found].Name, "new name for label")
 		newLabelUsage := subsubcmd.String("usage", subcmd.labels[found].Usage, "new usage for label")
 		position := subsubcmd.Int64("position", subcmd.labels[found].Position, "new position for label")
 		repeatable := subsubcmd.Bool("repeatable", subcmd.labels[found].Repeatable, "whether label repeats")
+		required := subsubcmd.Bool("required", subcmd.labels[found].Required, "whether label must be filled in on submit")
+		def := subsubcmd.String("default", subcmd.labels[found].Default, "value used when the label is left empty on submit")
+		typ := subsubcmd.String("type", string(subcmd.labels[found].Type), "type of the label's entries")
+		choices := setupChoiceFlags(subsubcmd, subcmd.labels[found].Choices)
+		constraints := setupConstraintFlags(subsubcmd, subcmd.labels[found].Constraints)
 		subsubcmd.Parse(subcmd.fs.Args()[1:])
-		if err := modifylabel(env, subcmd.form.ID, subcmd.labels[found].ID, *position, *repeatable, *newLabelName, *newLabelUsage); err != nil {
-			fmt.Println(err)
-			return
+		labelConstraints, err := constraints()
+		if err != nil {
+			fatal(env, err)
+		}
+		labels, err := modifylabel(subcmd.labels, found, formly.Label{
+			ID:          subcmd.labels[found].ID,
+			FormID:      subcmd.form.ID,
+			Position:    *position,
+			Repeatable:  *repeatable,
+			Required:    *required,
+			Type:        formly.LabelType(*typ),
+			Choices:     choices(),
+			Constraints: labelConstraints,
+			Name:        *newLabelName,
+			Usage:       *newLabelUsage,
+			Default:     *def,
+		})
+		if err != nil {
+			fatal(env, err)
+		}
+		if err := modify(env, form, labels); err != nil {
+			fatal(env, err)
+		}
+		if err := modified(env, out, subcmd.form.ID); err != nil {
+			fatal(env, err)
 		}
 	case "":
-		fmt.Println("No command passed in")
+		fatal(env, errors.New("no command passed in"))
 	default:
-		fmt.Printf("subcommand '%s' does not exist\n", cmd.Name())
+		fatal(env, fmt.Errorf("subcommand '%s' does not exist", cmd.Name()))
+	}
+}
+
+// fatal prints err to stderr and exits with 1. Deferred calls do not run on
+// exit, so env is closed first.
+func fatal(env *formly.Env, err error) {
+	fmt.Fprintln(os.Stderr, err)
+	env.Close()
+	os.Exit(1)
+}
+
+// fatalUsage is fatal for a command missing some of its arguments, whose
+// usage is printed along with what is missing.
+func fatalUsage(env *formly.Env, usage func(), missing string) {
+	fmt.Fprintln(os.Stderr, "fatal: "+missing)
+	usage()
+	env.Close()
+	os.Exit(1)
+}
+
+// openEnv picks the database from the --db and --workspace flags, then from
+// the FORMLY_DB and FORMLY_WORKSPACE environment variables.
+func openEnv(dbPath, workspace string) (*formly.Env, error) {
+	path, err := databasePath(dbPath, workspace)
+	if err != nil {
+		return nil, err
+	}
+	return formly.NewSqLiteEnv(path)
+}
+func databasePath(dbPath, workspace string) (string, error) {
+	if dbPath == "" && workspace == "" {
+		dbPath = os.Getenv("FORMLY_DB")
+	}
+	if dbPath == "" && workspace == "" {
+		workspace = os.Getenv("FORMLY_WORKSPACE")
 	}
+	if dbPath != "" {
+		return dbPath, nil
+	}
+	return formly.WorkspacePath(workspace)
+}
+
+const dbCommandUsage string = `usage: form db migrate [--status]
+	migrate
+		- upgrades the database schema to the latest version, which also
+		  happens whenever the database is opened
+	migrate --status
+		- lists the schema migrations and whether they were applied`
+
+func db(dbPath, workspace string, args []string) error {
+	cmd := flag.NewFlagSet("migrate", flag.ExitOnError)
+	status := cmd.Bool("status", false, "only list the migrations and whether they were applied")
+	cmd.Usage = func() {
+		fmt.Println(dbCommandUsage)
+	}
+	if len(args) == 0 {
+		cmd.Usage()
+		return errors.New("no db command passed in")
+	}
+	if args[0] != "migrate" {
+		cmd.Usage()
+		return fmt.Errorf("db command '%s' does not exist", args[0])
+	}
+	cmd.Parse(args[1:])
+	path, err := databasePath(dbPath, workspace)
+	if err != nil {
+		return err
+	}
+	if *status {
+		current, migrations, err := formly.SqLiteMigrations(path)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("database: %s\nschema version: %v of %v\n", path, current, formly.LatestSchemaVersion())
+		for _, migration := range migrations {
+			state := "pending"
+			if !migration.AppliedAt.IsZero() {
+				state = "applied " + migration.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("  %3v  %-28s  %s\n", migration.Version, state, migration.Description)
+		}
+		if current > formly.LatestSchemaVersion() {
+			return fmt.Errorf("%v: update formly to use this database", formly.ErrSchemaTooNew)
+		}
+		return nil
+	}
+	applied, err := formly.MigrateSqLite(path)
+	for _, migration := range applied {
+		fmt.Printf("applied migration %v: %s\n", migration.Version, migration.Description)
+	}
+	if err != nil {
+		return err
+	}
+	if len(applied) == 0 {
+		fmt.Printf("database is up to date at schema version %v\n", formly.LatestSchemaVersion())
+	}
+	return nil
+}
+
+func workspaces(out output) error {
+	workspaces, err := formly.Workspaces()
+	if err != nil {
+		return err
+	}
+	if len(workspaces) == 0 && !out.structured() {
+		fmt.Println("no workspaces yet, use 'form --workspace <name> ...' to create one")
+		return nil
+	}
+	return out.print(workspacesDocument{Workspaces: append([]string{}, workspaces...)}, func(w io.Writer) {
+		for _, workspace := range workspaces {
+			fmt.Fprintln(w, workspace)
+		}
+	})
 }
 
 type subcommand struct {
-	fs                     *flag.FlagSet
-	form                   formly.Form
-	labels                 []formly.Label
-	flags                  []formFlag
-	entries                []formly.Entry
-	unParsedArgs           []string
-	repeatableArgSeperator string
+	fs           *flag.FlagSet
+	form         formly.Form
+	labels       []formly.Label
+	flags        []formFlag
+	entries      []formly.Entry
+	unParsedArgs []string
 }
+
+// formFlag is the flag of a label on submit. A repeatable label takes the
+// flag once per value, as in --tag a --tag b, and keeps the values as typed.
 type formFlag struct {
-	labelID    int64
-	repeatable bool
-	name, txt  string
+	label  formly.Label
+	name   string
+	values []string
+}
+
+func (fflag *formFlag) String() string {
+	if fflag == nil {
+		return ""
+	}
+	return strings.Join(fflag.values, ", ")
+}
+func (fflag *formFlag) Set(txt string) error {
+	if txt == "" {
+		return nil
+	}
+	if len(fflag.values) != 0 && !fflag.label.Repeatable {
+		return fmt.Errorf("label '%s' is not repeatable, it takes a single value", fflag.name)
+	}
+	canonical, err := formly.ValidateEntry(fflag.label, txt)
+	if err != nil {
+		return err
+	}
+	fflag.values = append(fflag.values, canonical)
+	return nil
 }
 
 func newSubCommand(env *formly.Env, cmd *flag.FlagSet, args ...string) (scmd subcommand, err error) {
@@ -231,6 +766,10 @@ func newSubCommand(env *formly.Env, cmd *flag.FlagSet, args ...string) (scmd sub
 		return
 	}
 	scmd.form, err = env.FormModel.GetByName(args[0])
+	if err == sql.ErrNoRows {
+		err = fmt.Errorf("form '%s' not found", args[0])
+		return
+	}
 	if err != nil {
 		return
 	}
@@ -241,11 +780,14 @@ func newSubCommand(env *formly.Env, cmd *flag.FlagSet, args ...string) (scmd sub
 	scmd.entries = make([]formly.Entry, 0)
 	scmd.fs = flag.NewFlagSet(args[0], flag.ExitOnError)
 	scmd.unParsedArgs = args[1:]
-	scmd.repeatableArgSeperator = ",/"
 
 	scmd.fs.Usage = func() {
 		usageFlagStr := []string{}
 		for _, label := range scmd.labels {
+			if label.Repeatable {
+				usageFlagStr = append(usageFlagStr, "[--"+label.Name+" ...]")
+				continue
+			}
 			usageFlagStr = append(usageFlagStr, "[--"+label.Name+"]")
 		}
 		fmt.Printf("\nusage: form submit %s %s\n", scmd.form.Name, strings.Join(usageFlagStr, " "))
@@ -256,164 +798,1188 @@ func newSubCommand(env *formly.Env, cmd *flag.FlagSet, args ...string) (scmd sub
 	return
 }
 
-var errRepeatableFlagSeperator = errors.New("flag contains a seperator while not being repeatable")
-
 func (scmd *subcommand) parse() {
 	scmd.fs.Parse(scmd.unParsedArgs)
 }
 func (scmd *subcommand) setupFormFlags() {
+	scmd.flags = make([]formFlag, len(scmd.labels))
 	for i, label := range scmd.labels {
-		scmd.flags = append(
-			scmd.flags,
-			formFlag{labelID: label.ID, repeatable: label.Repeatable, name: label.Name, txt: ""},
-		)
-		scmd.fs.StringVar(
-			&scmd.flags[i].txt,
-			scmd.labels[i].Name,
-			"",
-			scmd.labels[i].Usage,
-		)
+		scmd.flags[i] = formFlag{label: label, name: label.Name}
+		scmd.fs.Var(&scmd.flags[i], label.Name, label.Usage)
 	}
 }
 func (scmd *subcommand) parseFormFlags() error {
 	if err := scmd.fs.Parse(scmd.unParsedArgs); err != nil {
 		return err
 	}
-	for _, fflag := range scmd.flags {
-		if !fflag.repeatable && strings.Contains(fflag.txt, scmd.repeatableArgSeperator) {
-			return errRepeatableFlagSeperator
+	if json := scmd.fs.Lookup("json"); json != nil && json.Value.String() != "" && !scmd.isLabel("json") {
+		if scmd.fs.NFlag() != 1 {
+			return errors.New("--json can not be combined with label flags")
 		}
+		return nil
 	}
 	if scmd.fs.NFlag() != 0 {
 		return nil
 	}
+	s := bufio.NewScanner(os.Stdin)
 	for i, flag := range scmd.flags {
-		s := bufio.NewScanner(os.Stdin)
 		inputs := []string{}
-		prompt := flag.name + ":\n"
+		prompt := flag.name + promptHint(flag.label) + ":\n"
 		for fmt.Print(prompt); s.Scan(); fmt.Print(prompt) {
 			txt := s.Text()
-			if strings.Contains(txt, scmd.repeatableArgSeperator) {
-				continue
-			}
 			if txt == "" {
+				if len(inputs) == 0 && flag.label.Required && flag.label.Default == "" {
+					fmt.Printf("label '%s' is required\n", flag.name)
+					continue
+				}
 				break
 			}
-			inputs = append(inputs, txt)
-			if !flag.repeatable {
+			canonical, err := formly.ValidateEntry(flag.label, txt)
+			if err != nil {
+				fmt.Println(err)
+				continue
+			}
+			inputs = append(inputs, canonical)
+			if !flag.label.Repeatable {
 				break
 			}
 		}
-		scmd.flags[i].txt = strings.Join(inputs, scmd.repeatableArgSeperator)
+		scmd.flags[i].values = inputs
 		if err := s.Err(); err != io.EOF && err != nil {
 			return err
 		}
 	}
 	return nil
 }
-func (scmd *subcommand) submitForm(env *formly.Env) error {
-	submission, err := env.SubmissionModel.Create(scmd.form.ID)
+func (scmd *subcommand) isLabel(name string) bool {
+	for _, label := range scmd.labels {
+		if label.Name == name {
+			return true
+		}
+	}
+	return false
+}
+func (scmd *subcommand) submitJSON(env *formly.Env, out output, path string) error {
+	r := io.Reader(os.Stdin)
+	if path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		r = file
+	}
+	values, err := formly.ParseSubmission(r, scmd.labels)
+	if err != nil {
+		return err
+	}
+	return scmd.submit(env, out, values)
+}
+func (scmd *subcommand) submitEditor(env *formly.Env, out output) error {
+	values := map[int64][]string{}
+	for _, flag := range scmd.flags {
+		values[flag.label.ID] = flag.values
+	}
+	values, err := editValues(scmd.form, scmd.labels, values, func(values map[int64][]string) error {
+		_, err := formly.ValidateSubmission(scmd.labels, values)
+		return err
+	})
 	if err != nil {
 		return err
 	}
-	fmt.Println(
-		fmt.Sprintf("form '%s' submitted at time:%v", scmd.fs.Name(), submission.CreateAt),
-	)
+	return scmd.submit(env, out, values)
+}
+func (scmd *subcommand) submitForm(env *formly.Env, out output) error {
+	values := map[int64][]string{}
 	for _, flag := range scmd.flags {
-		if flag.txt == "" {
-			continue
-		}
-		for _, arg := range strings.Split(flag.txt, scmd.repeatableArgSeperator) {
-			entry, err := env.EntryModel.Create(submission.ID, flag.labelID, arg)
-			if err != nil {
-				return err
-			}
-			fmt.Println(fmt.Sprintf("\t%s: %s", flag.name, entry.Txt))
-		}
+		values[flag.label.ID] = flag.values
 	}
-	return nil
+	return scmd.submit(env, out, values)
+}
+
+// submit stores the submission through the form's hooks, the hooks that
+// failed without stopping it are reported on stderr.
+func (scmd *subcommand) submit(env *formly.Env, out output, values map[int64][]string) error {
+	submission, ignored, err := env.SubmitWithHooks(scmd.form.ID, values)
+	for _, hookErr := range ignored {
+		fmt.Fprintln(os.Stderr, "warning:", hookErr)
+	}
+	if submission.ID == 0 {
+		return err
+	}
+	doc := newSubmissionDocument(scmd.form.Name, submission, scmd.labels)
+	if printErr := out.print(doc, func(w io.Writer) {
+		fmt.Fprintf(w, "form '%s' submitted\n\n", scmd.form.Name)
+		printSubmissions(w, []submissionDocument{doc})
+	}); printErr != nil {
+		return printErr
+	}
+	// a failing post-submit hook set to abort still leaves the submission
+	return err
 }
-func create(env *formly.Env, name, usage string) error {
+func create(env *formly.Env, out output, name, usage string) error {
 	form, err := env.FormModel.Create(name, usage)
 	if err != nil {
 		return err
 	}
-	fmt.Printf("form created: %v\n", form)
-	return nil
+	doc := newFormDocument(form, nil)
+	return out.print(doc, func(w io.Writer) {
+		fmt.Fprintf(w, "form '%s' created\n\n", form.Name)
+		printForm(w, doc)
+	})
 }
-func delete(env *formly.Env, formID, labelID int64) error {
+func delete(env *formly.Env, out output, form formly.Form, labels []formly.Label, labelID int64) error {
 	if labelID != -1 {
 		label, err := env.LabelModel.DeleteByID(labelID)
 		if err != nil {
 			return err
 		}
-		fmt.Printf("delete label: %v\n", label)
-		return nil
+		doc := newLabelDocument(label)
+		return out.print(doc, func(w io.Writer) {
+			fmt.Fprintf(w, "label '%s' deleted from form '%s'\n\n", label.Name, form.Name)
+			printLabels(w, []labelDocument{doc})
+		})
 	}
-	form, err := env.FormModel.DeleteByID(formID)
+	form, err := env.FormModel.DeleteByID(form.ID)
 	if err != nil {
 		return err
 	}
-	fmt.Printf("deleted form: %v\n", form)
-	return nil
+	doc := newFormDocument(form, labels)
+	return out.print(doc, func(w io.Writer) {
+		fmt.Fprintf(w, "form '%s' deleted\n\n", form.Name)
+		printForm(w, doc)
+	})
 }
-func label(env *formly.Env, formID, position int64, repeatable bool, name, usage string) error {
+func checkLabelName(name string) error {
 	if name == "h" || name == "-h" || strings.Contains(name, "help") {
 		return errors.New("label name cannot be 'h' or or '-h' or contain 'help'")
 	}
-	label, err := env.LabelModel.Create(formID, position, repeatable, name, usage)
-	if err != nil {
+	return formly.ValidateLabelName(name)
+}
+func label(env *formly.Env, out output, newLabel formly.Label) error {
+	if err := checkLabelName(newLabel.Name); err != nil {
 		return err
 	}
-	fmt.Printf("label created: %v\n", label)
-	return nil
-}
-func submissions(env *formly.Env, formID int64) error {
-	submissions, err := env.SubmissionModel.GetSubmissions(formID)
+	label, err := env.LabelModel.Create(newLabel)
 	if err != nil {
 		return err
 	}
-	labels, err := env.LabelModel.GetLabels(formID)
+	doc := newLabelDocument(label)
+	return out.print(doc, func(w io.Writer) {
+		fmt.Fprintf(w, "label '%s' created\n\n", label.Name)
+		printLabels(w, []labelDocument{doc})
+	})
+}
+func submissionQuery(formID int64, where, since, until string, limit int) (formly.SubmissionQuery, error) {
+	query := formly.SubmissionQuery{FormID: formID, Limit: limit}
+	if where != "" {
+		condition, err := formly.ParseCondition(where)
+		if err != nil {
+			return query, err
+		}
+		query.Where = &condition
+	}
+	var err error
+	if query.Since, err = parseTime(since, false); err != nil {
+		return query, err
+	}
+	query.Until, err = parseTime(until, true)
+	return query, err
+}
+func submissions(env *formly.Env, out output, form formly.Form, query formly.SubmissionQuery) error {
+	submissions, err := env.SubmissionModel.Query(query)
 	if err != nil {
 		return err
 	}
-	if len(submissions) == 0 {
+	if len(submissions) == 0 && !out.structured() {
+		if query.Where != nil || !query.Since.IsZero() || !query.Until.IsZero() {
+			fmt.Println("no submission matches")
+			return nil
+		}
 		fmt.Println("no submission for this form yet")
 		return nil
 	}
+	// every submission is shown with the labels of the version it was made
+	// with, so entries of since deleted or renamed labels still show up
+	doc := submissionsDocument{Form: form.Name, Submissions: []submissionDocument{}}
+	versions := map[int64]formly.FormVersion{}
 	for _, submission := range submissions {
-		fmt.Printf("submission:%v\n", submission.CreateAt)
-		for _, label := range labels {
-			entries, err := env.GetEntries(submission.ID, label.ID)
+		version, ok := versions[submission.Version]
+		if !ok {
+			version, err = env.FormModel.GetVersion(form.ID, submission.Version)
 			if err != nil {
 				return err
 			}
-			if len(entries) == 0 {
-				continue
-			}
-			for _, entry := range entries {
-				fmt.Printf("\t%s: %s \n", label.Name, entry.Txt)
+			versions[submission.Version] = version
+		}
+		for _, label := range version.Labels {
+			entries, err := env.GetEntries(submission.ID, label.ID)
+			if err != nil {
+				return err
 			}
+			submission.Entries = append(submission.Entries, entries...)
 		}
+		doc.Submissions = append(doc.Submissions, newSubmissionDocument(form.Name, submission, version.Labels))
 	}
-	return nil
+	return out.print(doc, func(w io.Writer) {
+		printSubmissions(w, doc.Submissions)
+	})
 }
-func modify(env *formly.Env, formID int64, newName, newUsage string) error {
-	form, err := env.FormModel.Update(formID, newName, newUsage)
+
+// changeSubmission edits or deletes a submission of form, or lists its
+// changes, as told by args.
+func changeSubmission(env *formly.Env, out output, form formly.Form, args []string, usage func()) error {
+	if len(args) < 2 || (args[0] != "edit" && args[0] != "delete" && args[0] != "changes") {
+		usage()
+		return errors.New("fatal: Must specify edit, delete or changes and a submission id")
+	}
+	id, err := strconv.ParseInt(args[1], 10, 64)
 	if err != nil {
-		return err
+		return fmt.Errorf("submission id '%s' is not a number", args[1])
 	}
-	fmt.Printf("updated form: %v\n", form)
-	return nil
-}
-func modifylabel(env *formly.Env, formID, labelID, position int64, repeatable bool, newName, newUsage string) error {
-	if newName == "h" || newName == "-h" || strings.Contains(newName, "help") {
-		return errors.New("label name cannot be 'h' or or '-h' or contain 'help'")
+	submission, err := env.SubmissionModel.GetByID(id)
+	if err == nil && submission.FormID != form.ID {
+		err = sql.ErrNoRows
+	}
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("submission %v of form '%s' not found", id, form.Name)
 	}
-	labels, err := env.LabelModel.Update(formID, labelID, position, repeatable, newName, newUsage)
 	if err != nil {
 		return err
 	}
-	fmt.Printf("updated label(s): %v\n", labels)
-	return nil
+	version, err := env.FormModel.GetVersion(form.ID, submission.Version)
+	if err != nil {
+		return err
+	}
+	switch args[0] {
+	case "edit":
+		fs := flag.NewFlagSet("edit", flag.ExitOnError)
+		fs.Usage = usage
+		flags := make([]formFlag, len(version.Labels))
+		for i, label := range version.Labels {
+			flags[i] = formFlag{label: label, name: label.Name}
+			fs.Var(&flags[i], label.Name, label.Usage)
+		}
+		editor := new(bool)
+		if fs.Lookup("editor") == nil {
+			editor = fs.Bool("editor", false, "edit the submission in $VISUAL or $EDITOR")
+		}
+		fs.Parse(args[2:])
+		// only the labels passed are changed, an empty value clears a label
+		values := map[int64][]string{}
+		fs.Visit(func(f *flag.Flag) {
+			if fflag, ok := f.Value.(*formFlag); ok {
+				values[fflag.label.ID] = append([]string{}, fflag.values...)
+			}
+		})
+		if *editor {
+			if len(values) != 0 {
+				return errors.New("--editor can not be combined with label flags")
+			}
+			current := map[int64][]string{}
+			for _, entry := range submission.Entries {
+				current[entry.LabelID] = append(current[entry.LabelID], entry.Txt)
+			}
+			if values, err = editValues(form, version.Labels, current, func(values map[int64][]string) error {
+				_, err := formly.ValidateEdit(version.Labels, current, values)
+				return err
+			}); err != nil {
+				return err
+			}
+		}
+		if len(values) == 0 {
+			return errors.New("nothing to edit, pass the labels to change as flags")
+		}
+		if submission, err = env.SubmissionModel.Edit(id, values); err != nil {
+			return err
+		}
+		doc := newSubmissionDocument(form.Name, submission, version.Labels)
+		return out.print(doc, func(w io.Writer) {
+			fmt.Fprintf(w, "submission %v updated\n\n", id)
+			printSubmissions(w, []submissionDocument{doc})
+		})
+	case "delete":
+		if submission, err = env.SubmissionModel.DeleteByID(id); err != nil {
+			return err
+		}
+		doc := newSubmissionDocument(form.Name, submission, version.Labels)
+		return out.print(doc, func(w io.Writer) {
+			fmt.Fprintf(w, "submission %v deleted\n\n", id)
+			printSubmissions(w, []submissionDocument{doc})
+		})
+	}
+	changes, err := env.SubmissionModel.GetChanges(id)
+	if err != nil {
+		return err
+	}
+	names := map[int64]string{}
+	for _, label := range version.Labels {
+		names[label.ID] = label.Name
+	}
+	doc := changesDocument{SubmissionID: id, Changes: []submissionChangeDocument{}}
+	for _, change := range changes {
+		doc.Changes = append(doc.Changes, submissionChangeDocument{
+			ID:        change.ID,
+			LabelID:   change.LabelID,
+			Label:     names[change.LabelID],
+			ChangedAt: change.ChangedAt,
+			From:      change.From,
+			To:        change.To,
+		})
+	}
+	if len(doc.Changes) == 0 && !out.structured() {
+		fmt.Printf("submission %v was not changed since it was made\n", id)
+		return nil
+	}
+	return out.print(doc, func(w io.Writer) {
+		fmt.Fprintln(w, "CHANGED\tLABEL\tFROM\tTO")
+		for _, change := range doc.Changes {
+			fmt.Fprintf(
+				w, "%s\t%s\t%s\t%s\n",
+				change.ChangedAt.Format(tableTimeLayout), change.Label,
+				tableText(strings.Join(change.From, ", ")), tableText(strings.Join(change.To, ", ")),
+			)
+		}
+	})
+}
+func search(env *formly.Env, out output, query, form, label string) error {
+	if form != "" {
+		if _, err := env.FormModel.GetByName(form); err != nil {
+			return fmt.Errorf("form '%s' not found", form)
+		}
+	}
+	options := formly.SearchOptions{Form: form, Label: label}
+	if !out.structured() && colorOutput() {
+		options.MarkStart, options.MarkEnd = "\x1b[1m", "\x1b[0m"
+	}
+	results, err := env.Search(query, options)
+	if err != nil {
+		return err
+	}
+	if len(results) == 0 && !out.structured() {
+		fmt.Println("no entries match")
+		return nil
+	}
+	doc := searchDocument{Query: query, Results: []searchResultDocument{}}
+	for _, result := range results {
+		doc.Results = append(doc.Results, searchResultDocument{
+			SubmissionID: result.SubmissionID,
+			EntryID:      result.ID,
+			Form:         result.Form,
+			Label:        result.Label,
+			CreatedAt:    result.CreateAt,
+			Value:        result.Txt,
+			Highlight:    result.Highlight,
+		})
+	}
+	return out.print(doc, func(w io.Writer) {
+		fmt.Fprintln(w, "CREATED\tFORM\tSUBMISSION\tLABEL\tMATCH")
+		for _, result := range doc.Results {
+			fmt.Fprintf(
+				w, "%s\t%s\t%v\t%s\t%s\n",
+				result.CreatedAt.Format(tableTimeLayout), result.Form, result.SubmissionID, result.Label, tableText(result.Highlight),
+			)
+		}
+	})
+}
+
+// hook adds, lists or deletes the hooks of a form as told by args.
+func hook(env *formly.Env, out output, cmd *flag.FlagSet, args []string) error {
+	if len(args) < 2 || (args[0] != "add" && args[0] != "list" && args[0] != "delete") {
+		cmd.Usage()
+		return errors.New("fatal: Must specify add, list or delete and a form name")
+	}
+	form, err := env.FormModel.GetByName(args[1])
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("form '%s' not found", args[1])
+	}
+	if err != nil {
+		return err
+	}
+	switch args[0] {
+	case "add":
+		pre := cmd.Bool("pre", false, "run the command before the submission is stored")
+		timeout := cmd.Duration("timeout", formly.DefaultHookTimeout, "time the command gets to run")
+		onFailure := cmd.String("on-failure", "", "abort or ignore when the command fails")
+		// the flags may come before or after the command
+		command := []string{}
+		for args = args[2:]; ; args = cmd.Args()[1:] {
+			cmd.Parse(args)
+			if cmd.NArg() == 0 {
+				break
+			}
+			command = append(command, cmd.Arg(0))
+		}
+		event := formly.PostSubmit
+		if *pre {
+			event = formly.PreSubmit
+		}
+		h, err := env.HookModel.Create(formly.Hook{
+			FormID:    form.ID,
+			Event:     event,
+			Command:   strings.Join(command, " "),
+			Timeout:   *timeout,
+			OnFailure: formly.HookFailure(*onFailure),
+		})
+		if err != nil {
+			return err
+		}
+		doc := newHookDocument(form, h)
+		return out.print(doc, func(w io.Writer) {
+			fmt.Fprintf(w, "hook %v added to form '%s'\n\n", h.ID, form.Name)
+			printHooks(w, []hookDocument{doc})
+		})
+	case "delete":
+		if len(args) < 3 {
+			cmd.Usage()
+			return errors.New("fatal: Must specify a hook id")
+		}
+		id, err := strconv.ParseInt(args[2], 10, 64)
+		if err != nil {
+			return fmt.Errorf("hook id '%s' is not a number", args[2])
+		}
+		hooks, err := env.HookModel.GetHooks(form.ID)
+		if err != nil {
+			return err
+		}
+		for _, h := range hooks {
+			if h.ID != id {
+				continue
+			}
+			if h, err = env.HookModel.DeleteByID(id); err != nil {
+				return err
+			}
+			doc := newHookDocument(form, h)
+			return out.print(doc, func(w io.Writer) {
+				fmt.Fprintf(w, "hook %v deleted from form '%s'\n\n", h.ID, form.Name)
+				printHooks(w, []hookDocument{doc})
+			})
+		}
+		return fmt.Errorf("hook %v of form '%s' not found", id, form.Name)
+	}
+	hooks, err := env.HookModel.GetHooks(form.ID)
+	if err != nil {
+		return err
+	}
+	doc := hooksDocument{Form: form.Name, Hooks: []hookDocument{}}
+	for _, h := range hooks {
+		doc.Hooks = append(doc.Hooks, newHookDocument(form, h))
+	}
+	return out.print(doc, func(w io.Writer) {
+		if len(doc.Hooks) == 0 {
+			fmt.Fprintf(w, "form '%s' has no hooks\n", form.Name)
+			return
+		}
+		printHooks(w, doc.Hooks)
+	})
+}
+
+func webhooks(env *formly.Env, out output, cmd *flag.FlagSet, args []string) error {
+	if len(args) != 0 && args[0] == "deliver" {
+		return deliverDue(env, out, cmd, args[1:])
+	}
+	if len(args) < 2 || (args[0] != "add" && args[0] != "list" && args[0] != "delete" && args[0] != "log") {
+		cmd.Usage()
+		return errors.New("fatal: Must specify add, list, delete or log and a form name, or deliver")
+	}
+	form, err := env.FormModel.GetByName(args[1])
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("form '%s' not found", args[1])
+	}
+	if err != nil {
+		return err
+	}
+	switch args[0] {
+	case "add":
+		secret := cmd.String("secret", "", "key of the signatures, a random one when empty")
+		// the flag may come before or after the url
+		urls := []string{}
+		for cmd.Parse(args[2:]); cmd.NArg() != 0; cmd.Parse(cmd.Args()[1:]) {
+			urls = append(urls, cmd.Arg(0))
+		}
+		if len(urls) != 1 {
+			cmd.Usage()
+			return errors.New("fatal: Must specify a url")
+		}
+		webhook, err := env.WebhookModel.Create(formly.Webhook{FormID: form.ID, URL: urls[0], Secret: *secret})
+		if err != nil {
+			return err
+		}
+		doc := newWebhookDocument(form, webhook)
+		return out.print(doc, func(w io.Writer) {
+			fmt.Fprintf(w, "webhook %v added to form '%s'\n\n", webhook.ID, form.Name)
+			printWebhooks(w, []webhookDocument{doc})
+		})
+	case "delete":
+		if len(args) < 3 {
+			cmd.Usage()
+			return errors.New("fatal: Must specify a webhook id")
+		}
+		id, err := strconv.ParseInt(args[2], 10, 64)
+		if err != nil {
+			return fmt.Errorf("webhook id '%s' is not a number", args[2])
+		}
+		webhook, err := env.WebhookModel.GetByID(id)
+		if err == sql.ErrNoRows || (err == nil && webhook.FormID != form.ID) {
+			return fmt.Errorf("webhook %v of form '%s' not found", id, form.Name)
+		}
+		if err != nil {
+			return err
+		}
+		if webhook, err = env.WebhookModel.DeleteByID(id); err != nil {
+			return err
+		}
+		doc := newWebhookDocument(form, webhook)
+		return out.print(doc, func(w io.Writer) {
+			fmt.Fprintf(w, "webhook %v deleted from form '%s' along with its deliveries\n\n", webhook.ID, form.Name)
+			printWebhooks(w, []webhookDocument{doc})
+		})
+	case "log":
+		limit := cmd.Int("limit", 20, "number of deliveries to show, newest first, 0 shows all")
+		cmd.Parse(args[2:])
+		webhooks, err := env.WebhookModel.GetWebhooks(form.ID)
+		if err != nil {
+			return err
+		}
+		urls := map[int64]string{}
+		for _, webhook := range webhooks {
+			urls[webhook.ID] = webhook.URL
+		}
+		deliveries, err := env.WebhookModel.GetDeliveries(form.ID)
+		if err != nil {
+			return err
+		}
+		if *limit > 0 && len(deliveries) > *limit {
+			deliveries = deliveries[:*limit]
+		}
+		doc := deliveriesDocument{Form: form.Name, Deliveries: []deliveryDocument{}}
+		for _, delivery := range deliveries {
+			doc.Deliveries = append(doc.Deliveries, newDeliveryDocument(delivery, urls[delivery.WebhookID]))
+		}
+		return out.print(doc, func(w io.Writer) {
+			if len(doc.Deliveries) == 0 {
+				fmt.Fprintf(w, "form '%s' has no deliveries\n", form.Name)
+				return
+			}
+			printDeliveries(w, doc.Deliveries)
+		})
+	}
+	webhooks, err := env.WebhookModel.GetWebhooks(form.ID)
+	if err != nil {
+		return err
+	}
+	doc := webhooksDocument{Form: form.Name, Webhooks: []webhookDocument{}}
+	for _, webhook := range webhooks {
+		doc.Webhooks = append(doc.Webhooks, newWebhookDocument(form, webhook))
+	}
+	return out.print(doc, func(w io.Writer) {
+		if len(doc.Webhooks) == 0 {
+			fmt.Fprintf(w, "form '%s' has no webhooks\n", form.Name)
+			return
+		}
+		printWebhooks(w, doc.Webhooks)
+	})
+}
+
+// deliverDue sends the deliveries that are due, up to timeout. The ones it
+// gets no further to are left for the next run.
+func deliverDue(env *formly.Env, out output, cmd *flag.FlagSet, args []string) error {
+	fs := flag.NewFlagSet("webhooks deliver", flag.ExitOnError)
+	fs.Usage = cmd.Usage
+	timeout := fs.Duration("timeout", 30*time.Second, "how long to keep delivering")
+	fs.Parse(args)
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return fmt.Errorf("webhooks deliver takes no arguments, got '%s'", strings.Join(fs.Args(), " "))
+	}
+	if *timeout <= 0 {
+		return errors.New("--timeout has to be positive")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	deliveries, err := env.DeliverWebhooksContext(ctx, nil, time.Now())
+	if errors.Is(err, context.DeadlineExceeded) {
+		fmt.Fprintf(os.Stderr, "warning: stopped delivering after %v, the deliveries left are sent by the next run\n", *timeout)
+	} else if err != nil {
+		return err
+	}
+	doc := deliveredDocument{Deliveries: []deliveryDocument{}}
+	for _, delivery := range deliveries {
+		url := ""
+		if webhook, err := env.WebhookModel.GetByID(delivery.WebhookID); err == nil {
+			url = webhook.URL
+		}
+		doc.Deliveries = append(doc.Deliveries, newDeliveryDocument(delivery, url))
+	}
+	return out.print(doc, func(w io.Writer) {
+		if len(doc.Deliveries) == 0 {
+			fmt.Fprintln(w, "no deliveries are due")
+			return
+		}
+		printDeliveries(w, doc.Deliveries)
+	})
+}
+
+// serve runs the rest api until it fails.
+func serve(env *formly.Env, addr, token string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("serving the api on http://%s\n", listener.Addr())
+	fmt.Printf("forms can be filled in at http://%s/ui\n", listener.Addr())
+	if token == "" {
+		fmt.Println("no --token or $FORMLY_TOKEN given, requests are not authenticated")
+	}
+	go func() {
+		for {
+			deliverWebhooks(env)
+			time.Sleep(webhookInterval)
+		}
+	}()
+	srv := &http.Server{Handler: server.NewHandler(env, token), ReadHeaderTimeout: 10 * time.Second}
+	return srv.Serve(listener)
+}
+
+// webhookInterval is how often form serve looks for deliveries that are
+// due.
+const webhookInterval = time.Second
+
+// deliverWebhooks sends the deliveries that are due, the ones that fail
+// are retried later and show up in form webhooks log.
+func deliverWebhooks(env *formly.Env) {
+	if _, err := env.DeliverWebhooks(nil, time.Now()); err != nil {
+		fmt.Fprintln(os.Stderr, "warning: delivering webhooks:", err)
+	}
+}
+
+// drainTimeout bounds how long a run of form spends sending the deliveries
+// that are due.
+const drainTimeout = 2 * time.Second
+
+// drainWebhooks sends the deliveries that are due for up to drainTimeout,
+// the ones it does not get to are left for the next run.
+func drainWebhooks(env *formly.Env) {
+	ctx, cancel := context.WithTimeout(context.Background(), drainTimeout)
+	defer cancel()
+	if _, err := env.DeliverWebhooksContext(ctx, nil, time.Now()); err != nil && !errors.Is(err, context.DeadlineExceeded) {
+		fmt.Fprintln(os.Stderr, "warning: delivering webhooks:", err)
+	}
+}
+
+func stats(env *formly.Env, out output, query formly.SubmissionQuery, period formly.StatsPeriod) error {
+	stats, err := formly.NewFormStats(env, query, period)
+	if err != nil {
+		return err
+	}
+	doc := newStatsDocument(stats)
+	return out.print(doc, func(w io.Writer) {
+		printStats(w, stats, doc)
+	})
+}
+func chart(
+	env *formly.Env, out output, form formly.Form, labels []formly.Label, labelName, kind string,
+	bucket formly.StatsPeriod, query formly.SubmissionQuery,
+) error {
+	var label *formly.Label
+	for i := range labels {
+		if labels[i].Name == labelName {
+			label = &labels[i]
+		}
+	}
+	if labelName != "" && label == nil {
+		return fmt.Errorf("label '%s' not found in form '%s'", labelName, form.Name)
+	}
+	numeric := label != nil && (label.Type == formly.IntLabel || label.Type == formly.FloatLabel)
+	if kind == "" {
+		kind = "heatmap"
+		if numeric {
+			kind = "line"
+		} else if label != nil {
+			kind = "bar"
+		}
+	}
+	var labelID int64
+	if label != nil {
+		labelID = label.ID
+	}
+	switch kind {
+	case "line":
+		if label != nil && !numeric {
+			return fmt.Errorf("label '%s' holds no numbers, chart it with --kind bar", label.Name)
+		}
+	case "bar":
+		if label == nil {
+			return errors.New("bar charts count the values of a label, name one")
+		}
+	case "heatmap":
+		if label != nil {
+			return errors.New("heatmaps count submissions, pick them with --where instead of a label")
+		}
+		// the heatmap shows the last year unless told otherwise
+		if query.Until.IsZero() {
+			now := time.Now().UTC()
+			query.Until = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC).AddDate(0, 0, 1)
+		}
+		if query.Since.IsZero() {
+			query.Since = query.Until.AddDate(0, 0, -heatmapDays)
+		}
+		bucket = formly.DayPeriod
+	default:
+		return fmt.Errorf("unknown chart '%s', expected line, bar or heatmap", kind)
+	}
+	series, err := formly.NewSeries(env, query, labelID, bucket)
+	if err != nil {
+		return err
+	}
+	doc := chartDocument{Form: form.Name, Label: labelName, Kind: kind, Bucket: string(bucket), Points: []chartPointDocument{}}
+	for _, point := range series {
+		doc.Points = append(doc.Points, chartPointDocument{
+			Start:       point.Start.Format(formly.DateLayout),
+			Submissions: point.Submissions,
+			Values:      point.Values,
+		})
+	}
+	return out.print(doc, func(w io.Writer) {
+		if kind == "heatmap" {
+			counts := map[time.Time]int{}
+			for _, point := range series {
+				counts[point.Start] = point.Submissions
+			}
+			heatmap(w, counts, query.Since, query.Until.Add(-time.Nanosecond))
+			return
+		}
+		if len(series) == 0 {
+			fmt.Fprintln(w, "no submission to chart")
+			return
+		}
+		if kind == "bar" {
+			values := []string{}
+			for _, point := range series {
+				values = append(values, point.Values...)
+			}
+			barChart(w, valueBars(*label, values))
+			return
+		}
+		points := []linePoint{}
+		for _, point := range series {
+			value := float64(point.Submissions)
+			points = append(points, linePoint{start: point.Start, value: &value})
+			if label == nil {
+				continue
+			}
+			points[len(points)-1].value = meanValue(point.Values)
+		}
+		lineChart(w, points, bucket)
+	})
+}
+func meanValue(values []string) *float64 {
+	sum, n := 0.0, 0
+	for _, value := range values {
+		if number, err := strconv.ParseFloat(value, 64); err == nil {
+			sum += number
+			n++
+		}
+	}
+	if n == 0 {
+		return nil
+	}
+	mean := sum / float64(n)
+	return &mean
+}
+func history(env *formly.Env, out output, form formly.Form) error {
+	versions, err := env.FormModel.GetVersions(form.ID)
+	if err != nil {
+		return err
+	}
+	submissions, err := env.SubmissionModel.GetSubmissions(form.ID)
+	if err != nil {
+		return err
+	}
+	counts := map[int64]int{}
+	for _, submission := range submissions {
+		counts[submission.Version]++
+	}
+	doc := historyDocument{Form: form.Name, Versions: []versionDocument{}}
+	for _, version := range versions {
+		doc.Versions = append(doc.Versions, versionDocument{
+			Version:     version.Version,
+			CreatedAt:   version.CreatedAt,
+			Submissions: counts[version.Version],
+			Labels:      newLabelDocuments(version.Labels),
+		})
+	}
+	return out.print(doc, func(w io.Writer) {
+		fmt.Fprintln(w, "VERSION\tCREATED\tSUBMISSIONS\tLABELS")
+		for _, version := range doc.Versions {
+			names := []string{}
+			for _, label := range version.Labels {
+				names = append(names, fmt.Sprintf("%s(%s)", label.Name, label.Type))
+			}
+			fmt.Fprintf(
+				w, "%v\t%s\t%v\t%s\n",
+				version.Version, version.CreatedAt.Format(tableTimeLayout), version.Submissions, strings.Join(names, ", "),
+			)
+		}
+	})
+}
+func export(env *formly.Env, formID int64, format, since, until string, expand bool) error {
+	exporter, err := formly.GetExporter(format)
+	if err != nil {
+		return err
+	}
+	query, err := submissionQuery(formID, "", since, until, 0)
+	if err != nil {
+		return err
+	}
+	table, err := formly.NewExportTable(env, query, formly.ExportOptions{Expand: expand})
+	if err != nil {
+		return err
+	}
+	return exporter.Export(os.Stdout, table)
+}
+
+// importSubmissions imports the file at path, unless a row of it is invalid
+// or dryRun is set, and reports whether every row was valid. The rows go
+// through the form's hooks like submit when hooks is set.
+func importSubmissions(
+	env *formly.Env, out output, form formly.Form, labels []formly.Label, path, format string, dryRun, hooks bool,
+) (bool, error) {
+	if format == "" {
+		format = formly.ImportFormat(path)
+	}
+	r := io.Reader(os.Stdin)
+	if path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return false, err
+		}
+		defer file.Close()
+		r = file
+	}
+	rows, err := formly.ParseImport(r, format, labels)
+	if err != nil {
+		return false, err
+	}
+	doc := importDocument{Form: form.Name, Rows: len(rows), DryRun: dryRun, Errors: []importErrorDocument{}}
+	submissions := []formly.NewSubmission{}
+	for _, row := range rows {
+		err := row.Err
+		if err == nil {
+			_, err = formly.ValidateSubmission(labels, row.Submission.Values)
+		}
+		if err != nil {
+			doc.Errors = append(doc.Errors, importErrorDocument{Line: row.Line, Error: err.Error()})
+			continue
+		}
+		submissions = append(submissions, row.Submission)
+	}
+	// a failing post-submit hook set to abort still leaves the submissions
+	var hookErr error
+	if len(doc.Errors) == 0 && !dryRun {
+		var imported []formly.Submission
+		var err error
+		if hooks {
+			var ignored []error
+			imported, ignored, err = env.SubmitAllWithHooks(form.ID, submissions)
+			for _, ignoredErr := range ignored {
+				fmt.Fprintln(os.Stderr, "warning:", ignoredErr)
+			}
+		} else {
+			for i := range submissions {
+				submissions[i].SkipWebhooks = true
+			}
+			imported, err = env.SubmitAll(form.ID, submissions)
+		}
+		if len(imported) == 0 && err != nil {
+			return false, err
+		}
+		doc.Imported, hookErr = len(imported), err
+	}
+	printErr := out.print(doc, func(w io.Writer) {
+		for _, rowErr := range doc.Errors {
+			fmt.Fprintf(w, "line %v:\t%s\n", rowErr.Line, rowErr.Error)
+		}
+		switch {
+		case len(doc.Errors) != 0:
+			fmt.Fprintf(w, "%v of %v rows are invalid, nothing was imported\n", len(doc.Errors), doc.Rows)
+		case dryRun:
+			fmt.Fprintf(w, "all %v rows are valid, nothing was imported as this is a dry run\n", doc.Rows)
+		default:
+			fmt.Fprintf(w, "imported %v submissions into form '%s'\n", doc.Imported, form.Name)
+		}
+	})
+	if printErr != nil {
+		return false, printErr
+	}
+	return len(doc.Errors) == 0, hookErr
+}
+
+// parseTime reads a date or an RFC 3339 time. A date ending a range stands
+// for the end of that day.
+func parseTime(txt string, end bool) (time.Time, error) {
+	if txt == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, txt); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(formly.DateLayout, txt)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("'%s' is neither a YYYY-MM-DD date nor an RFC 3339 time", txt)
+	}
+	if end {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
+
+// modify saves the form along with its labels in one go, so a label that can
+// not be saved leaves the form's name and usage as they were.
+func modify(env *formly.Env, form formly.Form, labels []formly.Label) error {
+	_, _, err := env.FormModel.Save(form, labels)
+	return err
+}
+
+// modified prints the form as it is after modify and modifylabel.
+func modified(env *formly.Env, out output, formID int64) error {
+	form, err := env.FormModel.GetByID(formID)
+	if err != nil {
+		return err
+	}
+	labels, err := env.LabelModel.GetLabels(formID)
+	if err != nil {
+		return err
+	}
+	doc := newFormDocument(form, labels)
+	return out.print(doc, func(w io.Writer) {
+		fmt.Fprintf(w, "form '%s' updated\n\n", form.Name)
+		printForm(w, doc)
+	})
+}
+
+// modifylabel returns labels with the one at found replaced by label, which
+// swaps places with the label at its new position.
+func modifylabel(labels []formly.Label, found int, label formly.Label) ([]formly.Label, error) {
+	if err := checkLabelName(label.Name); err != nil {
+		return nil, err
+	}
+	if int(label.Position) > len(labels) || int(label.Position) < 1 {
+		return nil, fmt.Errorf("position has to be in range between: %v - %v", 1, len(labels))
+	}
+	for i, l := range labels {
+		if i != found && l.Name == label.Name {
+			return nil, fmt.Errorf("suggested new label name '%s' already exists", label.Name)
+		}
+	}
+	modified := append([]formly.Label{}, labels...)
+	modified[found] = label
+	to := int(label.Position) - 1
+	modified[found], modified[to] = modified[to], modified[found]
+	return modified, nil
+}
+func readDefinition(path string) (formly.Definition, error) {
+	if path == "-" {
+		return formly.ParseDefinition(os.Stdin, "yaml")
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return formly.Definition{}, err
+	}
+	defer file.Close()
+	return formly.ParseDefinition(file, formly.DefinitionFormat(path))
+}
+func apply(env *formly.Env, out output, path string) error {
+	def, err := readDefinition(path)
+	if err != nil {
+		return err
+	}
+	for _, label := range def.Labels {
+		if err := checkLabelName(label.Name); err != nil {
+			return err
+		}
+	}
+	form, err := formly.Apply(env, def)
+	if err != nil {
+		return err
+	}
+	labels, err := env.LabelModel.GetLabels(form.ID)
+	if err != nil {
+		return err
+	}
+	doc := newFormDocument(form, labels)
+	return out.print(doc, func(w io.Writer) {
+		fmt.Fprintf(w, "form '%s' applied\n\n", form.Name)
+		printForm(w, doc)
+	})
+}
+func plan(env *formly.Env, out output, path string) (bool, error) {
+	def, err := readDefinition(path)
+	if err != nil {
+		return false, err
+	}
+	plan, err := formly.MakePlan(env, def)
+	if err != nil {
+		return false, err
+	}
+	if out.structured() {
+		doc := planDocument{Form: plan.Form, Changes: []changeDocument{}}
+		for _, change := range plan.Changes {
+			doc.Changes = append(doc.Changes, changeDocument{
+				Kind:    string(change.Kind),
+				Label:   change.Label,
+				Field:   change.Field,
+				From:    change.From,
+				To:      change.To,
+				Entries: change.Entries,
+			})
+		}
+		return plan.HasChanges(), out.print(doc, nil)
+	}
+	if !plan.HasChanges() {
+		fmt.Printf("form '%s' matches the definition, no changes\n", plan.Form)
+		return false, nil
+	}
+	fmt.Printf("form '%s':\n", plan.Form)
+	added, changed, removed := 0, 0, 0
+	for _, change := range plan.Changes {
+		switch change.Kind {
+		case formly.CreateForm:
+			fmt.Printf("  + form '%s': %s\n", plan.Form, change.To)
+			added++
+		case formly.UpdateForm:
+			fmt.Printf("  ~ form %s: '%s' -> '%s'\n", change.Field, change.From, change.To)
+			changed++
+		case formly.AddLabel:
+			fmt.Printf("  + label '%s' (%s)\n", change.Label, change.To)
+			added++
+		case formly.RemoveLabel:
+			fmt.Printf("  - label '%s' (%v entries are kept with earlier versions)\n", change.Label, change.Entries)
+			removed++
+		case formly.RenameLabel:
+			fmt.Printf("  ~ label '%s' renamed to '%s'\n", change.From, change.To)
+			changed++
+		case formly.MoveLabel:
+			fmt.Printf("  ~ label '%s' moved from %s to %s\n", change.Label, change.From, change.To)
+			changed++
+		case formly.UpdateLabel:
+			fmt.Printf("  ~ label '%s' %s: '%s' -> '%s'\n", change.Label, change.Field, change.From, change.To)
+			changed++
+		}
+	}
+	fmt.Printf("plan: %v to add, %v to change, %v to remove\n", added, changed, removed)
+	return true, nil
+}
+func exportSchema(env *formly.Env, name, format string) error {
+	def, err := formly.ExportDefinition(env, name)
+	if err != nil {
+		return err
+	}
+	return formly.WriteDefinition(os.Stdout, def, format)
+}
+
+// choiceFlag is --choice, which takes a choice each time it is given, as
+// typed, so choices can hold commas.
+type choiceFlag []string
+
+func (cflag *choiceFlag) String() string {
+	if cflag == nil {
+		return ""
+	}
+	return strings.Join(*cflag, ", ")
+}
+func (cflag *choiceFlag) Set(txt string) error {
+	*cflag = append(*cflag, txt)
+	return nil
+}
+
+// setupChoiceFlags adds --choice and --choices, a comma separated list, to
+// fs. The returned func gives the choices of both flags, or current when
+// neither was given.
+func setupChoiceFlags(fs *flag.FlagSet, current []string) func() []string {
+	choices := fs.String("choices", "", "comma separated choices for an enum label")
+	choice := &choiceFlag{}
+	fs.Var(choice, "choice", "a choice for an enum label, given once per choice, commas included")
+	return func() []string {
+		set := false
+		fs.Visit(func(f *flag.Flag) {
+			set = set || f.Name == "choices" || f.Name == "choice"
+		})
+		if !set {
+			return current
+		}
+		return append(splitChoices(*choices), *choice...)
+	}
+}
+func splitChoices(choices string) []string {
+	if choices == "" {
+		return nil
+	}
+	split := strings.Split(choices, ",")
+	for i := range split {
+		split[i] = strings.TrimSpace(split[i])
+	}
+	return split
+}
+func setupConstraintFlags(fs *flag.FlagSet, current formly.Constraints) func() (formly.Constraints, error) {
+	pattern := fs.String("pattern", current.Pattern, "regular expression entries have to match")
+	minLength := fs.Int64("min-length", current.MinLength, "minimum number of characters of an entry")
+	maxLength := fs.Int64("max-length", current.MaxLength, "maximum number of characters of an entry, 0 for no limit")
+	min := fs.String("min", formatBound(current.Min), "minimum value of an int or float entry")
+	max := fs.String("max", formatBound(current.Max), "maximum value of an int or float entry")
+	return func() (formly.Constraints, error) {
+		constraints := formly.Constraints{Pattern: *pattern, MinLength: *minLength, MaxLength: *maxLength}
+		var err error
+		if constraints.Min, err = parseBound(*min); err != nil {
+			return formly.Constraints{}, err
+		}
+		if constraints.Max, err = parseBound(*max); err != nil {
+			return formly.Constraints{}, err
+		}
+		return constraints, nil
+	}
+}
+func formatBound(bound *float64) string {
+	if bound == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*bound, 'g', -1, 64)
+}
+func parseBound(bound string) (*float64, error) {
+	if bound == "" {
+		return nil, nil
+	}
+	f, err := strconv.ParseFloat(bound, 64)
+	if err != nil {
+		return nil, fmt.Errorf("bound '%s' is not a number", bound)
+	}
+	return &f, nil
+}
+func promptHint(label formly.Label) string {
+	hints := []string{}
+	if label.Type != formly.TextLabel {
+		hints = append(hints, describeType(label))
+	}
+	if label.Required {
+		hints = append(hints, "required")
+	}
+	if label.Default != "" {
+		hints = append(hints, "default: "+label.Default)
+	}
+	if label.Repeatable {
+		hints = append(hints, "repeatable")
+	}
+	if len(hints) == 0 {
+		return ""
+	}
+	return " (" + strings.Join(hints, ", ") + ")"
+}
+func describeType(label formly.Label) string {
+	if label.Type == formly.EnumLabel {
+		return strings.Join(label.Choices, "|")
+	}
+	if label.Type == formly.DateLabel {
+		return "YYYY-MM-DD"
+	}
+	return string(label.Type)
 }
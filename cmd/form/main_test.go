@@ -0,0 +1,649 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// TestMain runs form instead of the tests when the test binary is started
+// by run, so tests see what form prints and how it exits.
+func TestMain(m *testing.M) {
+	if os.Getenv("FORMLY_TEST_MAIN") == "1" {
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+type result struct {
+	code           int
+	stdout, stderr string
+}
+
+// run runs form with args on the database at db, with stdin as its input.
+func run(t *testing.T, db, stdin string, args ...string) result {
+	t.Helper()
+	return runEnv(t, nil, stdin, append([]string{"--db", db}, args...)...)
+}
+
+// runEnv runs form with args and env added to its environment, with stdin
+// as its input.
+func runEnv(t *testing.T, env []string, stdin string, args ...string) result {
+	t.Helper()
+	cmd := exec.Command(os.Args[0], args...)
+	cmd.Env = append(append(os.Environ(), "FORMLY_TEST_MAIN=1", "EDITOR=false", "VISUAL="), env...)
+	cmd.Stdin = strings.NewReader(stdin)
+	stdout, stderr := bytes.Buffer{}, bytes.Buffer{}
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	err := cmd.Run()
+	exitErr := &exec.ExitError{}
+	if err != nil && !errors.As(err, &exitErr) {
+		t.Fatal(err)
+	}
+	return result{code: cmd.ProcessState.ExitCode(), stdout: stdout.String(), stderr: stderr.String()}
+}
+
+// submitted returns the entries of every submission of form on the
+// database at db, one "label: value" line per entry.
+func submitted(t *testing.T, db, form string) []string {
+	t.Helper()
+	r := run(t, db, "", "--output", "json", "submissions", form)
+	doc := struct {
+		Submissions []struct {
+			Entries []struct{ Label, Value string }
+		}
+	}{}
+	if err := json.Unmarshal([]byte(r.stdout), &doc); err != nil {
+		t.Fatalf("submissions = %+v: %v", r, err)
+	}
+	submissions := []string{}
+	for _, submission := range doc.Submissions {
+		entries := ""
+		for _, entry := range submission.Entries {
+			entries += entry.Label + ": " + entry.Value + "\n"
+		}
+		submissions = append(submissions, entries)
+	}
+	return submissions
+}
+
+// newDB returns the path of a database holding a standup form.
+func newDB(t *testing.T) string {
+	t.Helper()
+	db := filepath.Join(t.TempDir(), "formly.db")
+	for _, args := range [][]string{
+		{"create", "standup", "daily standup notes"},
+		{"label", "standup", "--repeatable", "done", "what you did yesterday"},
+		{"label", "standup", "--type", "int", "mood", "mood from one to ten"},
+	} {
+		if r := run(t, db, "", args...); r.code != 0 || !strings.Contains(r.stdout, "created") {
+			t.Fatalf("%v = %+v", args, r)
+		}
+	}
+	return db
+}
+
+func TestTypedLabels(t *testing.T) {
+	db := newDB(t)
+	for _, args := range [][]string{
+		{"label", "standup", "--type", "enum", "--choices", "home, office", "place", "where you worked"},
+		{"label", "standup", "--type", "bool", "blocked", "whether you are blocked"},
+		{"label", "standup", "--type", "date", "due", "when it is due"},
+	} {
+		if r := run(t, db, "", args...); !strings.Contains(r.stdout, "' created") {
+			t.Fatalf("%v = %+v", args, r)
+		}
+	}
+	for _, args := range [][]string{
+		{"label", "standup", "--type", "color", "hue", "color of the day"},
+		{"label", "standup", "--type", "enum", "where", "where you worked"},
+		{"label", "standup", "--choices", "a,b", "which", "which one it was"},
+	} {
+		if r := run(t, db, "", args...); strings.Contains(r.stdout, "' created") {
+			t.Errorf("%v creates a label: %+v", args, r)
+		}
+	}
+	if r := run(t, db, "", "submit", "standup",
+		"--mood", " 07", "--place", "OFFICE", "--blocked", "yes", "--due", "2021/03/04", "--done", "review",
+	); !strings.Contains(r.stdout, "submitted") {
+		t.Fatalf("submit = %+v", r)
+	}
+	got := submitted(t, db, "standup")
+	for _, want := range []string{"mood: 7\n", "place: office\n", "blocked: true\n", "due: 2021-03-04\n"} {
+		if !strings.Contains(got[0], want) {
+			t.Errorf("submission holds %q, want it to contain %q", got[0], want)
+		}
+	}
+	for _, args := range [][]string{
+		{"--mood", "seven"},
+		{"--place", "moon"},
+		{"--blocked", "maybe"},
+		{"--due", "tomorrow"},
+	} {
+		r := run(t, db, "", append([]string{"submit", "standup"}, args...)...)
+		if strings.Contains(r.stdout, "submitted") || !strings.Contains(r.stdout+r.stderr, "expects") {
+			t.Errorf("submit %v = %+v, want it rejected", args, r)
+		}
+	}
+	if got := submitted(t, db, "standup"); len(got) != 1 {
+		t.Errorf("submissions = %q, want only the valid submission", got)
+	}
+}
+
+func TestRequiredAndDefaults(t *testing.T) {
+	db := newDB(t)
+	for _, args := range [][]string{
+		{"modify", "standup", "mood", "--required"},
+		{"label", "standup", "--default", "office", "place", "where you worked"},
+	} {
+		if r := run(t, db, "", args...); !strings.Contains(r.stdout, "' updated") && !strings.Contains(r.stdout, "' created") {
+			t.Fatalf("%v = %+v", args, r)
+		}
+	}
+	if r := run(t, db, "", "label", "standup", "--type", "int", "--default", "ten", "hours", "hours worked"); strings.Contains(r.stdout, "' created") {
+		t.Errorf("label with a default that does not fit its type = %+v, want it rejected", r)
+	}
+	if r := run(t, db, "", "submit", "standup", "--done", "review"); strings.Contains(r.stdout, "submitted") ||
+		!strings.Contains(r.stdout+r.stderr, "label 'mood' is required") {
+		t.Errorf("submit without a required label = %+v", r)
+	}
+	r := run(t, db, "review\n\n\n7\n\n", "submit", "standup")
+	if !strings.Contains(r.stdout, "label 'mood' is required") {
+		t.Errorf("submit of an empty required label does not re-prompt: %+v", r)
+	}
+	got := submitted(t, db, "standup")
+	if want := "done: review\nmood: 7\nplace: office\n"; len(got) != 1 || got[0] != want {
+		t.Errorf("submissions = %q, want [%q]", got, want)
+	}
+}
+
+func TestConstraints(t *testing.T) {
+	db := newDB(t)
+	for _, args := range [][]string{
+		{"modify", "standup", "mood", "--min", "1", "--max", "10"},
+		{"label", "standup", "--pattern", "[A-Z]+-[0-9]+", "--max-length", "8", "ticket", "ticket worked on"},
+	} {
+		if r := run(t, db, "", args...); !strings.Contains(r.stdout, "' updated") && !strings.Contains(r.stdout, "' created") {
+			t.Fatalf("%v = %+v", args, r)
+		}
+	}
+	for _, args := range [][]string{
+		{"label", "standup", "--pattern", "[", "broken", "broken pattern"},
+		{"label", "standup", "--min-length", "5", "--max-length", "2", "short", "short text"},
+		{"label", "standup", "--min", "1", "note", "text with a range"},
+		{"label", "standup", "--type", "int", "--min", "5", "--max", "2", "hours", "hours worked"},
+	} {
+		if r := run(t, db, "", args...); strings.Contains(r.stdout, "' created") {
+			t.Errorf("%v creates a label: %+v", args, r)
+		}
+	}
+	if r := run(t, db, "", "submit", "standup", "--mood", "7", "--ticket", "AB-12"); !strings.Contains(r.stdout, "submitted") {
+		t.Errorf("submit within the constraints = %+v", r)
+	}
+	for _, args := range [][]string{
+		{"--mood", "0"},
+		{"--mood", "11"},
+		{"--ticket", "ab-12"},
+		{"--ticket", "ABCDEF-12"},
+	} {
+		r := run(t, db, "", append([]string{"submit", "standup"}, args...)...)
+		if strings.Contains(r.stdout, "submitted") || !strings.Contains(r.stdout+r.stderr, "expects") {
+			t.Errorf("submit %v = %+v, want it rejected", args, r)
+		}
+	}
+}
+
+const standupDefinition = `name: standup
+usage: daily standup notes
+labels:
+  - name: done
+    usage: what you did yesterday
+    repeatable: true
+  - name: mood
+    usage: mood from one to ten
+    type: int
+    required: true
+    min: 1
+    max: 10
+`
+
+func TestApplyExportSchema(t *testing.T) {
+	db := filepath.Join(t.TempDir(), "formly.db")
+	if r := run(t, db, standupDefinition, "apply", "-f", "-"); !strings.Contains(r.stdout, "' applied") {
+		t.Fatalf("apply = %+v", r)
+	}
+	exported := run(t, db, "", "export-schema", "standup")
+	if !strings.Contains(exported.stdout, "name: mood") || !strings.Contains(exported.stdout, "required: true") {
+		t.Fatalf("export-schema = %+v", exported)
+	}
+	otherDB := filepath.Join(t.TempDir(), "formly.db")
+	if r := run(t, otherDB, exported.stdout, "apply", "-f", "-"); !strings.Contains(r.stdout, "' applied") {
+		t.Fatalf("apply of an exported definition = %+v", r)
+	}
+	if r := run(t, otherDB, "", "export-schema", "standup"); r.stdout != exported.stdout {
+		t.Errorf("export-schema after applying its output = %q, want %q", r.stdout, exported.stdout)
+	}
+	if r := run(t, db, "", "export-schema", "standup", "--format", "json"); !strings.HasPrefix(r.stdout, "{") {
+		t.Errorf("export-schema with --format after the form name = %+v, want json", r)
+	}
+
+	if r := run(t, db, "", "submit", "standup", "--mood", "7", "--done", "review"); !strings.Contains(r.stdout, "submitted") {
+		t.Fatalf("submit = %+v", r)
+	}
+	renamed := strings.Replace(standupDefinition, "  - name: done\n", "  - name: did\n    renamed_from: done\n", 1)
+	if r := run(t, db, renamed, "apply", "-f", "-"); !strings.Contains(r.stdout, "' applied") {
+		t.Fatalf("apply of a renamed label = %+v", r)
+	}
+	if got := submitted(t, db, "standup"); len(got) != 1 || !strings.Contains(got[0], "done: review\n") {
+		t.Errorf("submissions after renaming a label = %q, want its entries kept under the old name", got)
+	}
+	if r := run(t, db, "labels: [", "apply", "-f", "-"); strings.Contains(r.stdout, "' applied") {
+		t.Errorf("apply of a broken definition = %+v", r)
+	}
+}
+
+func TestPlanExitCodes(t *testing.T) {
+	db := filepath.Join(t.TempDir(), "formly.db")
+	if r := run(t, db, standupDefinition, "plan", "-f", "-"); r.code != 2 || !strings.Contains(r.stdout, "+ form 'standup'") {
+		t.Errorf("plan of a new form = %+v, want it added with exit code 2", r)
+	}
+	if r := run(t, db, standupDefinition, "apply", "-f", "-"); !strings.Contains(r.stdout, "' applied") {
+		t.Fatalf("apply = %+v", r)
+	}
+	if r := run(t, db, "", "submit", "standup", "--mood", "7", "--done", "review"); !strings.Contains(r.stdout, "submitted") {
+		t.Fatalf("submit = %+v", r)
+	}
+	drifted := strings.Replace(standupDefinition, "max: 10", "max: 5", 1)
+	removed := standupDefinition[:strings.Index(standupDefinition, "  - name: mood")]
+	for _, tt := range []struct {
+		name, definition, output string
+		want                     int
+	}{
+		{"unchanged", standupDefinition, "no changes", 0},
+		{"changed", drifted, "~ label 'mood' max: '10' -> '5'", 2},
+		{"shrunk", removed, "- label 'mood' (1 entries are kept with earlier versions)", 2},
+		{"broken", "labels: [", "", 1},
+	} {
+		r := run(t, db, tt.definition, "plan", "-f", "-")
+		if r.code != tt.want || !strings.Contains(r.stdout, tt.output) {
+			t.Errorf("plan of a %s definition = %+v, want exit code %v and %q", tt.name, r, tt.want, tt.output)
+		}
+	}
+	if r := run(t, db, standupDefinition, "plan", "-f", "-"); r.code != 0 {
+		t.Errorf("plan writes to the database: %+v", r)
+	}
+}
+
+func TestDatabaseLocation(t *testing.T) {
+	dataHome := t.TempDir()
+	env := []string{"XDG_DATA_HOME=" + dataHome, "FORMLY_DB=", "FORMLY_WORKSPACE="}
+	for _, args := range [][]string{
+		{"create", "home", "forms kept at home"},
+		{"--workspace", "work", "create", "work", "forms kept at work"},
+	} {
+		if r := runEnv(t, env, "", args...); !strings.Contains(r.stdout, "' created") {
+			t.Fatalf("%v = %+v", args, r)
+		}
+	}
+	for _, path := range []string{"formly/data.db", "formly/workspaces/work.db"} {
+		if _, err := os.Stat(filepath.Join(dataHome, path)); err != nil {
+			t.Errorf("database is not created in XDG_DATA_HOME: %v", err)
+		}
+	}
+	if r := runEnv(t, env, "", "review", "work"); strings.Contains(r.stdout, "forms kept at work") {
+		t.Errorf("forms of a workspace are visible outside of it: %+v", r)
+	}
+	if r := runEnv(t, append(env, "FORMLY_WORKSPACE=work"), "", "review", "work"); !strings.Contains(r.stdout, "forms kept at work") {
+		t.Errorf("review with FORMLY_WORKSPACE = %+v", r)
+	}
+	if r := runEnv(t, env, "", "workspaces"); r.stdout != "work\n" {
+		t.Errorf("workspaces = %+v", r)
+	}
+	db := filepath.Join(t.TempDir(), "formly.db")
+	if r := runEnv(t, append(env, "FORMLY_DB="+db), "", "create", "other", "forms kept elsewhere"); !strings.Contains(r.stdout, "' created") {
+		t.Fatalf("create with FORMLY_DB = %+v", r)
+	}
+	if r := run(t, db, "", "review", "other"); !strings.Contains(r.stdout, "forms kept elsewhere") {
+		t.Errorf("review with --db of the FORMLY_DB database = %+v", r)
+	}
+	if r := runEnv(t, env, "", "--workspace", "../up", "review", "home"); r.code == 0 {
+		t.Errorf("invalid workspace name = %+v, want it rejected", r)
+	}
+}
+
+func TestMigrateStatus(t *testing.T) {
+	db := filepath.Join(t.TempDir(), "formly.db")
+	if r := run(t, db, "", "db", "migrate", "--status"); r.code != 0 || !strings.Contains(r.stdout, "pending") {
+		t.Errorf("db migrate --status of a new database = %+v, want pending migrations", r)
+	}
+	if r := run(t, db, "", "db", "migrate"); r.code != 0 || !strings.Contains(r.stdout, "applied migration 1") {
+		t.Errorf("db migrate = %+v", r)
+	}
+	if r := run(t, db, "", "db", "migrate"); r.code != 0 || !strings.Contains(r.stdout, "up to date") {
+		t.Errorf("db migrate of an up to date database = %+v", r)
+	}
+	if r := run(t, db, "", "db", "migrate", "--status"); r.code != 0 || strings.Contains(r.stdout, "pending") {
+		t.Errorf("db migrate --status after migrating = %+v, want no pending migrations", r)
+	}
+}
+
+func TestOutput(t *testing.T) {
+	db := newDB(t)
+	r := run(t, db, "", "--output", "json", "review", "standup")
+	form := struct {
+		Name   string
+		Labels []struct {
+			Name, Type string
+			Choices    []string
+		}
+	}{}
+	if err := json.Unmarshal([]byte(r.stdout), &form); err != nil {
+		t.Fatalf("review --output json = %+v: %v", r, err)
+	}
+	if form.Name != "standup" || len(form.Labels) != 2 || form.Labels[1].Type != "int" || form.Labels[1].Choices == nil {
+		t.Errorf("review --output json = %+v", form)
+	}
+	if r := run(t, db, "", "--output", "yaml", "review", "standup"); !strings.HasPrefix(r.stdout, "id: 1\nname: standup\n") {
+		t.Errorf("review --output yaml = %+v", r)
+	}
+	if r := run(t, db, "", "review", "standup"); !strings.Contains(r.stdout, "POSITION  NAME  TYPE") {
+		t.Errorf("review = %+v, want a table", r)
+	}
+	if r := run(t, db, "", "--output", "xml", "review", "standup"); r.code != 1 {
+		t.Errorf("review --output xml exits %v, want 1: %+v", r.code, r)
+	}
+	if r := run(t, db, "", "--output", "json", "submissions", "standup"); !strings.Contains(r.stdout, `"submissions": []`) {
+		t.Errorf("submissions --output json of a form without any = %+v, want an empty list", r)
+	}
+}
+
+func TestApplyExitCodes(t *testing.T) {
+	db := filepath.Join(t.TempDir(), "formly.db")
+	if r := run(t, db, standupDefinition, "apply", "-f", "-"); r.code != 0 {
+		t.Fatalf("apply = %+v", r)
+	}
+	if r := run(t, db, "", "submit", "standup", "--mood", "7", "--done", "review"); r.code != 0 {
+		t.Fatalf("submit = %+v", r)
+	}
+	// done can not be of an unknown type, so mood is not removed either
+	broken := strings.Replace(standupDefinition, "repeatable: true", "repeatable: true\n    type: colour", 1)
+	broken = broken[:strings.Index(broken, "  - name: mood")]
+	if r := run(t, db, broken, "apply", "-f", "-"); r.code != 1 || r.stderr == "" {
+		t.Errorf("apply of a definition with an unknown type exits %v with %q on stderr, want 1 and an error", r.code, r.stderr)
+	}
+	if r := run(t, db, standupDefinition, "plan", "-f", "-"); r.code != 0 {
+		t.Errorf("plan after a failed apply = %+v, want no changes", r)
+	}
+}
+
+func TestSubmitJSON(t *testing.T) {
+	db := newDB(t)
+	if r := run(t, db, `{"mood": 7, "done": ["review", "deploy"]}`, "submit", "standup", "--json", "-"); r.code != 0 ||
+		!strings.Contains(r.stdout, `"value": "deploy"`) {
+		t.Errorf("submit --json = %+v", r)
+	}
+	if got := submitted(t, db, "standup"); len(got) != 1 || got[0] != "done: review\ndone: deploy\nmood: 7\n" {
+		t.Errorf("submissions after submit --json = %q", got)
+	}
+	for _, tt := range []struct{ name, stdin string }{
+		{"an invalid value", `{"mood": "seven"}`},
+		{"an unknown label", `{"moood": 7}`},
+		{"several values of a label that does not repeat", `{"mood": [1, 2]}`},
+		{"two documents", `{"mood": 7} {"mood": 8}`},
+	} {
+		if r := run(t, db, tt.stdin, "submit", "standup", "--json", "-"); r.code != 1 {
+			t.Errorf("submit --json of %s exits %v, want 1: %+v", tt.name, r.code, r)
+		}
+	}
+	if r := run(t, db, `{"mood": 7}`, "submit", "standup", "--json", "-", "--mood", "8"); r.code == 0 && strings.Contains(r.stdout, "submitted") {
+		t.Errorf("submit --json combined with label flags = %+v, want it rejected", r)
+	}
+	if r := run(t, db, "", "label", "standup", "json", "a label named json"); strings.Contains(r.stdout, "' created") {
+		t.Errorf("label named json = %+v, want it rejected", r)
+	}
+	definition := standupDefinition + "  - name: json\n    usage: a label named json\n"
+	if r := run(t, db, definition, "apply", "-f", "-"); r.code != 1 || !strings.Contains(r.stderr, "'json'") {
+		t.Errorf("apply of a label named json = %+v, want it rejected", r)
+	}
+	if got := submitted(t, db, "standup"); len(got) != 1 {
+		t.Errorf("submissions = %q, want only the valid submission", got)
+	}
+}
+
+func TestRepeatedFlags(t *testing.T) {
+	db := newDB(t)
+	if r := run(t, db, "", "submit", "standup", "--done", "review,/merge", "--done", " deploy ", "--mood", "7"); !strings.Contains(r.stdout, "submitted") {
+		t.Fatalf("submit with a repeated flag = %+v", r)
+	}
+	if r := run(t, db, "fix a,/b\n\n8\n", "submit", "standup"); !strings.Contains(r.stdout, "submitted") {
+		t.Fatalf("interactive submit = %+v", r)
+	}
+	want := []string{"done: review,/merge\ndone:  deploy \nmood: 7\n", "done: fix a,/b\nmood: 8\n"}
+	if got := submitted(t, db, "standup"); strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("submissions = %q, want %q", got, want)
+	}
+	if r := run(t, db, "", "submit", "standup", "--mood", "7", "--mood", "8"); strings.Contains(r.stdout, "submitted") {
+		t.Errorf("submit with a repeated flag of a label that does not repeat = %+v, want it rejected", r)
+	}
+}
+
+func TestSubmitEditor(t *testing.T) {
+	db := newDB(t)
+	written := filepath.Join(t.TempDir(), "submission.txt")
+	editor := []string{"VISUAL=cp " + written}
+	write := func(txt string) {
+		t.Helper()
+		if err := os.WriteFile(written, []byte(txt), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("==> done (repeatable) <==\nreview\nof the\n\nparser\n--\ndeploy\n\n==> mood (int) <==\n7\n")
+	if r := runEnv(t, editor, "", "--db", db, "submit", "standup", "--editor"); !strings.Contains(r.stdout, "submitted") {
+		t.Fatalf("submit --editor = %+v", r)
+	}
+	if got := submitted(t, db, "standup"); len(got) != 1 || got[0] != "done: review\nof the\n\nparser\ndone: deploy\nmood: 7\n" {
+		t.Errorf("submissions after submit --editor = %q", got)
+	}
+	write("==> mood <==\n8\n")
+	if r := runEnv(t, editor, "", "--db", db, "submissions", "standup", "edit", "1", "--editor"); r.code != 0 {
+		t.Fatalf("submissions edit --editor = %+v", r)
+	}
+	if got := submitted(t, db, "standup"); len(got) != 1 || got[0] != "done: review\nof the\n\nparser\ndone: deploy\nmood: 8\n" {
+		t.Errorf("submissions after submissions edit --editor = %q", got)
+	}
+	write("")
+	if r := runEnv(t, editor, "", "--db", db, "submit", "standup", "--editor"); strings.Contains(r.stdout, "submitted") {
+		t.Errorf("submit --editor of an empty file = %+v, want it canceled", r)
+	}
+	if r := run(t, db, "", "label", "standup", "editor", "a label named editor"); strings.Contains(r.stdout, "' created") {
+		t.Errorf("label named editor = %+v, want it rejected", r)
+	}
+}
+
+func TestChart(t *testing.T) {
+	db := newDB(t)
+	for _, mood := range []string{"7", "7", "3"} {
+		if r := run(t, db, "", "submit", "standup", "--mood", mood); !strings.Contains(r.stdout, "submitted") {
+			t.Fatalf("submit = %+v", r)
+		}
+	}
+	for _, tt := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"chart", "standup", "mood", "--kind", "bar"}, "7 │" + strings.Repeat("█", barWidth) + " 2\n"},
+		{[]string{"chart", "standup", "mood", "--kind", "line"}, "one point per day"},
+		{[]string{"chart", "standup", "--kind", "heatmap"}, "busiest day 3"},
+	} {
+		if r := run(t, db, "", tt.args...); r.code != 0 || !strings.Contains(r.stdout, tt.want) {
+			t.Errorf("%v = %+v, want %q", tt.args, r, tt.want)
+		}
+	}
+	if r := run(t, db, "", "chart", "standup", "mood", "--bucket", "year"); strings.Contains(r.stdout, "┤") {
+		t.Errorf("chart with an unknown bucket = %+v, want it rejected", r)
+	}
+}
+
+func TestChoicesWithCommas(t *testing.T) {
+	db := newDB(t)
+	if r := run(t, db, "", "label", "standup", "--type", "enum", "--choice", "yes, really", "--choice", "no", "sure", "are you sure"); r.code != 0 {
+		t.Fatalf("label --choice = %+v", r)
+	}
+	// modifying anything else keeps the choices as they are
+	if r := run(t, db, "", "modify", "standup", "sure", "--usage", "really sure"); r.code != 0 {
+		t.Fatalf("modify = %+v", r)
+	}
+	if r := run(t, db, "", "--output", "json", "review", "standup"); !strings.Contains(r.stdout, `"yes, really",`) {
+		t.Errorf("choices after modify = %s", r.stdout)
+	}
+	if r := run(t, db, "", "modify", "standup", "sure", "--choices", "a,b", "--choice", "c, d"); r.code != 0 ||
+		!strings.Contains(r.stdout, "enum(a|b|c, d)") {
+		t.Errorf("modify --choices --choice = %+v", r)
+	}
+}
+
+func TestModifyLabel(t *testing.T) {
+	db := newDB(t)
+	// the label can not be saved, so the form keeps its name as well
+	if r := run(t, db, "", "modify", "standup", "--name", "daily", "mood", "--type", "colour"); r.code != 1 || r.stderr == "" {
+		t.Errorf("modify of a label to an unknown type exits %v with %q on stderr, want 1 and an error", r.code, r.stderr)
+	}
+	if r := run(t, db, "", "review", "standup"); r.code != 0 {
+		t.Errorf("review after a failed modify = %+v, want the form under its old name", r)
+	}
+	r := run(t, db, "", "--output", "json", "modify", "standup", "--name", "daily", "mood", "--position", "1")
+	if r.code != 0 || strings.Index(r.stdout, `"mood"`) > strings.Index(r.stdout, `"done"`) {
+		t.Errorf("modify --name --position = %+v, want mood first", r)
+	}
+	if r := run(t, db, "", "review", "daily"); r.code != 0 {
+		t.Errorf("review after modify --name = %+v", r)
+	}
+}
+
+func TestSubmitExitCodes(t *testing.T) {
+	db := newDB(t)
+	if r := run(t, db, "", "modify", "standup", "mood", "--required"); r.code != 0 {
+		t.Fatalf("modify = %+v", r)
+	}
+	for _, args := range [][]string{
+		{"submit", "standup", "--done", "review"},
+		{"submit", "standup", "--editor"},
+		{"submit", "standup", "--editor", "--json", "-"},
+		{"submit", "standup", "--mood", "7", "--json", "-"},
+	} {
+		if r := run(t, db, "", args...); r.code != 1 || r.stderr == "" {
+			t.Errorf("form %s exits %v with %q on stderr, want 1 and an error", strings.Join(args, " "), r.code, r.stderr)
+		}
+	}
+	if r := run(t, db, "", "submit", "standup", "--mood", "7"); r.code != 0 {
+		t.Errorf("submit = %+v", r)
+	}
+}
+
+func TestErrorExitCodes(t *testing.T) {
+	db := newDB(t)
+	if r := run(t, db, "", "submit", "standup", "--mood", "7"); r.code != 0 {
+		t.Fatalf("submit = %+v", r)
+	}
+	for _, args := range [][]string{
+		{"create", "standup"},
+		{"create", "standup", "daily standup notes"},
+		{"label", "standup", "--type", "colour", "shade", "shade of the day"},
+		{"label", "missing", "mood", "mood of a missing form"},
+		{"review", "missing"},
+		{"delete", "standup", "--label", "missing"},
+		{"modify", "standup", "missing", "--usage", "missing label"},
+		{"modify", "standup", "mood", "--type", "colour"},
+		{"history", "missing"},
+		{"export-schema", "missing"},
+		{"search"},
+		{"apply"},
+		{"frobnicate"},
+		{"db"},
+		{"db", "frobnicate"},
+		{"webhooks", "deliver", "standup"},
+	} {
+		if r := run(t, db, "", args...); r.code != 1 || r.stderr == "" {
+			t.Errorf("form %s exits %v with %q on stderr, want 1 and an error", strings.Join(args, " "), r.code, r.stderr)
+		}
+	}
+}
+
+func TestExportImport(t *testing.T) {
+	db := newDB(t)
+	if r := run(t, db, "", "submit", "standup", "--mood", "7", "--done", "review; merge", "--done", `C:\temp`); r.code != 0 {
+		t.Fatalf("submit = %+v", r)
+	}
+	exported := run(t, db, "", "export", "standup")
+	other := newDB(t)
+	if r := run(t, other, exported.stdout, "import", "standup", "-"); r.code != 0 {
+		t.Fatalf("import of an export = %+v", r)
+	}
+	r := run(t, other, "", "--output", "json", "submissions", "standup")
+	if !strings.Contains(r.stdout, `"value": "review; merge"`) || !strings.Contains(r.stdout, `"value": "C:\\temp"`) {
+		t.Errorf("imported submissions = %s", r.stdout)
+	}
+	expanded := run(t, db, "", "export", "standup", "--expand")
+	if r := run(t, other, expanded.stdout, "import", "standup", "-"); r.code != 1 || !strings.Contains(r.stdout, "--expand") {
+		t.Errorf("import of an expanded export = %+v, want it refused", r)
+	}
+}
+
+func TestImportHooks(t *testing.T) {
+	db := newDB(t)
+	if r := run(t, db, "", "hook", "add", "standup", "--pre", "echo no imports >&2; exit 1"); r.code != 0 {
+		t.Fatalf("hook add = %+v", r)
+	}
+	if r := run(t, db, "", "webhooks", "add", "standup", "http://127.0.0.1:1/hook"); r.code != 0 {
+		t.Fatalf("webhooks add = %+v", r)
+	}
+	rows := "mood,done\n7,review\n"
+	if r := run(t, db, rows, "import", "standup", "-"); r.code != 1 || !strings.Contains(r.stderr, "no imports") {
+		t.Errorf("import past a rejecting pre-submit hook = %+v", r)
+	}
+	if r := run(t, db, rows, "import", "standup", "--no-hooks", "-"); r.code != 0 || !strings.Contains(r.stdout, "imported 1 submissions") {
+		t.Errorf("import --no-hooks = %+v", r)
+	}
+	if r := run(t, db, "", "--output", "json", "webhooks", "log", "standup"); !strings.Contains(r.stdout, `"deliveries": []`) {
+		t.Errorf("deliveries after import --no-hooks = %s, want none", r.stdout)
+	}
+}
+
+func TestWebhooksDeliver(t *testing.T) {
+	db := newDB(t)
+	var received int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&received, 1)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+	// nothing listens on port 1, so every attempt there fails right away
+	for _, url := range []string{srv.URL, "http://127.0.0.1:1/hook"} {
+		if r := run(t, db, "", "webhooks", "add", "standup", url); r.code != 0 {
+			t.Fatalf("webhooks add = %+v", r)
+		}
+	}
+	// submit sends the deliveries on its way out
+	if r := run(t, db, "", "submit", "standup", "--mood", "7"); r.code != 0 {
+		t.Fatalf("submit = %+v", r)
+	}
+	r := run(t, db, "", "--output", "json", "webhooks", "log", "standup")
+	if received := atomic.LoadInt32(&received); received != 1 || !strings.Contains(r.stdout, `"status": "delivered"`) || !strings.Contains(r.stdout, `"status": "pending"`) {
+		t.Errorf("deliveries after submit = %s with %v received, want one delivered and one to retry", r.stdout, received)
+	}
+	if r := run(t, db, "", "--output", "json", "webhooks", "deliver", "--timeout", "5s"); r.code != 0 || !strings.Contains(r.stdout, `"deliveries": []`) {
+		t.Errorf("webhooks deliver before the retry is due = %+v, want nothing sent", r)
+	}
+	if r := run(t, db, "", "webhooks", "deliver", "--timeout", "0s"); r.code != 1 {
+		t.Errorf("webhooks deliver without time = %+v, want it refused", r)
+	}
+}
@@ -0,0 +1,564 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pablothedeveloper/formly"
+	"gopkg.in/yaml.v3"
+)
+
+// output is the format commands print their results in, picked with the
+// global --output flag. The json and yaml documents are described in the
+// readme and only ever gain fields, so scripts can depend on them.
+type output string
+
+const (
+	tableOutput output = "table"
+	jsonOutput  output = "json"
+	yamlOutput  output = "yaml"
+)
+
+const tableTimeLayout = "2006-01-02 15:04:05"
+
+func parseOutput(name string) (output, error) {
+	switch out := output(name); out {
+	case tableOutput, jsonOutput, yamlOutput:
+		return out, nil
+	}
+	return "", fmt.Errorf("unknown output '%s', expected table, json or yaml", name)
+}
+
+// structured reports whether documents are printed instead of tables.
+func (out output) structured() bool {
+	return out != tableOutput
+}
+
+// print writes doc as json or yaml, or calls table to print it for humans.
+func (out output) print(doc interface{}, table func(w io.Writer)) error {
+	switch out {
+	case jsonOutput:
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(doc)
+	case yamlOutput:
+		encoder := yaml.NewEncoder(os.Stdout)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(doc); err != nil {
+			return err
+		}
+		return encoder.Close()
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	table(w)
+	return w.Flush()
+}
+
+type formDocument struct {
+	ID     int64           `json:"id" yaml:"id"`
+	Name   string          `json:"name" yaml:"name"`
+	Usage  string          `json:"usage" yaml:"usage"`
+	Labels []labelDocument `json:"labels" yaml:"labels"`
+}
+
+type labelDocument struct {
+	ID         int64    `json:"id" yaml:"id"`
+	Position   int64    `json:"position" yaml:"position"`
+	Name       string   `json:"name" yaml:"name"`
+	Usage      string   `json:"usage" yaml:"usage"`
+	Type       string   `json:"type" yaml:"type"`
+	Repeatable bool     `json:"repeatable" yaml:"repeatable"`
+	Required   bool     `json:"required" yaml:"required"`
+	Default    string   `json:"default" yaml:"default"`
+	Choices    []string `json:"choices" yaml:"choices"`
+	Pattern    string   `json:"pattern" yaml:"pattern"`
+	MinLength  int64    `json:"min_length" yaml:"min_length"`
+	MaxLength  int64    `json:"max_length" yaml:"max_length"`
+	Min        *float64 `json:"min" yaml:"min"`
+	Max        *float64 `json:"max" yaml:"max"`
+}
+
+type submissionsDocument struct {
+	Form        string               `json:"form" yaml:"form"`
+	Submissions []submissionDocument `json:"submissions" yaml:"submissions"`
+}
+
+type submissionDocument struct {
+	ID        int64           `json:"id" yaml:"id"`
+	Form      string          `json:"form" yaml:"form"`
+	Version   int64           `json:"version" yaml:"version"`
+	CreatedAt time.Time       `json:"created_at" yaml:"created_at"`
+	Entries   []entryDocument `json:"entries" yaml:"entries"`
+}
+
+type entryDocument struct {
+	ID      int64  `json:"id" yaml:"id"`
+	LabelID int64  `json:"label_id" yaml:"label_id"`
+	Label   string `json:"label" yaml:"label"`
+	Value   string `json:"value" yaml:"value"`
+}
+
+type changesDocument struct {
+	SubmissionID int64                      `json:"submission_id" yaml:"submission_id"`
+	Changes      []submissionChangeDocument `json:"changes" yaml:"changes"`
+}
+
+type submissionChangeDocument struct {
+	ID        int64     `json:"id" yaml:"id"`
+	LabelID   int64     `json:"label_id" yaml:"label_id"`
+	Label     string    `json:"label" yaml:"label"`
+	ChangedAt time.Time `json:"changed_at" yaml:"changed_at"`
+	From      []string  `json:"from" yaml:"from"`
+	To        []string  `json:"to" yaml:"to"`
+}
+
+type historyDocument struct {
+	Form     string            `json:"form" yaml:"form"`
+	Versions []versionDocument `json:"versions" yaml:"versions"`
+}
+
+type versionDocument struct {
+	Version     int64           `json:"version" yaml:"version"`
+	CreatedAt   time.Time       `json:"created_at" yaml:"created_at"`
+	Submissions int             `json:"submissions" yaml:"submissions"`
+	Labels      []labelDocument `json:"labels" yaml:"labels"`
+}
+
+type planDocument struct {
+	Form    string           `json:"form" yaml:"form"`
+	Changes []changeDocument `json:"changes" yaml:"changes"`
+}
+
+type changeDocument struct {
+	Kind    string `json:"kind" yaml:"kind"`
+	Label   string `json:"label" yaml:"label"`
+	Field   string `json:"field" yaml:"field"`
+	From    string `json:"from" yaml:"from"`
+	To      string `json:"to" yaml:"to"`
+	Entries int64  `json:"entries" yaml:"entries"`
+}
+
+type importDocument struct {
+	Form     string                `json:"form" yaml:"form"`
+	Rows     int                   `json:"rows" yaml:"rows"`
+	Imported int                   `json:"imported" yaml:"imported"`
+	DryRun   bool                  `json:"dry_run" yaml:"dry_run"`
+	Errors   []importErrorDocument `json:"errors" yaml:"errors"`
+}
+
+type importErrorDocument struct {
+	Line  int    `json:"line" yaml:"line"`
+	Error string `json:"error" yaml:"error"`
+}
+
+type hooksDocument struct {
+	Form  string         `json:"form" yaml:"form"`
+	Hooks []hookDocument `json:"hooks" yaml:"hooks"`
+}
+
+type hookDocument struct {
+	ID        int64  `json:"id" yaml:"id"`
+	Form      string `json:"form" yaml:"form"`
+	Event     string `json:"event" yaml:"event"`
+	Command   string `json:"command" yaml:"command"`
+	Timeout   string `json:"timeout" yaml:"timeout"`
+	OnFailure string `json:"on_failure" yaml:"on_failure"`
+}
+
+type webhooksDocument struct {
+	Form     string            `json:"form" yaml:"form"`
+	Webhooks []webhookDocument `json:"webhooks" yaml:"webhooks"`
+}
+
+type webhookDocument struct {
+	ID     int64  `json:"id" yaml:"id"`
+	Form   string `json:"form" yaml:"form"`
+	URL    string `json:"url" yaml:"url"`
+	Secret string `json:"secret" yaml:"secret"`
+}
+
+type deliveriesDocument struct {
+	Form       string             `json:"form" yaml:"form"`
+	Deliveries []deliveryDocument `json:"deliveries" yaml:"deliveries"`
+}
+
+type deliveredDocument struct {
+	Deliveries []deliveryDocument `json:"deliveries" yaml:"deliveries"`
+}
+
+type deliveryDocument struct {
+	ID            int64      `json:"id" yaml:"id"`
+	WebhookID     int64      `json:"webhook_id" yaml:"webhook_id"`
+	URL           string     `json:"url" yaml:"url"`
+	SubmissionID  int64      `json:"submission_id" yaml:"submission_id"`
+	Status        string     `json:"status" yaml:"status"`
+	Attempts      int64      `json:"attempts" yaml:"attempts"`
+	StatusCode    int        `json:"status_code" yaml:"status_code"`
+	Error         string     `json:"error" yaml:"error"`
+	CreatedAt     time.Time  `json:"created_at" yaml:"created_at"`
+	NextAttemptAt *time.Time `json:"next_attempt_at" yaml:"next_attempt_at"`
+	DeliveredAt   *time.Time `json:"delivered_at" yaml:"delivered_at"`
+}
+
+type searchDocument struct {
+	Query   string                 `json:"query" yaml:"query"`
+	Results []searchResultDocument `json:"results" yaml:"results"`
+}
+
+type searchResultDocument struct {
+	SubmissionID int64     `json:"submission_id" yaml:"submission_id"`
+	EntryID      int64     `json:"entry_id" yaml:"entry_id"`
+	Form         string    `json:"form" yaml:"form"`
+	Label        string    `json:"label" yaml:"label"`
+	CreatedAt    time.Time `json:"created_at" yaml:"created_at"`
+	Value        string    `json:"value" yaml:"value"`
+	Highlight    string    `json:"highlight" yaml:"highlight"`
+}
+
+type statsDocument struct {
+	Form        string                `json:"form" yaml:"form"`
+	Submissions int                   `json:"submissions" yaml:"submissions"`
+	First       *time.Time            `json:"first" yaml:"first"`
+	Last        *time.Time            `json:"last" yaml:"last"`
+	Period      string                `json:"period" yaml:"period"`
+	PerPeriod   []periodCountDocument `json:"per_period" yaml:"per_period"`
+	Labels      []labelStatsDocument  `json:"labels" yaml:"labels"`
+}
+
+type periodCountDocument struct {
+	Start       string `json:"start" yaml:"start"`
+	Submissions int    `json:"submissions" yaml:"submissions"`
+}
+
+type labelStatsDocument struct {
+	Label       string               `json:"label" yaml:"label"`
+	Type        string               `json:"type" yaml:"type"`
+	Count       int                  `json:"count" yaml:"count"`
+	Submissions int                  `json:"submissions" yaml:"submissions"`
+	Min         *float64             `json:"min" yaml:"min"`
+	Max         *float64             `json:"max" yaml:"max"`
+	Mean        *float64             `json:"mean" yaml:"mean"`
+	Median      *float64             `json:"median" yaml:"median"`
+	Frequencies []valueCountDocument `json:"frequencies" yaml:"frequencies"`
+}
+
+type valueCountDocument struct {
+	Value string `json:"value" yaml:"value"`
+	Count int    `json:"count" yaml:"count"`
+}
+
+type chartDocument struct {
+	Form   string               `json:"form" yaml:"form"`
+	Label  string               `json:"label" yaml:"label"`
+	Kind   string               `json:"kind" yaml:"kind"`
+	Bucket string               `json:"bucket" yaml:"bucket"`
+	Points []chartPointDocument `json:"points" yaml:"points"`
+}
+
+type chartPointDocument struct {
+	Start       string   `json:"start" yaml:"start"`
+	Submissions int      `json:"submissions" yaml:"submissions"`
+	Values      []string `json:"values" yaml:"values"`
+}
+
+type workspacesDocument struct {
+	Workspaces []string `json:"workspaces" yaml:"workspaces"`
+}
+
+func newFormDocument(form formly.Form, labels []formly.Label) formDocument {
+	return formDocument{ID: form.ID, Name: form.Name, Usage: form.Usage, Labels: newLabelDocuments(labels)}
+}
+
+func newLabelDocuments(labels []formly.Label) []labelDocument {
+	docs := []labelDocument{}
+	for _, label := range labels {
+		docs = append(docs, newLabelDocument(label))
+	}
+	return docs
+}
+
+func newLabelDocument(label formly.Label) labelDocument {
+	choices := []string{}
+	choices = append(choices, label.Choices...)
+	return labelDocument{
+		ID:         label.ID,
+		Position:   label.Position,
+		Name:       label.Name,
+		Usage:      label.Usage,
+		Type:       string(label.Type),
+		Repeatable: label.Repeatable,
+		Required:   label.Required,
+		Default:    label.Default,
+		Choices:    choices,
+		Pattern:    label.Constraints.Pattern,
+		MinLength:  label.Constraints.MinLength,
+		MaxLength:  label.Constraints.MaxLength,
+		Min:        label.Constraints.Min,
+		Max:        label.Constraints.Max,
+	}
+}
+
+// newSubmissionDocument names the entries after the labels of the version
+// the submission was made with.
+func newSubmissionDocument(form string, submission formly.Submission, labels []formly.Label) submissionDocument {
+	names := map[int64]string{}
+	for _, label := range labels {
+		names[label.ID] = label.Name
+	}
+	doc := submissionDocument{
+		ID:        submission.ID,
+		Form:      form,
+		Version:   submission.Version,
+		CreatedAt: submission.CreateAt,
+		Entries:   []entryDocument{},
+	}
+	for _, entry := range submission.Entries {
+		doc.Entries = append(doc.Entries, entryDocument{
+			ID:      entry.ID,
+			LabelID: entry.LabelID,
+			Label:   names[entry.LabelID],
+			Value:   entry.Txt,
+		})
+	}
+	return doc
+}
+
+func newHookDocument(form formly.Form, hook formly.Hook) hookDocument {
+	timeout := hook.Timeout
+	if timeout == 0 {
+		timeout = formly.DefaultHookTimeout
+	}
+	return hookDocument{
+		ID:        hook.ID,
+		Form:      form.Name,
+		Event:     string(hook.Event),
+		Command:   hook.Command,
+		Timeout:   timeout.String(),
+		OnFailure: string(hook.OnFailure),
+	}
+}
+
+func printHooks(w io.Writer, docs []hookDocument) {
+	fmt.Fprintln(w, "ID\tEVENT\tON FAILURE\tTIMEOUT\tCOMMAND")
+	for _, doc := range docs {
+		fmt.Fprintf(w, "%v\t%s\t%s\t%s\t%s\n", doc.ID, doc.Event, doc.OnFailure, doc.Timeout, tableText(doc.Command))
+	}
+}
+
+func newWebhookDocument(form formly.Form, webhook formly.Webhook) webhookDocument {
+	return webhookDocument{ID: webhook.ID, Form: form.Name, URL: webhook.URL, Secret: webhook.Secret}
+}
+
+func printWebhooks(w io.Writer, docs []webhookDocument) {
+	fmt.Fprintln(w, "ID\tURL\tSECRET")
+	for _, doc := range docs {
+		fmt.Fprintf(w, "%v\t%s\t%s\n", doc.ID, tableText(doc.URL), doc.Secret)
+	}
+}
+
+// newDeliveryDocument only sets the next attempt of pending deliveries.
+func newDeliveryDocument(delivery formly.WebhookDelivery, url string) deliveryDocument {
+	doc := deliveryDocument{
+		ID:           delivery.ID,
+		WebhookID:    delivery.WebhookID,
+		URL:          url,
+		SubmissionID: delivery.SubmissionID,
+		Status:       string(delivery.Status),
+		Attempts:     delivery.Attempts,
+		StatusCode:   delivery.StatusCode,
+		Error:        delivery.Error,
+		CreatedAt:    delivery.CreatedAt,
+	}
+	if delivery.Status == formly.DeliveryPending {
+		nextAttemptAt := delivery.NextAttemptAt
+		doc.NextAttemptAt = &nextAttemptAt
+	}
+	if !delivery.DeliveredAt.IsZero() {
+		deliveredAt := delivery.DeliveredAt
+		doc.DeliveredAt = &deliveredAt
+	}
+	return doc
+}
+
+func printDeliveries(w io.Writer, docs []deliveryDocument) {
+	fmt.Fprintln(w, "ID\tWEBHOOK\tSUBMISSION\tCREATED AT\tSTATUS\tATTEMPTS\tLAST ATTEMPT\tNEXT ATTEMPT")
+	for _, doc := range docs {
+		last := ""
+		if doc.StatusCode != 0 {
+			last = strconv.Itoa(doc.StatusCode)
+		}
+		if doc.Error != "" {
+			last = tableText(doc.Error)
+		}
+		next := ""
+		if doc.NextAttemptAt != nil {
+			next = doc.NextAttemptAt.Format(tableTimeLayout)
+		}
+		fmt.Fprintf(
+			w, "%v\t%v %s\t%v\t%s\t%s\t%v\t%s\t%s\n",
+			doc.ID, doc.WebhookID, tableText(doc.URL), doc.SubmissionID, doc.CreatedAt.Format(tableTimeLayout),
+			doc.Status, doc.Attempts, last, next,
+		)
+	}
+}
+
+func printForm(w io.Writer, doc formDocument) {
+	fmt.Fprintln(w, "ID\tNAME\tUSAGE")
+	fmt.Fprintf(w, "%v\t%s\t%s\n", doc.ID, doc.Name, doc.Usage)
+	if len(doc.Labels) == 0 {
+		fmt.Fprintln(w, "\nno labels yet")
+		return
+	}
+	fmt.Fprintln(w)
+	printLabels(w, doc.Labels)
+}
+
+func printLabels(w io.Writer, labels []labelDocument) {
+	fmt.Fprintln(w, "POSITION\tNAME\tTYPE\tREQUIRED\tREPEATABLE\tDEFAULT\tUSAGE")
+	for _, label := range labels {
+		typ := label.Type
+		if len(label.Choices) != 0 {
+			typ += "(" + strings.Join(label.Choices, "|") + ")"
+		}
+		fmt.Fprintf(
+			w, "%v\t%s\t%s\t%v\t%v\t%s\t%s\n",
+			label.Position, label.Name, typ, label.Required, label.Repeatable, label.Default, label.Usage,
+		)
+	}
+}
+
+func printSubmissions(w io.Writer, submissions []submissionDocument) {
+	fmt.Fprintln(w, "ID\tCREATED\tVERSION\tLABEL\tVALUE")
+	for _, submission := range submissions {
+		head := fmt.Sprintf("%v\t%s\t%v", submission.ID, submission.CreatedAt.Format(tableTimeLayout), submission.Version)
+		if len(submission.Entries) == 0 {
+			fmt.Fprintf(w, "%s\t\t\n", head)
+		}
+		for i, entry := range submission.Entries {
+			if i != 0 {
+				head = "\t\t"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\n", head, entry.Label, tableText(entry.Value))
+		}
+	}
+}
+
+// tableText keeps multi-line values on their row of a table.
+func tableText(txt string) string {
+	return strings.ReplaceAll(txt, "\n", "\\n")
+}
+
+// colorOutput reports whether tables may be styled, which is only when they
+// go to a terminal and NO_COLOR is not set.
+func colorOutput() bool {
+	if _, ok := os.LookupEnv("NO_COLOR"); ok {
+		return false
+	}
+	info, err := os.Stdout.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+func newStatsDocument(stats formly.FormStats) statsDocument {
+	doc := statsDocument{
+		Form:        stats.Form.Name,
+		Submissions: stats.Submissions,
+		Period:      string(stats.Period),
+		PerPeriod:   []periodCountDocument{},
+		Labels:      []labelStatsDocument{},
+	}
+	if stats.Submissions != 0 {
+		doc.First, doc.Last = &stats.First, &stats.Last
+	}
+	for _, period := range stats.PerPeriod {
+		doc.PerPeriod = append(doc.PerPeriod, periodCountDocument{
+			Start:       period.Start.Format(formly.DateLayout),
+			Submissions: period.Submissions,
+		})
+	}
+	for _, label := range stats.Labels {
+		labelDoc := labelStatsDocument{
+			Label:       label.Label.Name,
+			Type:        string(label.Label.Type),
+			Count:       label.Count,
+			Submissions: label.Submissions,
+			Min:         label.Min,
+			Max:         label.Max,
+			Mean:        label.Mean,
+			Median:      label.Median,
+			Frequencies: []valueCountDocument{},
+		}
+		for _, frequency := range label.Frequencies {
+			labelDoc.Frequencies = append(labelDoc.Frequencies, valueCountDocument{Value: frequency.Value, Count: frequency.Count})
+		}
+		doc.Labels = append(doc.Labels, labelDoc)
+	}
+	return doc
+}
+
+// statsFrequencies is how many values of a label the stats table shows.
+const statsFrequencies = 5
+
+func printStats(w io.Writer, stats formly.FormStats, doc statsDocument) {
+	if stats.Submissions == 0 {
+		fmt.Fprintf(w, "no submission of form '%s' to count\n", doc.Form)
+		return
+	}
+	fmt.Fprintf(
+		w, "form '%s': %v submissions from %s to %s\n",
+		doc.Form, doc.Submissions, stats.First.Format(tableTimeLayout), stats.Last.Format(tableTimeLayout),
+	)
+	numbers, values := []labelStatsDocument{}, []labelStatsDocument{}
+	for _, label := range doc.Labels {
+		if label.Type == string(formly.IntLabel) || label.Type == string(formly.FloatLabel) {
+			numbers = append(numbers, label)
+			continue
+		}
+		values = append(values, label)
+	}
+	if len(numbers) != 0 {
+		fmt.Fprintln(w, "\nLABEL\tCOUNT\tMIN\tMAX\tMEAN\tMEDIAN")
+		for _, label := range numbers {
+			fmt.Fprintf(
+				w, "%s\t%v\t%s\t%s\t%s\t%s\n",
+				label.Label, label.Count, formatStat(label.Min), formatStat(label.Max), formatStat(label.Mean), formatStat(label.Median),
+			)
+		}
+	}
+	if len(values) != 0 {
+		fmt.Fprintln(w, "\nLABEL\tCOUNT\tVALUE\tTIMES")
+		for _, label := range values {
+			head := fmt.Sprintf("%s\t%v", label.Label, label.Count)
+			if len(label.Frequencies) == 0 {
+				fmt.Fprintf(w, "%s\t\t\n", head)
+			}
+			for i, frequency := range label.Frequencies {
+				if i == statsFrequencies {
+					fmt.Fprintf(w, "\t\t%v more\t\n", len(label.Frequencies)-i)
+					break
+				}
+				if i != 0 {
+					head = "\t"
+				}
+				fmt.Fprintf(w, "%s\t%s\t%v\n", head, tableText(frequency.Value), frequency.Count)
+			}
+		}
+	}
+	fmt.Fprintf(w, "\n%s\tSUBMISSIONS\n", strings.ToUpper(doc.Period))
+	for _, period := range doc.PerPeriod {
+		fmt.Fprintf(w, "%s\t%v\n", period.Start, period.Submissions)
+	}
+}
+
+func formatStat(stat *float64) string {
+	if stat == nil {
+		return ""
+	}
+	return strconv.FormatFloat(math.Round(*stat*100)/100, 'f', -1, 64)
+}
@@ -0,0 +1,270 @@
+package formly
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Definition describes a form and its ordered labels as stored in a yaml or
+// json file.
+type Definition struct {
+	Name   string            `json:"name" yaml:"name"`
+	Usage  string            `json:"usage" yaml:"usage"`
+	Labels []LabelDefinition `json:"labels" yaml:"labels"`
+}
+
+// LabelDefinition ...
+type LabelDefinition struct {
+	Name string `json:"name" yaml:"name"`
+	// RenamedFrom lets an existing label keep its entries under a new name.
+	RenamedFrom string    `json:"renamed_from,omitempty" yaml:"renamed_from,omitempty"`
+	Usage       string    `json:"usage" yaml:"usage"`
+	Repeatable  bool      `json:"repeatable,omitempty" yaml:"repeatable,omitempty"`
+	Required    bool      `json:"required,omitempty" yaml:"required,omitempty"`
+	Type        LabelType `json:"type,omitempty" yaml:"type,omitempty"`
+	Choices     []string  `json:"choices,omitempty" yaml:"choices,omitempty"`
+	Default     string    `json:"default,omitempty" yaml:"default,omitempty"`
+	Pattern     string    `json:"pattern,omitempty" yaml:"pattern,omitempty"`
+	MinLength   int64     `json:"min_length,omitempty" yaml:"min_length,omitempty"`
+	MaxLength   int64     `json:"max_length,omitempty" yaml:"max_length,omitempty"`
+	Min         *float64  `json:"min,omitempty" yaml:"min,omitempty"`
+	Max         *float64  `json:"max,omitempty" yaml:"max,omitempty"`
+}
+
+// ErrUnknownDefinitionFormat ...
+var ErrUnknownDefinitionFormat error = errors.New("definition format is not one of: yaml, json")
+
+// DefinitionFormat guesses the format of a definition file from its extension.
+func DefinitionFormat(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return "json"
+	default:
+		return "yaml"
+	}
+}
+
+// ParseDefinition ...
+func ParseDefinition(r io.Reader, format string) (Definition, error) {
+	def := Definition{}
+	switch format {
+	case "yaml", "yml":
+		decoder := yaml.NewDecoder(r)
+		decoder.KnownFields(true)
+		if err := decoder.Decode(&def); err != nil {
+			return Definition{}, err
+		}
+	case "json":
+		decoder := json.NewDecoder(r)
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&def); err != nil {
+			return Definition{}, err
+		}
+	default:
+		return Definition{}, ErrUnknownDefinitionFormat
+	}
+	return def, def.Validate()
+}
+
+// WriteDefinition ...
+func WriteDefinition(w io.Writer, def Definition, format string) error {
+	switch format {
+	case "yaml", "yml":
+		encoder := yaml.NewEncoder(w)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(def); err != nil {
+			return err
+		}
+		return encoder.Close()
+	case "json":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(def)
+	}
+	return ErrUnknownDefinitionFormat
+}
+
+// Validate checks the definition against the same rules the models enforce.
+func (def Definition) Validate() error {
+	if err := ValidateName(def.Name); err != nil {
+		return fmt.Errorf("form '%s': %v", def.Name, err)
+	}
+	if err := ValidateUsage(def.Usage); err != nil {
+		return fmt.Errorf("form '%s': %v", def.Name, err)
+	}
+	names := map[string]bool{}
+	for i, labelDef := range def.Labels {
+		label := labelDef.label(0, int64(i+1))
+		if err := ValidateLabelName(label.Name); err != nil {
+			return fmt.Errorf("label '%s': %v", label.Name, err)
+		}
+		if err := ValidateUsage(label.Usage); err != nil {
+			return fmt.Errorf("label '%s': %v", label.Name, err)
+		}
+		if err := ValidateType(label.Type, label.Choices); err != nil {
+			return fmt.Errorf("label '%s': %v", label.Name, err)
+		}
+		if err := ValidateConstraints(label.Type, label.Constraints); err != nil {
+			return fmt.Errorf("label '%s': %v", label.Name, err)
+		}
+		if _, err := ValidateDefault(label); err != nil {
+			return err
+		}
+		if names[label.Name] {
+			return fmt.Errorf("label '%s' is defined more than once", label.Name)
+		}
+		names[label.Name] = true
+	}
+	return nil
+}
+
+func (labelDef LabelDefinition) label(formID, position int64) Label {
+	label := Label{
+		FormID:     formID,
+		Position:   position,
+		Repeatable: labelDef.Repeatable,
+		Required:   labelDef.Required,
+		Type:       labelDef.Type,
+		Choices:    labelDef.Choices,
+		Constraints: Constraints{
+			Pattern:   labelDef.Pattern,
+			MinLength: labelDef.MinLength,
+			MaxLength: labelDef.MaxLength,
+			Min:       labelDef.Min,
+			Max:       labelDef.Max,
+		},
+		Name:    labelDef.Name,
+		Usage:   labelDef.Usage,
+		Default: labelDef.Default,
+	}
+	if label.Type == "" {
+		label.Type = TextLabel
+	}
+	if def, err := ValidateDefault(label); err == nil {
+		label.Default = def
+	}
+	return label
+}
+
+func labelDefinition(label Label) LabelDefinition {
+	labelDef := LabelDefinition{
+		Name:       label.Name,
+		Usage:      label.Usage,
+		Repeatable: label.Repeatable,
+		Required:   label.Required,
+		Choices:    label.Choices,
+		Default:    label.Default,
+		Pattern:    label.Constraints.Pattern,
+		MinLength:  label.Constraints.MinLength,
+		MaxLength:  label.Constraints.MaxLength,
+		Min:        label.Constraints.Min,
+		Max:        label.Constraints.Max,
+	}
+	if label.Type != TextLabel {
+		labelDef.Type = label.Type
+	}
+	return labelDef
+}
+
+// ExportDefinition ...
+func ExportDefinition(env *Env, name string) (Definition, error) {
+	form, err := env.FormModel.GetByName(name)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return Definition{}, fmt.Errorf("form '%s' does not exists", name)
+		}
+		return Definition{}, err
+	}
+	labels, err := env.LabelModel.GetLabels(form.ID)
+	if err != nil {
+		return Definition{}, err
+	}
+	def := Definition{Name: form.Name, Usage: form.Usage, Labels: []LabelDefinition{}}
+	for _, label := range labels {
+		def.Labels = append(def.Labels, labelDefinition(label))
+	}
+	return def, nil
+}
+
+// Apply creates or updates the form named in the definition so that its
+// stored labels match the definition. Labels missing from the definition
+// are deleted, their entries stay with the earlier versions of the form.
+// The form is saved at once, adding a single version, or not at all.
+func Apply(env *Env, def Definition) (Form, error) {
+	if err := def.Validate(); err != nil {
+		return Form{}, err
+	}
+	form, err := env.FormModel.GetByName(def.Name)
+	labels := []Label{}
+	switch {
+	case err == sql.ErrNoRows:
+		form = Form{Name: def.Name}
+	case err != nil:
+		return Form{}, err
+	default:
+		if labels, err = env.LabelModel.GetLabels(form.ID); err != nil {
+			return Form{}, err
+		}
+	}
+	form.Usage = def.Usage
+	wanted := make([]Label, len(def.Labels))
+	for i, labelDef := range def.Labels {
+		wanted[i] = labelDef.label(form.ID, int64(i+1))
+	}
+	for id, i := range matchLabels(labels, def.Labels) {
+		wanted[i].ID = id
+	}
+	form, _, err = env.FormModel.Save(form, wanted)
+	return form, err
+}
+
+// matchLabels maps the id of each stored label to the index of the label
+// definition it corresponds to, by name or by the name it was renamed from.
+func matchLabels(labels []Label, labelDefs []LabelDefinition) map[int64]int {
+	matches := map[int64]int{}
+	matched := map[int]bool{}
+	byName := map[string]int64{}
+	for _, label := range labels {
+		byName[label.Name] = label.ID
+	}
+	for i, labelDef := range labelDefs {
+		if id, ok := byName[labelDef.Name]; ok {
+			matches[id] = i
+			matched[i] = true
+			delete(byName, labelDef.Name)
+		}
+	}
+	for i, labelDef := range labelDefs {
+		if matched[i] || labelDef.RenamedFrom == "" {
+			continue
+		}
+		if id, ok := byName[labelDef.RenamedFrom]; ok {
+			matches[id] = i
+			delete(byName, labelDef.RenamedFrom)
+		}
+	}
+	return matches
+}
+
+func sameLabel(a, b Label) bool {
+	return a.ID == b.ID && a.FormID == b.FormID && a.Position == b.Position &&
+		a.Repeatable == b.Repeatable && a.Required == b.Required && a.Type == b.Type &&
+		strings.Join(a.Choices, "\n") == strings.Join(b.Choices, "\n") &&
+		sameConstraints(a.Constraints, b.Constraints) &&
+		a.Name == b.Name && a.Usage == b.Usage && a.Default == b.Default
+}
+
+func sameConstraints(a, b Constraints) bool {
+	sameBound := func(x, y *float64) bool {
+		return (x == nil && y == nil) || (x != nil && y != nil && *x == *y)
+	}
+	return a.Pattern == b.Pattern && a.MinLength == b.MinLength && a.MaxLength == b.MaxLength &&
+		sameBound(a.Min, b.Min) && sameBound(a.Max, b.Max)
+}
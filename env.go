@@ -2,8 +2,13 @@ package formly
 
 import (
 	"errors"
+	"fmt"
+	"math"
 	"regexp"
+	"strconv"
+	"strings"
 	"time"
+	"unicode/utf8"
 
 	// to support sqlite
 	_ "github.com/mattn/go-sqlite3"
@@ -15,6 +20,8 @@ type Env struct {
 	LabelModel
 	SubmissionModel
 	EntryModel
+	HookModel
+	WebhookModel
 	close func() error
 }
 
@@ -38,33 +45,120 @@ type FormModel interface {
 	DeleteByID(id int64) (Form, error)
 	DeleteByName(name string) (Form, error)
 	Update(formID int64, name, usage string) (Form, error)
+	GetVersions(formID int64) ([]FormVersion, error)
+	GetVersion(formID, version int64) (FormVersion, error)
+	// Save creates the form when its id is 0, or else updates its name and
+	// usage, and makes labels its labels in that order, all at once. Labels
+	// with an id are updated, the others created and the form's labels left
+	// out deleted. A single version is added when the labels change.
+	Save(form Form, labels []Label) (Form, []Label, error)
+}
+
+// FormVersion is the set of labels a form had between two changes of its
+// labels. Every label created, updated or deleted adds a new version.
+type FormVersion struct {
+	FormID, Version int64
+	CreatedAt       time.Time
+	Labels          []Label
+}
+
+// LabelType ...
+type LabelType string
+
+// Label types an entry's txt is validated and normalized against.
+const (
+	TextLabel  LabelType = "text"
+	IntLabel   LabelType = "int"
+	FloatLabel LabelType = "float"
+	DateLabel  LabelType = "date"
+	BoolLabel  LabelType = "bool"
+	EnumLabel  LabelType = "enum"
+)
+
+// LabelTypes ...
+var LabelTypes = []LabelType{TextLabel, IntLabel, FloatLabel, DateLabel, BoolLabel, EnumLabel}
+
+// Constraints ...
+type Constraints struct {
+	// Pattern has to match an entry as a whole.
+	Pattern string
+	// MinLength and MaxLength count characters, zero leaves them unbounded.
+	MinLength, MaxLength int64
+	// Min and Max bound int and float entries when set.
+	Min, Max *float64
 }
 
 // Label ...
 type Label struct {
 	ID, FormID, Position int64
-	Repeatable           bool
-	Name, Usage          string
+	Repeatable, Required bool
+	Type                 LabelType
+	Choices              []string
+	Constraints          Constraints
+	Name, Usage, Default string
 }
 
 // LabelModel ...
 type LabelModel interface {
-	Create(formID, position int64, repeatable bool, name, usage string) (Label, error)
+	Create(label Label) (Label, error)
 	GetLabels(formID int64) ([]Label, error)
-	Update(formID, labelID, position int64, repeatable bool, name, usage string) ([]Label, error)
+	Update(label Label) ([]Label, error)
 	DeleteByID(id int64) (Label, error)
 }
 
 // Submission ...
 type Submission struct {
 	ID, FormID int64
-	CreateAt   time.Time
+	// Version is the version of the form the submission was filled under.
+	Version  int64
+	CreateAt time.Time
+	// Entries is only filled in by Submit and GetByID, in the order of the
+	// form's labels.
+	Entries []Entry
+}
+
+// NewSubmission is a submission to be stored by SubmitAll. A zero CreateAt
+// stands for the time it is stored at.
+type NewSubmission struct {
+	CreateAt time.Time
+	Values   map[int64][]string
+	// SkipWebhooks stores the submission without queueing it for the form's
+	// webhooks.
+	SkipWebhooks bool
 }
 
 // SubmissionModel ...
 type SubmissionModel interface {
 	Create(formID int64) (Submission, error)
+	// Submit validates values, keyed by label id, against the form's current
+	// labels and stores the submission with all its entries atomically, in
+	// the same go as queueing it for the form's webhooks.
+	Submit(formID int64, values map[int64][]string) (Submission, error)
+	// SubmitAll stores several submissions in one go, either all of them or,
+	// when one fails to validate, none.
+	SubmitAll(formID int64, submissions []NewSubmission) ([]Submission, error)
 	GetSubmissions(formID int64) ([]Submission, error)
+	// Query returns the submissions of a form selected by query, without
+	// their entries like GetSubmissions.
+	Query(query SubmissionQuery) ([]Submission, error)
+	// QueryEntries returns the entries of the submissions selected by query
+	// in one go, ordered like the submissions and then by entry id.
+	QueryEntries(query SubmissionQuery) ([]Entry, error)
+	// GetByID returns the submission along with its entries.
+	GetByID(id int64) (Submission, error)
+	// Edit replaces the values of the labels in values, keyed by label id,
+	// and keeps the previous ones in the submission's change history. The
+	// values are validated against the labels of the submission's version.
+	Edit(id int64, values map[int64][]string) (Submission, error)
+	DeleteByID(id int64) (Submission, error)
+	GetChanges(id int64) ([]SubmissionChange, error)
+}
+
+// SubmissionChange is an edit of a label's values in a submission.
+type SubmissionChange struct {
+	ID, SubmissionID, LabelID int64
+	ChangedAt                 time.Time
+	From, To                  []string
 }
 
 // Entry ...
@@ -77,6 +171,119 @@ type Entry struct {
 type EntryModel interface {
 	Create(submissionID, labelID int64, txt string) (Entry, error)
 	GetEntries(submissionID, labeID int64) ([]Entry, error)
+	CountEntries(labelID int64) (int64, error)
+	// Update and DeleteByID change a single entry, recording the change in
+	// the history of its submission.
+	Update(id int64, txt string) (Entry, error)
+	DeleteByID(id int64) (Entry, error)
+	// Search finds the entries holding every word of query, best matches
+	// first.
+	Search(query string, options SearchOptions) ([]SearchResult, error)
+}
+
+// HookEvent is when a hook runs.
+type HookEvent string
+
+// Hook events, pre-submit hooks run once a submission validated and before
+// it is stored, post-submit hooks once it is stored.
+const (
+	PreSubmit  HookEvent = "pre-submit"
+	PostSubmit HookEvent = "post-submit"
+)
+
+// HookFailure is what a failing hook does to the submission.
+type HookFailure string
+
+// A failing pre-submit hook set to abort rejects the submission, a failing
+// post-submit hook set to abort stops the hooks after it and fails the
+// submit although the submission is kept. Failures of hooks set to ignore
+// are only reported.
+const (
+	AbortOnFailure  HookFailure = "abort"
+	IgnoreOnFailure HookFailure = "ignore"
+)
+
+// Hook is a shell command run when a form is submitted.
+type Hook struct {
+	ID, FormID int64
+	Event      HookEvent
+	Command    string
+	// Timeout bounds how long the command runs, zero is DefaultHookTimeout.
+	Timeout time.Duration
+	// OnFailure defaults to abort for pre-submit hooks and to ignore for
+	// post-submit hooks.
+	OnFailure HookFailure
+}
+
+// HookModel ...
+type HookModel interface {
+	Create(hook Hook) (Hook, error)
+	// GetHooks returns the hooks of a form in the order they were added,
+	// which is the order they run in.
+	GetHooks(formID int64) ([]Hook, error)
+	DeleteByID(id int64) (Hook, error)
+}
+
+// Webhook is a url the submissions of a form are posted to.
+type Webhook struct {
+	ID, FormID int64
+	URL        string
+	// Secret is the key of the signature every delivery carries, see
+	// SignWebhook.
+	Secret string
+}
+
+// DeliveryStatus ...
+type DeliveryStatus string
+
+// A pending delivery waits for its next attempt, a delivered one was
+// accepted by the webhook and a failed one ran out of attempts.
+const (
+	DeliveryPending   DeliveryStatus = "pending"
+	DeliveryDelivered DeliveryStatus = "delivered"
+	DeliveryFailed    DeliveryStatus = "failed"
+)
+
+// WebhookDelivery is a submission posted, or to be posted, to a webhook.
+type WebhookDelivery struct {
+	ID, WebhookID, SubmissionID int64
+	// Payload is the json body, fixed when the delivery is queued so every
+	// attempt sends the same one.
+	Payload  string
+	Status   DeliveryStatus
+	Attempts int64
+	// StatusCode and Error tell how the last attempt went, StatusCode is 0
+	// when no response came back.
+	StatusCode int
+	Error      string
+	CreatedAt  time.Time
+	// NextAttemptAt is when a pending delivery is due.
+	NextAttemptAt time.Time
+	// DeliveredAt is zero until the delivery is delivered.
+	DeliveredAt time.Time
+}
+
+// WebhookModel ...
+type WebhookModel interface {
+	Create(webhook Webhook) (Webhook, error)
+	GetByID(id int64) (Webhook, error)
+	// GetWebhooks returns the webhooks of a form in the order they were
+	// added.
+	GetWebhooks(formID int64) ([]Webhook, error)
+	// DeleteByID deletes a webhook along with its deliveries.
+	DeleteByID(id int64) (Webhook, error)
+	// Enqueue queues a pending delivery of payload, due at now, to every
+	// webhook of a form.
+	Enqueue(formID, submissionID int64, payload string, now time.Time) ([]WebhookDelivery, error)
+	// ClaimDelivery returns the oldest pending delivery due at now and moves
+	// its next attempt to until, so nothing else draining the queue picks it
+	// up meanwhile. It returns sql.ErrNoRows when no delivery is due.
+	ClaimDelivery(now, until time.Time) (WebhookDelivery, error)
+	// UpdateDelivery stores the outcome of an attempt.
+	UpdateDelivery(delivery WebhookDelivery) (WebhookDelivery, error)
+	// GetDeliveries returns the deliveries to the webhooks of a form, newest
+	// first.
+	GetDeliveries(formID int64) ([]WebhookDelivery, error)
 }
 
 // ErrInvalidLengthName ...
@@ -96,6 +303,27 @@ func ValidateName(name string) error {
 	return nil
 }
 
+// ErrReservedLabelName ...
+var ErrReservedLabelName error = errors.New("label name is taken by a flag of form submit")
+
+// reservedLabelNames are the flags form submit takes besides a flag per
+// label.
+var reservedLabelNames = []string{"json", "editor"}
+
+// ValidateLabelName is ValidateName for the name of a label, which can not
+// take the name of a flag of form submit either.
+func ValidateLabelName(name string) error {
+	if err := ValidateName(name); err != nil {
+		return err
+	}
+	for _, reserved := range reservedLabelNames {
+		if name == reserved {
+			return fmt.Errorf("%w: '%s'", ErrReservedLabelName, name)
+		}
+	}
+	return nil
+}
+
 // ErrInvalidLengthUsage ...
 var ErrInvalidLengthUsage error = errors.New("name's length is not between 5 - 252 characters long")
 
@@ -106,3 +334,317 @@ func ValidateUsage(usage string) error {
 	}
 	return nil
 }
+
+// ErrUnknownLabelType ...
+var ErrUnknownLabelType error = errors.New("label type is not one of: text, int, float, date, bool, enum")
+
+// ErrEnumWithoutChoices ...
+var ErrEnumWithoutChoices error = errors.New("enum label needs at least one choice")
+
+// ErrChoicesWithoutEnum ...
+var ErrChoicesWithoutEnum error = errors.New("only enum labels can have choices")
+
+// ValidateType ...
+func ValidateType(typ LabelType, choices []string) error {
+	found := false
+	for _, t := range LabelTypes {
+		if t == typ {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return ErrUnknownLabelType
+	}
+	if typ != EnumLabel {
+		if len(choices) != 0 {
+			return ErrChoicesWithoutEnum
+		}
+		return nil
+	}
+	if len(choices) == 0 {
+		return ErrEnumWithoutChoices
+	}
+	seen := map[string]bool{}
+	for _, choice := range choices {
+		if choice == "" || strings.ContainsAny(choice, "\n\r") {
+			return fmt.Errorf("choice '%s' must be non empty and on a single line", choice)
+		}
+		if seen[strings.ToLower(choice)] {
+			return fmt.Errorf("choice '%s' is listed more than once", choice)
+		}
+		seen[strings.ToLower(choice)] = true
+	}
+	return nil
+}
+
+// ErrInvalidLengthBounds ...
+var ErrInvalidLengthBounds error = errors.New("lengths cannot be negative and min length cannot be above max length")
+
+// ErrInvalidRangeBounds ...
+var ErrInvalidRangeBounds error = errors.New("min has to be below or equal to max")
+
+// ErrRangeWithoutNumber ...
+var ErrRangeWithoutNumber error = errors.New("only int and float labels can have a min or max")
+
+// ValidateConstraints ...
+func ValidateConstraints(typ LabelType, constraints Constraints) error {
+	if constraints.Pattern != "" {
+		if _, err := compilePattern(constraints.Pattern); err != nil {
+			return fmt.Errorf("pattern '%s' is not a valid regular expression: %v", constraints.Pattern, err)
+		}
+	}
+	if constraints.MinLength < 0 || constraints.MaxLength < 0 ||
+		(constraints.MaxLength != 0 && constraints.MinLength > constraints.MaxLength) {
+		return ErrInvalidLengthBounds
+	}
+	if constraints.Min == nil && constraints.Max == nil {
+		return nil
+	}
+	if typ != IntLabel && typ != FloatLabel {
+		return ErrRangeWithoutNumber
+	}
+	if constraints.Min != nil && constraints.Max != nil && *constraints.Min > *constraints.Max {
+		return ErrInvalidRangeBounds
+	}
+	return nil
+}
+
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	return regexp.Compile(`^(?:` + pattern + `)$`)
+}
+
+// ValidateDefault ...
+func ValidateDefault(label Label) (string, error) {
+	if label.Default == "" {
+		return "", nil
+	}
+	return ValidateEntry(label, label.Default)
+}
+
+// DateLayout is the canonical layout date entries are stored with.
+const DateLayout = "2006-01-02"
+
+// ValidateEntry checks txt against the label's type and constraints and
+// returns the canonical form it should be stored as.
+func ValidateEntry(label Label, txt string) (string, error) {
+	canonical, err := normalizeEntry(label, txt)
+	if err != nil {
+		return "", err
+	}
+	constraints := label.Constraints
+	if constraints.Pattern != "" {
+		pattern, err := compilePattern(constraints.Pattern)
+		if err != nil {
+			return "", err
+		}
+		if !pattern.MatchString(canonical) {
+			return "", fmt.Errorf("label '%s' expects a value matching '%s', got '%s'", label.Name, constraints.Pattern, txt)
+		}
+	}
+	length := int64(utf8.RuneCountInString(canonical))
+	if length < constraints.MinLength {
+		return "", fmt.Errorf("label '%s' expects at least %v characters, got '%s'", label.Name, constraints.MinLength, txt)
+	}
+	if constraints.MaxLength != 0 && length > constraints.MaxLength {
+		return "", fmt.Errorf("label '%s' expects at most %v characters, got '%s'", label.Name, constraints.MaxLength, txt)
+	}
+	if constraints.Min == nil && constraints.Max == nil {
+		return canonical, nil
+	}
+	number, err := strconv.ParseFloat(canonical, 64)
+	if err != nil {
+		return "", ErrRangeWithoutNumber
+	}
+	if constraints.Min != nil && number < *constraints.Min {
+		return "", fmt.Errorf("label '%s' expects a value of at least %v, got '%s'", label.Name, *constraints.Min, txt)
+	}
+	if constraints.Max != nil && number > *constraints.Max {
+		return "", fmt.Errorf("label '%s' expects a value of at most %v, got '%s'", label.Name, *constraints.Max, txt)
+	}
+	return canonical, nil
+}
+
+func normalizeEntry(label Label, txt string) (string, error) {
+	if label.Type == TextLabel || label.Type == "" {
+		return txt, nil
+	}
+	value := strings.TrimSpace(txt)
+	switch label.Type {
+	case IntLabel:
+		i, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return "", fmt.Errorf("label '%s' expects an integer, got '%s'", label.Name, txt)
+		}
+		return strconv.FormatInt(i, 10), nil
+	case FloatLabel:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+			return "", fmt.Errorf("label '%s' expects a decimal number, got '%s'", label.Name, txt)
+		}
+		return strconv.FormatFloat(f, 'g', -1, 64), nil
+	case DateLabel:
+		for _, layout := range []string{DateLayout, "2006/01/02", time.RFC3339} {
+			if t, err := time.Parse(layout, value); err == nil {
+				return t.Format(DateLayout), nil
+			}
+		}
+		return "", fmt.Errorf("label '%s' expects a date as YYYY-MM-DD, got '%s'", label.Name, txt)
+	case BoolLabel:
+		switch strings.ToLower(value) {
+		case "true", "t", "yes", "y", "on", "1":
+			return "true", nil
+		case "false", "f", "no", "n", "off", "0":
+			return "false", nil
+		}
+		return "", fmt.Errorf("label '%s' expects true or false, got '%s'", label.Name, txt)
+	case EnumLabel:
+		for _, choice := range label.Choices {
+			if strings.EqualFold(choice, value) {
+				return choice, nil
+			}
+		}
+		return "", fmt.Errorf(
+			"label '%s' expects one of: %s, got '%s'", label.Name, strings.Join(label.Choices, ", "), txt,
+		)
+	}
+	return "", ErrUnknownLabelType
+}
+
+// ValidateEdit checks the edited values of a submission along with its
+// current ones and returns the canonical values of the edited labels.
+func ValidateEdit(labels []Label, current, edits map[int64][]string) (map[int64][]string, error) {
+	// entries of labels the version does not know are left alone
+	known := map[int64]bool{}
+	for _, label := range labels {
+		known[label.ID] = true
+	}
+	merged := map[int64][]string{}
+	for labelID, values := range current {
+		if known[labelID] {
+			merged[labelID] = values
+		}
+	}
+	for labelID, values := range edits {
+		merged[labelID] = values
+	}
+	canonical, err := ValidateSubmission(labels, merged)
+	if err != nil {
+		return nil, err
+	}
+	edited := map[int64][]string{}
+	for labelID := range edits {
+		edited[labelID] = append([]string{}, canonical[labelID]...)
+	}
+	return edited, nil
+}
+
+// ErrEmptyEntry ...
+var ErrEmptyEntry error = errors.New("an entry can not be empty, delete it instead")
+
+// entryIndex is the index of entry among the values of its label.
+func entryIndex(entries []Entry, entry Entry) int {
+	i := 0
+	for _, e := range entries {
+		if e.ID == entry.ID {
+			break
+		}
+		if e.LabelID == entry.LabelID {
+			i++
+		}
+	}
+	return i
+}
+
+// entryValues groups entries by their label.
+func entryValues(entries []Entry) map[int64][]string {
+	values := map[int64][]string{}
+	for _, entry := range entries {
+		values[entry.LabelID] = append(values[entry.LabelID], entry.Txt)
+	}
+	return values
+}
+
+func sameValues(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// ValidateSubmission checks values, keyed by label id, against the form's
+// labels. It fills in defaults for labels left empty, fails when a required
+// label is still missing and returns the canonical values to be stored.
+func ValidateSubmission(labels []Label, values map[int64][]string) (map[int64][]string, error) {
+	known := map[int64]bool{}
+	for _, label := range labels {
+		known[label.ID] = true
+	}
+	for labelID := range values {
+		if !known[labelID] {
+			return nil, fmt.Errorf("label with label_id:%v is not part of the form", labelID)
+		}
+	}
+	canonical := map[int64][]string{}
+	for _, label := range labels {
+		txts := []string{}
+		for _, txt := range values[label.ID] {
+			if txt == "" {
+				continue
+			}
+			txt, err := ValidateEntry(label, txt)
+			if err != nil {
+				return nil, err
+			}
+			txts = append(txts, txt)
+		}
+		if len(txts) == 0 && label.Default != "" {
+			txts = append(txts, label.Default)
+		}
+		if len(txts) == 0 && label.Required {
+			return nil, fmt.Errorf("label '%s' is required", label.Name)
+		}
+		if len(txts) > 1 && !label.Repeatable {
+			return nil, fmt.Errorf("label '%s' is not repeatable but got %v values", label.Name, len(txts))
+		}
+		if len(txts) != 0 {
+			canonical[label.ID] = txts
+		}
+	}
+	return canonical, nil
+}
+
+// orderLabels places labels at their index in the slice and checks that
+// labels with an id are among the stored labels of the form and that names
+// are unique once all of them are saved, so labels can swap names.
+func orderLabels(formID int64, stored, labels []Label) ([]Label, error) {
+	known := map[int64]bool{}
+	for _, label := range stored {
+		known[label.ID] = true
+	}
+	ordered := []Label{}
+	ids := map[int64]bool{}
+	names := map[string]bool{}
+	for i, label := range labels {
+		if label.ID != 0 && !known[label.ID] {
+			return nil, fmt.Errorf("label with label_id:%v is not part of the form", label.ID)
+		}
+		if label.ID != 0 && ids[label.ID] {
+			return nil, fmt.Errorf("label with label_id:%v is saved more than once", label.ID)
+		}
+		if names[label.Name] {
+			return nil, fmt.Errorf("label '%s' is defined more than once", label.Name)
+		}
+		ids[label.ID] = true
+		names[label.Name] = true
+		label.FormID = formID
+		label.Position = int64(i + 1)
+		ordered = append(ordered, label)
+	}
+	return ordered, nil
+}
@@ -0,0 +1,227 @@
+package formly
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Exporter writes a form's submissions in some file format.
+type Exporter interface {
+	Export(w io.Writer, table ExportTable) error
+}
+
+// ErrUnknownExporter ...
+var ErrUnknownExporter error = errors.New("unknown export format")
+
+var exporters = map[string]Exporter{}
+
+// RegisterExporter makes an exporter available under name. It panics when
+// name is already taken, like registering a sql driver twice does.
+func RegisterExporter(name string, exporter Exporter) {
+	if _, ok := exporters[name]; ok {
+		panic("formly: RegisterExporter called twice for " + name)
+	}
+	exporters[name] = exporter
+}
+
+// GetExporter ...
+func GetExporter(name string) (Exporter, error) {
+	exporter, ok := exporters[name]
+	if !ok {
+		return nil, fmt.Errorf("%w '%s', expected one of %s", ErrUnknownExporter, name, strings.Join(Exporters(), ", "))
+	}
+	return exporter, nil
+}
+
+// Exporters lists the names of the registered exporters.
+func Exporters() []string {
+	names := []string{}
+	for name := range exporters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ExportOptions ...
+type ExportOptions struct {
+	// Expand puts every value of a repeatable label on its own row instead
+	// of joining them into one cell. A submission with values in several
+	// repeatable labels gets a row for every combination of them, so its
+	// rows multiply with each such label.
+	Expand bool
+}
+
+// ExportTable is a form's submissions laid out with a column per label.
+// The columns hold the current labels in order, followed by labels only
+// earlier versions of the form had.
+type ExportTable struct {
+	Form    Form
+	Labels  []Label
+	Columns []string
+	Rows    []ExportRow
+}
+
+// ExportRow holds the values of a submission, one slice per label. Values
+// only hold more than one value for repeatable labels that were not
+// expanded.
+type ExportRow struct {
+	SubmissionID int64
+	CreatedAt    time.Time
+	Values       [][]string
+}
+
+// ExportSeparator joins the values of a repeatable label into one cell.
+const ExportSeparator = "; "
+
+var valueEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`)
+
+// JoinValues joins values with ExportSeparator, escaping every backslash
+// and ';' in them with a backslash so that SplitValues gets them back as
+// they were.
+func JoinValues(values []string) string {
+	escaped := make([]string, len(values))
+	for i, value := range values {
+		escaped[i] = valueEscaper.Replace(value)
+	}
+	return strings.Join(escaped, ExportSeparator)
+}
+
+// SplitValues splits a cell written by JoinValues into its values. Only a
+// backslash before another one or a ';' escapes it, so cells written by
+// hand keep their other backslashes.
+func SplitValues(cell string) []string {
+	values := []string{}
+	value := strings.Builder{}
+	for i := 0; i < len(cell); i++ {
+		switch {
+		case cell[i] == '\\' && i+1 < len(cell) && (cell[i+1] == '\\' || cell[i+1] == ';'):
+			i++
+			value.WriteByte(cell[i])
+		case strings.HasPrefix(cell[i:], ExportSeparator):
+			values = append(values, value.String())
+			value.Reset()
+			i += len(ExportSeparator) - 1
+		default:
+			value.WriteByte(cell[i])
+		}
+	}
+	return append(values, value.String())
+}
+
+// Cells is the row as text, in the order of the table's columns, for
+// reading. The values of a repeatable label are joined as they are, see
+// JoinValues for cells that have to be split again.
+func (row ExportRow) Cells() []string {
+	cells := []string{strconv.FormatInt(row.SubmissionID, 10), row.CreatedAt.UTC().Format(time.RFC3339)}
+	for _, values := range row.Values {
+		cells = append(cells, strings.Join(values, ExportSeparator))
+	}
+	return cells
+}
+
+// NewExportTable collects the submissions selected by query for exporting.
+func NewExportTable(env *Env, query SubmissionQuery, options ExportOptions) (ExportTable, error) {
+	form, err := env.FormModel.GetByID(query.FormID)
+	if err != nil {
+		return ExportTable{}, err
+	}
+	versions, err := env.FormModel.GetVersions(query.FormID)
+	if err != nil {
+		return ExportTable{}, err
+	}
+	table := ExportTable{Form: form, Labels: []Label{}, Rows: []ExportRow{}}
+	// walking the versions from the newest keeps the current labels first
+	// and names older labels after the last version that had them
+	column := map[int64]int{}
+	for i := len(versions) - 1; i >= 0; i-- {
+		for _, label := range versions[i].Labels {
+			if _, ok := column[label.ID]; ok {
+				continue
+			}
+			column[label.ID] = len(table.Labels)
+			table.Labels = append(table.Labels, label)
+		}
+	}
+	table.Columns = exportColumns(table.Labels)
+	submissions, err := env.SubmissionModel.Query(query)
+	if err != nil {
+		return ExportTable{}, err
+	}
+	entries, err := env.SubmissionModel.QueryEntries(query)
+	if err != nil {
+		return ExportTable{}, err
+	}
+	// the entries come in the order of their submissions, so each row takes
+	// the entries up to the first of the next submission
+	next := 0
+	for _, submission := range submissions {
+		values := make([][]string, len(table.Labels))
+		for i := range values {
+			values[i] = []string{}
+		}
+		for ; next < len(entries) && entries[next].SubmissionID == submission.ID; next++ {
+			entry := entries[next]
+			if i, ok := column[entry.LabelID]; ok {
+				values[i] = append(values[i], entry.Txt)
+			}
+		}
+		row := ExportRow{SubmissionID: submission.ID, CreatedAt: submission.CreateAt, Values: values}
+		if !options.Expand {
+			table.Rows = append(table.Rows, row)
+			continue
+		}
+		table.Rows = append(table.Rows, expandRow(row)...)
+	}
+	return table, nil
+}
+
+// exportColumns names the columns, a label sharing its name with a column
+// before it gets its id appended.
+func exportColumns(labels []Label) []string {
+	columns := []string{"submission_id", "created_at"}
+	taken := map[string]bool{"submission_id": true, "created_at": true}
+	for _, label := range labels {
+		name := label.Name
+		if taken[name] {
+			name = fmt.Sprintf("%s#%v", label.Name, label.ID)
+		}
+		taken[name] = true
+		columns = append(columns, name)
+	}
+	return columns
+}
+
+// expandRow turns a row into one row per combination of its values, the
+// product of the number of values of every label, so every cell of the
+// resulting rows holds at most one value.
+func expandRow(row ExportRow) []ExportRow {
+	rows := []ExportRow{{SubmissionID: row.SubmissionID, CreatedAt: row.CreatedAt, Values: [][]string{}}}
+	for _, values := range row.Values {
+		if len(values) <= 1 {
+			for i := range rows {
+				rows[i].Values = append(rows[i].Values, values)
+			}
+			continue
+		}
+		expanded := []ExportRow{}
+		for _, r := range rows {
+			for _, value := range values {
+				cells := make([][]string, len(r.Values), len(row.Values))
+				copy(cells, r.Values)
+				expanded = append(expanded, ExportRow{
+					SubmissionID: r.SubmissionID,
+					CreatedAt:    r.CreatedAt,
+					Values:       append(cells, []string{value}),
+				})
+			}
+		}
+		rows = expanded
+	}
+	return rows
+}
@@ -0,0 +1,141 @@
+package formly
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+)
+
+func init() {
+	RegisterExporter("csv", csvExporter{})
+	RegisterExporter("jsonl", jsonlExporter{})
+	RegisterExporter("md", markdownExporter{})
+	RegisterExporter("html", htmlExporter{})
+}
+
+type csvExporter struct{}
+
+func (csvExporter) Export(w io.Writer, table ExportTable) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(table.Columns); err != nil {
+		return err
+	}
+	for _, row := range table.Rows {
+		// import splits the cells of repeatable labels again
+		cells := row.Cells()
+		for i, label := range table.Labels {
+			if label.Repeatable {
+				cells[i+2] = JoinValues(row.Values[i])
+			}
+		}
+		if err := writer.Write(cells); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// jsonlExporter writes an object per row with the keys in column order.
+// Repeatable labels always hold an array, the other labels a string.
+type jsonlExporter struct{}
+
+func (jsonlExporter) Export(w io.Writer, table ExportTable) error {
+	for _, row := range table.Rows {
+		line := bytes.Buffer{}
+		line.WriteString("{")
+		cells := row.Cells()
+		for i, column := range table.Columns {
+			var value interface{} = cells[i]
+			switch {
+			case i == 0:
+				value = row.SubmissionID
+			case i >= 2 && table.Labels[i-2].Repeatable:
+				value = row.Values[i-2]
+			}
+			key, err := json.Marshal(column)
+			if err != nil {
+				return err
+			}
+			txt, err := json.Marshal(value)
+			if err != nil {
+				return err
+			}
+			if i != 0 {
+				line.WriteString(",")
+			}
+			line.Write(key)
+			line.WriteString(":")
+			line.Write(txt)
+		}
+		line.WriteString("}\n")
+		if _, err := w.Write(line.Bytes()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type markdownExporter struct{}
+
+var markdownEscaper = strings.NewReplacer("\\", "\\\\", "|", "\\|", "\r\n", "<br>", "\n", "<br>")
+
+func (markdownExporter) Export(w io.Writer, table ExportTable) error {
+	line := func(cells []string) error {
+		escaped := make([]string, len(cells))
+		for i, cell := range cells {
+			escaped[i] = markdownEscaper.Replace(cell)
+		}
+		_, err := fmt.Fprintf(w, "| %s |\n", strings.Join(escaped, " | "))
+		return err
+	}
+	if err := line(table.Columns); err != nil {
+		return err
+	}
+	rule := make([]string, len(table.Columns))
+	for i := range rule {
+		rule[i] = "---"
+	}
+	if err := line(rule); err != nil {
+		return err
+	}
+	for _, row := range table.Rows {
+		if err := line(row.Cells()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type htmlExporter struct{}
+
+var htmlTemplate = template.Must(template.New("export").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Form.Name}}</title>
+</head>
+<body>
+<h1>{{.Form.Name}}</h1>
+<p>{{.Form.Usage}}</p>
+<table>
+<thead>
+<tr>{{range .Columns}}<th>{{.}}</th>{{end}}</tr>
+</thead>
+<tbody>
+{{- range .Rows}}
+<tr>{{range .Cells}}<td>{{.}}</td>{{end}}</tr>
+{{- end}}
+</tbody>
+</table>
+</body>
+</html>
+`))
+
+func (htmlExporter) Export(w io.Writer, table ExportTable) error {
+	return htmlTemplate.Execute(w, table)
+}
@@ -0,0 +1,1388 @@
+// Package formlytest checks that an implementation of the formly models
+// behaves like the ones shipped with formly.
+package formlytest
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pablothedeveloper/formly"
+)
+
+// TestEnv runs the conformance suite. newEnv is called for every subtest
+// and has to return an empty env, which is closed once the subtest is done.
+func TestEnv(t *testing.T, newEnv func(t *testing.T) *formly.Env) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, env *formly.Env)
+	}{
+		{"Forms", testForms},
+		{"FormLimits", testFormLimits},
+		{"IDs", testIDs},
+		{"Labels", testLabels},
+		{"LabelPositions", testLabelPositions},
+		{"LabelTypes", testLabelTypes},
+		{"LabelConstraints", testLabelConstraints},
+		{"Submissions", testSubmissions},
+		{"Submit", testSubmit},
+		{"SubmitAll", testSubmitAll},
+		{"EditSubmissions", testEditSubmissions},
+		{"CascadeDeletes", testCascadeDeletes},
+		{"Versions", testVersions},
+		{"Save", testSave},
+		{"Export", testExport},
+		{"Search", testSearch},
+		{"Query", testQuery},
+		{"Stats", testStats},
+		{"Hooks", testHooks},
+		{"Webhooks", testWebhooks},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			env := newEnv(t)
+			defer env.Close()
+			tt.test(t, env)
+		})
+	}
+}
+
+func must(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func createForm(t *testing.T, env *formly.Env, name string) formly.Form {
+	t.Helper()
+	form, err := env.FormModel.Create(name, "usage of "+name)
+	must(t, err)
+	return form
+}
+
+func createLabel(t *testing.T, env *formly.Env, label formly.Label) formly.Label {
+	t.Helper()
+	if label.Usage == "" {
+		label.Usage = "usage of " + label.Name
+	}
+	label, err := env.LabelModel.Create(label)
+	must(t, err)
+	return label
+}
+
+func labelNames(t *testing.T, env *formly.Env, formID int64) string {
+	t.Helper()
+	labels, err := env.LabelModel.GetLabels(formID)
+	must(t, err)
+	names := []string{}
+	for i, label := range labels {
+		if label.Position != int64(i+1) {
+			t.Errorf("label '%s' has position %v, want %v", label.Name, label.Position, i+1)
+		}
+		names = append(names, label.Name)
+	}
+	return strings.Join(names, ",")
+}
+
+// testIDs checks that every table numbers its rows on its own, from 1, so
+// tests asserting ids hold for every env.
+func testIDs(t *testing.T, env *formly.Env) {
+	standup := createForm(t, env, "standup")
+	retro := createForm(t, env, "retro")
+	mood := createLabel(t, env, formly.Label{FormID: standup.ID, Position: 1, Name: "mood"})
+	done := createLabel(t, env, formly.Label{FormID: standup.ID, Position: 2, Name: "done", Repeatable: true})
+	first, err := env.SubmissionModel.Create(standup.ID)
+	must(t, err)
+	second, err := env.SubmissionModel.Create(standup.ID)
+	must(t, err)
+	entries := []int64{}
+	for _, value := range []struct {
+		submissionID, labelID int64
+		txt                   string
+	}{
+		{first.ID, mood.ID, "fine"},
+		{first.ID, done.ID, "review"},
+		{first.ID, done.ID, "deploy"},
+		{second.ID, mood.ID, "tired"},
+	} {
+		entry, err := env.EntryModel.Create(value.submissionID, value.labelID, value.txt)
+		must(t, err)
+		entries = append(entries, entry.ID)
+	}
+	got := fmt.Sprint(standup.ID, retro.ID, mood.ID, done.ID, first.ID, second.ID, entries)
+	if want := "1 2 1 2 1 2 [1 2 3 4]"; got != want {
+		t.Errorf("ids of forms, labels, submissions and entries = %s, want %s", got, want)
+	}
+}
+
+func testForms(t *testing.T, env *formly.Env) {
+	journal := createForm(t, env, "journal")
+	standup := createForm(t, env, "standup")
+	if journal.ID == 0 || journal.ID == standup.ID {
+		t.Fatalf("forms got ids %v and %v", journal.ID, standup.ID)
+	}
+	form, err := env.FormModel.GetByName("journal")
+	must(t, err)
+	if form != journal {
+		t.Errorf("GetByName = %v, want %v", form, journal)
+	}
+	form, err = env.FormModel.GetByID(standup.ID)
+	must(t, err)
+	if form != standup {
+		t.Errorf("GetByID = %v, want %v", form, standup)
+	}
+	if _, err := env.FormModel.GetByName("missing"); err != sql.ErrNoRows {
+		t.Errorf("GetByName of a missing form = %v, want %v", err, sql.ErrNoRows)
+	}
+	if _, err := env.FormModel.GetByID(standup.ID + 1000); err != sql.ErrNoRows {
+		t.Errorf("GetByID of a missing form = %v, want %v", err, sql.ErrNoRows)
+	}
+	if _, err := env.FormModel.Create("journal", "another journal"); err == nil {
+		t.Error("created two forms named journal")
+	}
+	form, err = env.FormModel.Update(journal.ID, "diary", "daily diary")
+	must(t, err)
+	if want := (formly.Form{ID: journal.ID, Name: "diary", Usage: "daily diary"}); form != want {
+		t.Errorf("Update = %v, want %v", form, want)
+	}
+	if _, err := env.FormModel.Update(journal.ID, "standup", "daily diary"); err == nil {
+		t.Error("renamed a form to the name of another form")
+	}
+	forms, err := env.FormModel.GetAll()
+	must(t, err)
+	if len(forms) != 2 || forms[0].Name != "diary" || forms[1].Name != "standup" {
+		t.Errorf("GetAll = %v, want diary and standup in creation order", forms)
+	}
+	form, err = env.FormModel.DeleteByName("diary")
+	must(t, err)
+	if form.ID != journal.ID {
+		t.Errorf("DeleteByName deleted %v, want %v", form, journal)
+	}
+	if _, err := env.FormModel.GetByID(journal.ID); err != sql.ErrNoRows {
+		t.Errorf("GetByID of a deleted form = %v, want %v", err, sql.ErrNoRows)
+	}
+	if _, err := env.FormModel.DeleteByID(journal.ID); err != sql.ErrNoRows {
+		t.Errorf("DeleteByID of a deleted form = %v, want %v", err, sql.ErrNoRows)
+	}
+}
+
+func testFormLimits(t *testing.T, env *formly.Env) {
+	for _, tt := range []struct{ name, usage string }{
+		{"", "empty name"},
+		{strings.Repeat("a", 17), "long name"},
+		{"short", "use"},
+		{"long", strings.Repeat("u", 253)},
+	} {
+		if _, err := env.FormModel.Create(tt.name, tt.usage); err == nil {
+			t.Errorf("created form with name '%s' and usage '%s'", tt.name, tt.usage)
+		}
+	}
+	if _, err := env.FormModel.Create(strings.Repeat("a", 16), strings.Repeat("u", 252)); err != nil {
+		t.Errorf("form of maximum length: %v", err)
+	}
+	form := createForm(t, env, "limits")
+	for _, label := range []formly.Label{
+		{FormID: form.ID, Position: 1, Name: "", Usage: "empty name"},
+		{FormID: form.ID, Position: 1, Name: strings.Repeat("a", 17), Usage: "long name"},
+		{FormID: form.ID, Position: 1, Name: "short", Usage: "use"},
+		{FormID: form.ID, Position: 0, Name: "position", Usage: "position below one"},
+		{FormID: form.ID + 1000, Position: 1, Name: "orphan", Usage: "missing form"},
+	} {
+		if _, err := env.LabelModel.Create(label); err == nil {
+			t.Errorf("created label %v", label)
+		}
+	}
+}
+
+func testLabels(t *testing.T, env *formly.Env) {
+	form := createForm(t, env, "journal")
+	mood := createLabel(t, env, formly.Label{FormID: form.ID, Position: 1, Name: "mood"})
+	if mood.ID == 0 || mood.Type != formly.TextLabel {
+		t.Errorf("Create = %v, want an id and the text type", mood)
+	}
+	createLabel(t, env, formly.Label{FormID: form.ID, Position: 2, Repeatable: true, Name: "tags"})
+	labels, err := env.LabelModel.GetLabels(form.ID)
+	must(t, err)
+	if len(labels) != 2 || labels[0].ID != mood.ID || !labels[1].Repeatable {
+		t.Errorf("GetLabels = %v", labels)
+	}
+	if _, err := env.LabelModel.GetLabels(form.ID + 1000); err == nil {
+		t.Error("got labels of a missing form")
+	}
+	mood.Usage = "mood of the day"
+	mood.Required = true
+	updated, err := env.LabelModel.Update(mood)
+	must(t, err)
+	if len(updated) != 1 || updated[0].Usage != mood.Usage || !updated[0].Required {
+		t.Errorf("Update = %v", updated)
+	}
+	mood.Name = "tags"
+	if _, err := env.LabelModel.Update(mood); err == nil {
+		t.Error("renamed a label to the name of another label")
+	}
+	deleted, err := env.LabelModel.DeleteByID(mood.ID)
+	must(t, err)
+	if deleted.ID != mood.ID || deleted.Name != "mood" {
+		t.Errorf("DeleteByID = %v", deleted)
+	}
+	if got := labelNames(t, env, form.ID); got != "tags" {
+		t.Errorf("labels after delete = %s, want tags", got)
+	}
+	if _, err := env.LabelModel.DeleteByID(mood.ID); err == nil {
+		t.Error("deleted a label twice")
+	}
+}
+
+func testLabelPositions(t *testing.T, env *formly.Env) {
+	form := createForm(t, env, "journal")
+	labels := []formly.Label{}
+	for i, name := range []string{"a", "b", "c", "d"} {
+		labels = append(labels, createLabel(t, env, formly.Label{FormID: form.ID, Position: int64(i + 1), Name: name}))
+	}
+	d := labels[3]
+	d.Position = 1
+	updated, err := env.LabelModel.Update(d)
+	must(t, err)
+	if len(updated) != 2 || updated[0].ID != d.ID || updated[1].ID != labels[0].ID || updated[1].Position != 4 {
+		t.Errorf("Update = %v, want d and a swapped", updated)
+	}
+	if got := labelNames(t, env, form.ID); got != "d,b,c,a" {
+		t.Errorf("labels after swap = %s, want d,b,c,a", got)
+	}
+	for _, position := range []int64{0, 5} {
+		d.Position = position
+		if _, err := env.LabelModel.Update(d); err == nil {
+			t.Errorf("moved label to position %v of 4", position)
+		}
+	}
+	_, err = env.LabelModel.DeleteByID(labels[1].ID)
+	must(t, err)
+	if got := labelNames(t, env, form.ID); got != "d,c,a" {
+		t.Errorf("labels after delete = %s, want d,c,a", got)
+	}
+}
+
+func testLabelTypes(t *testing.T, env *formly.Env) {
+	form := createForm(t, env, "journal")
+	mood := createLabel(t, env, formly.Label{FormID: form.ID, Position: 1, Type: formly.IntLabel, Name: "mood"})
+	color := createLabel(t, env, formly.Label{
+		FormID: form.ID, Position: 2, Type: formly.EnumLabel, Choices: []string{"Red", "Blue"}, Default: "red", Name: "color",
+	})
+	submission, err := env.SubmissionModel.Create(form.ID)
+	must(t, err)
+	if color.Default != "Red" {
+		t.Errorf("default = '%s', want it normalized to 'Red'", color.Default)
+	}
+	if _, err := env.LabelModel.Create(formly.Label{
+		FormID: form.ID, Position: 3, Type: formly.IntLabel, Default: "many", Name: "bad", Usage: "bad default",
+	}); err == nil {
+		t.Error("created an int label with a default that is not an integer")
+	}
+	if _, err := env.LabelModel.Create(formly.Label{
+		FormID: form.ID, Position: 3, Type: "color", Name: "bad", Usage: "bad type",
+	}); err == nil {
+		t.Error("created a label of an unknown type")
+	}
+	entry, err := env.EntryModel.Create(submission.ID, mood.ID, " 007")
+	must(t, err)
+	if entry.Txt != "7" {
+		t.Errorf("entry = '%s', want it normalized to '7'", entry.Txt)
+	}
+	if _, err := env.EntryModel.Create(submission.ID, mood.ID, "happy"); err == nil {
+		t.Error("created an int entry that is not an integer")
+	}
+	if _, err := env.EntryModel.Create(submission.ID, color.ID, "green"); err == nil {
+		t.Error("created an enum entry that is not one of the choices")
+	}
+	// the entry belongs to the version the submission was made with, which
+	// keeps the label's type, so a new type leaves it as it is
+	mood.Type = formly.BoolLabel
+	_, err = env.LabelModel.Update(mood)
+	must(t, err)
+	labels, err := env.LabelModel.GetLabels(form.ID)
+	must(t, err)
+	if labels[0].Type != formly.BoolLabel || strings.Join(labels[1].Choices, ",") != "Red,Blue" {
+		t.Errorf("GetLabels = %v", labels)
+	}
+	entries, err := env.EntryModel.GetEntries(submission.ID, mood.ID)
+	must(t, err)
+	if len(entries) != 1 || entries[0].Txt != "7" {
+		t.Errorf("entries of an earlier version = %v, want them unchanged", entries)
+	}
+	version, err := env.FormModel.GetVersion(form.ID, submission.Version)
+	must(t, err)
+	if version.Labels[0].Type != formly.IntLabel {
+		t.Errorf("label of the submission's version = %v, want it to stay an int", version.Labels[0])
+	}
+}
+
+func testLabelConstraints(t *testing.T, env *formly.Env) {
+	form := createForm(t, env, "journal")
+	submission, err := env.SubmissionModel.Create(form.ID)
+	must(t, err)
+	min, max := 1.0, 10.0
+	mood := createLabel(t, env, formly.Label{
+		FormID: form.ID, Position: 1, Type: formly.IntLabel, Name: "mood",
+		Constraints: formly.Constraints{Min: &min, Max: &max},
+	})
+	word := createLabel(t, env, formly.Label{
+		FormID: form.ID, Position: 2, Name: "word",
+		Constraints: formly.Constraints{Pattern: "[a-z]+", MinLength: 2, MaxLength: 4},
+	})
+	labels, err := env.LabelModel.GetLabels(form.ID)
+	must(t, err)
+	if c := labels[0].Constraints; c.Min == nil || *c.Min != min || c.Max == nil || *c.Max != max {
+		t.Errorf("constraints of mood = %v", c)
+	}
+	if c := labels[1].Constraints; c.Pattern != "[a-z]+" || c.MinLength != 2 || c.MaxLength != 4 || c.Min != nil {
+		t.Errorf("constraints of word = %v", c)
+	}
+	for _, tt := range []struct {
+		label formly.Label
+		txt   string
+		ok    bool
+	}{
+		{mood, "5", true},
+		{mood, "0", false},
+		{mood, "11", false},
+		{word, "abc", true},
+		{word, "a", false},
+		{word, "abcde", false},
+		{word, "ABC", false},
+	} {
+		_, err := env.EntryModel.Create(submission.ID, tt.label.ID, tt.txt)
+		if (err == nil) != tt.ok {
+			t.Errorf("entry '%s' for %s: err = %v, want ok = %v", tt.txt, tt.label.Name, err, tt.ok)
+		}
+	}
+	if _, err := env.LabelModel.Create(formly.Label{
+		FormID: form.ID, Position: 3, Name: "bad", Usage: "range on text",
+		Constraints: formly.Constraints{Min: &min},
+	}); err == nil {
+		t.Error("created a text label with a min")
+	}
+	word.Constraints = formly.Constraints{}
+	_, err = env.LabelModel.Update(word)
+	must(t, err)
+	labels, err = env.LabelModel.GetLabels(form.ID)
+	must(t, err)
+	if labels[1].Constraints != (formly.Constraints{}) {
+		t.Errorf("constraints of word after clearing them = %v", labels[1].Constraints)
+	}
+}
+
+func testSubmissions(t *testing.T, env *formly.Env) {
+	form := createForm(t, env, "journal")
+	if _, err := env.SubmissionModel.Create(form.ID + 1000); err == nil {
+		t.Error("created a submission for a missing form")
+	}
+	first, err := env.SubmissionModel.Create(form.ID)
+	must(t, err)
+	if first.ID == 0 || first.FormID != form.ID || first.CreateAt.IsZero() {
+		t.Errorf("Create = %v", first)
+	}
+	second, err := env.SubmissionModel.Create(form.ID)
+	must(t, err)
+	submissions, err := env.SubmissionModel.GetSubmissions(form.ID)
+	must(t, err)
+	if len(submissions) != 2 || submissions[0].ID != first.ID || submissions[1].ID != second.ID {
+		t.Errorf("GetSubmissions = %v", submissions)
+	}
+	if _, err := env.SubmissionModel.GetSubmissions(form.ID + 1000); err == nil {
+		t.Error("got submissions of a missing form")
+	}
+	label := createLabel(t, env, formly.Label{FormID: form.ID, Position: 1, Repeatable: true, Name: "tags"})
+	for _, txt := range []string{"a", "b"} {
+		_, err := env.EntryModel.Create(first.ID, label.ID, txt)
+		must(t, err)
+	}
+	if _, err := env.EntryModel.Create(first.ID, label.ID+1000, "c"); err == nil {
+		t.Error("created an entry for a missing label")
+	}
+	entries, err := env.EntryModel.GetEntries(first.ID, label.ID)
+	must(t, err)
+	if len(entries) != 2 || entries[0].Txt != "a" || entries[1].Txt != "b" {
+		t.Errorf("GetEntries = %v", entries)
+	}
+	count, err := env.EntryModel.CountEntries(label.ID)
+	must(t, err)
+	if count != 2 {
+		t.Errorf("CountEntries = %v, want 2", count)
+	}
+}
+
+func testSubmit(t *testing.T, env *formly.Env) {
+	form := createForm(t, env, "journal")
+	mood := createLabel(t, env, formly.Label{FormID: form.ID, Position: 1, Name: "mood", Type: formly.IntLabel, Required: true})
+	tags := createLabel(t, env, formly.Label{FormID: form.ID, Position: 2, Repeatable: true, Name: "tags"})
+	late := createLabel(t, env, formly.Label{FormID: form.ID, Position: 3, Name: "late", Type: formly.BoolLabel, Default: "false"})
+	if _, err := env.Submit(form.ID+1000, nil); err == nil {
+		t.Error("submitted a missing form")
+	}
+	for _, values := range []map[int64][]string{
+		{tags.ID: {"a"}},
+		{mood.ID: {"many"}},
+		{mood.ID: {"1"}, tags.ID: {"a"}, tags.ID + 1000: {"b"}},
+		{mood.ID: {"1", "2"}},
+	} {
+		if _, err := env.Submit(form.ID, values); err == nil {
+			t.Errorf("Submit(%v) succeeded", values)
+		}
+	}
+	submissions, err := env.SubmissionModel.GetSubmissions(form.ID)
+	must(t, err)
+	if len(submissions) != 0 {
+		t.Errorf("failed submits left %v submissions", len(submissions))
+	}
+	submission, err := env.Submit(form.ID, map[int64][]string{tags.ID: {"a", "", "b"}, mood.ID: {" 7 "}})
+	must(t, err)
+	if submission.ID == 0 || submission.FormID != form.ID || submission.CreateAt.IsZero() || submission.Version != 4 {
+		t.Errorf("Submit = %v", submission)
+	}
+	got := []string{}
+	for _, entry := range submission.Entries {
+		if entry.ID == 0 || entry.SubmissionID != submission.ID {
+			t.Errorf("Submit entry = %v", entry)
+		}
+		got = append(got, entry.Txt)
+	}
+	if want := "7,a,b,false"; strings.Join(got, ",") != want {
+		t.Errorf("Submit entries = %v, want %v", strings.Join(got, ","), want)
+	}
+	entries, err := env.EntryModel.GetEntries(submission.ID, late.ID)
+	must(t, err)
+	if len(entries) != 1 || entries[0] != submission.Entries[3] {
+		t.Errorf("GetEntries = %v, want %v", entries, submission.Entries[3])
+	}
+}
+
+func testSubmitAll(t *testing.T, env *formly.Env) {
+	form := createForm(t, env, "journal")
+	mood := createLabel(t, env, formly.Label{FormID: form.ID, Position: 1, Name: "mood", Type: formly.IntLabel})
+	if _, err := env.SubmitAll(form.ID, []formly.NewSubmission{
+		{Values: map[int64][]string{mood.ID: {"1"}}},
+		{Values: map[int64][]string{mood.ID: {"many"}}},
+	}); err == nil {
+		t.Error("SubmitAll with an invalid submission succeeded")
+	}
+	submissions, err := env.SubmissionModel.GetSubmissions(form.ID)
+	must(t, err)
+	if len(submissions) != 0 {
+		t.Errorf("failed SubmitAll left %v submissions", len(submissions))
+	}
+	old := time.Date(2019, 3, 1, 10, 11, 12, 500, time.FixedZone("", 2*60*60))
+	stored, err := env.SubmitAll(form.ID, []formly.NewSubmission{
+		{Values: map[int64][]string{mood.ID: {"1"}}},
+		{CreateAt: old, Values: map[int64][]string{mood.ID: {" 2"}}},
+	})
+	must(t, err)
+	if len(stored) != 2 || stored[1].Entries[0].Txt != "2" {
+		t.Fatalf("SubmitAll = %v", stored)
+	}
+	if want := time.Date(2019, 3, 1, 8, 11, 12, 0, time.UTC); !stored[1].CreateAt.Equal(want) {
+		t.Errorf("CreateAt = %v, want %v", stored[1].CreateAt, want)
+	}
+	submissions, err = env.SubmissionModel.GetSubmissions(form.ID)
+	must(t, err)
+	if len(submissions) != 2 || submissions[0].ID != stored[1].ID || !submissions[0].CreateAt.Equal(stored[1].CreateAt) {
+		t.Errorf("GetSubmissions = %v, want the imported submission first", submissions)
+	}
+}
+
+func entryTxts(submission formly.Submission) string {
+	txts := []string{}
+	for _, entry := range submission.Entries {
+		txts = append(txts, entry.Txt)
+	}
+	return strings.Join(txts, ",")
+}
+
+func testEditSubmissions(t *testing.T, env *formly.Env) {
+	form := createForm(t, env, "journal")
+	mood := createLabel(t, env, formly.Label{FormID: form.ID, Position: 1, Name: "mood", Type: formly.IntLabel, Required: true})
+	tags := createLabel(t, env, formly.Label{FormID: form.ID, Position: 2, Repeatable: true, Name: "tags"})
+	late := createLabel(t, env, formly.Label{FormID: form.ID, Position: 3, Name: "late", Type: formly.BoolLabel, Default: "false"})
+	submission, err := env.Submit(form.ID, map[int64][]string{mood.ID: {"1"}, tags.ID: {"a", "b"}, late.ID: {"true"}})
+	must(t, err)
+	got, err := env.SubmissionModel.GetByID(submission.ID)
+	must(t, err)
+	if entryTxts(got) != "1,a,b,true" || got.Version != submission.Version {
+		t.Errorf("GetByID = %v", got)
+	}
+	if _, err := env.SubmissionModel.GetByID(submission.ID + 1000); err != sql.ErrNoRows {
+		t.Errorf("GetByID of a missing submission = %v, want sql.ErrNoRows", err)
+	}
+	for _, values := range []map[int64][]string{
+		{mood.ID: {}},
+		{mood.ID: {"many"}},
+		{mood.ID + 1000: {"1"}},
+	} {
+		if _, err := env.SubmissionModel.Edit(submission.ID, values); err == nil {
+			t.Errorf("Edit(%v) succeeded", values)
+		}
+	}
+	// edits are checked against the submission's version, which still has
+	// the label deleted since
+	_, err = env.LabelModel.DeleteByID(tags.ID)
+	must(t, err)
+	edited, err := env.SubmissionModel.Edit(submission.ID, map[int64][]string{mood.ID: {" 2"}, tags.ID: {"c", "a"}})
+	must(t, err)
+	if entryTxts(edited) != "2,c,a,true" {
+		t.Errorf("Edit = %v", entryTxts(edited))
+	}
+	if _, err := env.SubmissionModel.Edit(submission.ID, map[int64][]string{mood.ID: {"2"}}); err != nil {
+		t.Error(err)
+	}
+	lateEntry := edited.Entries[3]
+	if _, err := env.EntryModel.Update(lateEntry.ID, "nope"); err == nil {
+		t.Error("updated a bool entry to 'nope'")
+	}
+	if _, err := env.EntryModel.Update(lateEntry.ID, ""); err == nil {
+		t.Error("updated an entry to nothing")
+	}
+	entry, err := env.EntryModel.Update(edited.Entries[1].ID, "d")
+	must(t, err)
+	if entry.ID != edited.Entries[1].ID || entry.Txt != "d" {
+		t.Errorf("Update = %v", entry)
+	}
+	if _, err := env.EntryModel.DeleteByID(edited.Entries[0].ID); err == nil {
+		t.Error("deleted the only value of a required label")
+	}
+	_, err = env.EntryModel.DeleteByID(edited.Entries[2].ID)
+	must(t, err)
+	_, err = env.EntryModel.DeleteByID(lateEntry.ID)
+	must(t, err)
+	got, err = env.SubmissionModel.GetByID(submission.ID)
+	must(t, err)
+	if entryTxts(got) != "2,d,false" {
+		t.Errorf("after the entry changes GetByID = %v, want 2,d,false", entryTxts(got))
+	}
+	changes, err := env.SubmissionModel.GetChanges(submission.ID)
+	must(t, err)
+	history := []string{}
+	for _, change := range changes {
+		if change.SubmissionID != submission.ID || change.ChangedAt.IsZero() {
+			t.Errorf("change = %v", change)
+		}
+		history = append(history, strings.Join(change.From, "|")+">"+strings.Join(change.To, "|"))
+	}
+	want := "1>2,a|b>c|a,c|a>d|a,d|a>d,true>false"
+	if strings.Join(history, ",") != want {
+		t.Errorf("GetChanges = %v, want %v", strings.Join(history, ","), want)
+	}
+	deleted, err := env.SubmissionModel.DeleteByID(submission.ID)
+	must(t, err)
+	if deleted.ID != submission.ID || entryTxts(deleted) != "2,d,false" {
+		t.Errorf("DeleteByID = %v", deleted)
+	}
+	if _, err := env.SubmissionModel.GetChanges(submission.ID); err != sql.ErrNoRows {
+		t.Errorf("GetChanges of a deleted submission = %v, want sql.ErrNoRows", err)
+	}
+	submissions, err := env.SubmissionModel.GetSubmissions(form.ID)
+	must(t, err)
+	if len(submissions) != 0 {
+		t.Errorf("GetSubmissions after the delete = %v", submissions)
+	}
+}
+
+func testCascadeDeletes(t *testing.T, env *formly.Env) {
+	form := createForm(t, env, "journal")
+	submission, err := env.SubmissionModel.Create(form.ID)
+	must(t, err)
+	mood := createLabel(t, env, formly.Label{FormID: form.ID, Position: 1, Name: "mood"})
+	tags := createLabel(t, env, formly.Label{FormID: form.ID, Position: 2, Name: "tags"})
+	for _, label := range []formly.Label{mood, tags} {
+		_, err := env.EntryModel.Create(submission.ID, label.ID, "txt")
+		must(t, err)
+	}
+	_, err = env.LabelModel.DeleteByID(mood.ID)
+	must(t, err)
+	if count, err := env.EntryModel.CountEntries(mood.ID); err != nil || count != 1 {
+		t.Errorf("entries of a deleted label = %v, %v, want them kept for earlier versions", count, err)
+	}
+	if _, err := env.EntryModel.Create(submission.ID, mood.ID, "txt"); err == nil {
+		t.Error("created an entry for a deleted label")
+	}
+	_, err = env.FormModel.DeleteByID(form.ID)
+	must(t, err)
+	if count, err := env.EntryModel.CountEntries(tags.ID); err != nil || count != 0 {
+		t.Errorf("entries of a deleted form = %v, %v", count, err)
+	}
+	if entries, err := env.EntryModel.GetEntries(submission.ID, tags.ID); err != nil || len(entries) != 0 {
+		t.Errorf("entries of a deleted submission = %v, %v", entries, err)
+	}
+	if _, err := env.SubmissionModel.GetSubmissions(form.ID); err == nil {
+		t.Error("got submissions of a deleted form")
+	}
+	if _, err := env.LabelModel.DeleteByID(tags.ID); err == nil {
+		t.Error("deleted a label of a deleted form")
+	}
+	form = createForm(t, env, "journal")
+	if got := labelNames(t, env, form.ID); got != "" {
+		t.Errorf("labels of a recreated form = %s, want none", got)
+	}
+}
+
+func versionNames(version formly.FormVersion) string {
+	names := []string{}
+	for _, label := range version.Labels {
+		names = append(names, label.Name)
+	}
+	return strings.Join(names, ",")
+}
+
+func testVersions(t *testing.T, env *formly.Env) {
+	form := createForm(t, env, "journal")
+	versions, err := env.FormModel.GetVersions(form.ID)
+	must(t, err)
+	if len(versions) != 1 || versions[0].Version != 1 || len(versions[0].Labels) != 0 {
+		t.Fatalf("versions of a new form = %v", versions)
+	}
+	mood := createLabel(t, env, formly.Label{FormID: form.ID, Position: 1, Name: "mood"})
+	tags := createLabel(t, env, formly.Label{FormID: form.ID, Position: 2, Repeatable: true, Name: "tags"})
+	first, err := env.SubmissionModel.Create(form.ID)
+	must(t, err)
+	if first.Version != 3 {
+		t.Errorf("submission made after adding two labels has version %v, want 3", first.Version)
+	}
+	_, err = env.EntryModel.Create(first.ID, tags.ID, "gym")
+	must(t, err)
+	mood.Name = "feeling"
+	mood.Position = 2
+	_, err = env.LabelModel.Update(mood)
+	must(t, err)
+	_, err = env.LabelModel.DeleteByID(tags.ID)
+	must(t, err)
+	second, err := env.SubmissionModel.Create(form.ID)
+	must(t, err)
+	if second.Version != 5 {
+		t.Errorf("submission made after a rename and a delete has version %v, want 5", second.Version)
+	}
+	versions, err = env.FormModel.GetVersions(form.ID)
+	must(t, err)
+	want := []string{"", "mood", "mood,tags", "tags,feeling", "feeling"}
+	if len(versions) != len(want) {
+		t.Fatalf("got %v versions, want %v", len(versions), len(want))
+	}
+	for i, version := range versions {
+		if version.Version != int64(i+1) || version.FormID != form.ID || version.CreatedAt.IsZero() {
+			t.Errorf("version %v = %v", i+1, version)
+		}
+		if got := versionNames(version); got != want[i] {
+			t.Errorf("labels of version %v = %s, want %s", i+1, got, want[i])
+		}
+	}
+	version, err := env.FormModel.GetVersion(form.ID, first.Version)
+	must(t, err)
+	if got := versionNames(version); got != "mood,tags" || !version.Labels[1].Repeatable {
+		t.Errorf("labels of the version of the first submission = %v", version.Labels)
+	}
+	entries, err := env.EntryModel.GetEntries(first.ID, tags.ID)
+	must(t, err)
+	if len(entries) != 1 || entries[0].Txt != "gym" {
+		t.Errorf("entries of a deleted label = %v, want them kept", entries)
+	}
+	if _, err := env.FormModel.GetVersion(form.ID, 6); err != sql.ErrNoRows {
+		t.Errorf("GetVersion of a missing version = %v, want %v", err, sql.ErrNoRows)
+	}
+	if _, err := env.FormModel.GetVersions(form.ID + 1000); err == nil {
+		t.Error("got versions of a missing form")
+	}
+}
+
+func testSave(t *testing.T, env *formly.Env) {
+	form, labels, err := env.FormModel.Save(formly.Form{Name: "journal", Usage: "usage of journal"}, []formly.Label{
+		{Name: "mood", Usage: "usage of mood"},
+		{Name: "note", Usage: "usage of note"},
+		{Name: "tags", Usage: "usage of tags", Repeatable: true},
+	})
+	must(t, err)
+	if got := labelNames(t, env, form.ID); got != "mood,note,tags" || len(labels) != 3 {
+		t.Fatalf("labels of a saved form = %s", got)
+	}
+	_, err = env.Submit(form.ID, map[int64][]string{labels[0].ID: {"many"}})
+	must(t, err)
+	versions := func() int {
+		t.Helper()
+		versions, err := env.FormModel.GetVersions(form.ID)
+		must(t, err)
+		return len(versions)
+	}
+	if n := versions(); n != 1 {
+		t.Errorf("a saved form has %v versions, want 1", n)
+	}
+	// the second label has an unknown type, so the first is not renamed
+	// either
+	broken := append([]formly.Label{}, labels[:2]...)
+	broken[0].Name = "text"
+	broken[1].Type = "colour"
+	if _, _, err := env.FormModel.Save(form, broken); err == nil {
+		t.Error("saved a label of an unknown type")
+	}
+	if got := labelNames(t, env, form.ID); got != "mood,note,tags" || versions() != 1 {
+		t.Errorf("labels after a failed save = %s with %v versions, want them unchanged", got, versions())
+	}
+	swapped := []formly.Label{labels[1], labels[0], labels[2]}
+	swapped[0].Name, swapped[1].Name = "mood", "note"
+	swapped = append(swapped, formly.Label{Name: "day", Usage: "usage of day", Type: formly.DateLabel})
+	if _, _, err := env.FormModel.Save(form, swapped); err != nil {
+		t.Errorf("swapping the names of two labels: %v", err)
+	}
+	if got := labelNames(t, env, form.ID); got != "mood,note,tags,day" || versions() != 2 {
+		t.Errorf("labels after swapping two names = %s with %v versions", got, versions())
+	}
+	current, err := env.LabelModel.GetLabels(form.ID)
+	must(t, err)
+	if current[0].ID != labels[1].ID || current[1].ID != labels[0].ID {
+		t.Errorf("labels after swapping two names = %v", current)
+	}
+	if _, _, err := env.FormModel.Save(form, current); err != nil || versions() != 2 {
+		t.Errorf("saving the labels unchanged = %v with %v versions, want no new version", err, versions())
+	}
+	for _, labels := range [][]formly.Label{
+		{current[0], current[0]},
+		{current[0], {Name: "mood", Usage: "usage of mood"}},
+		{{ID: current[0].ID + 1000, Name: "other", Usage: "usage of other"}},
+	} {
+		if _, _, err := env.FormModel.Save(form, labels); err == nil {
+			t.Errorf("saved %v", labels)
+		}
+	}
+	if _, _, err := env.FormModel.Save(formly.Form{ID: form.ID + 1000, Name: "missing", Usage: "usage of missing"}, nil); err == nil {
+		t.Error("saved a missing form")
+	}
+}
+
+func testExport(t *testing.T, env *formly.Env) {
+	form := createForm(t, env, "journal")
+	mood := createLabel(t, env, formly.Label{FormID: form.ID, Position: 1, Name: "mood"})
+	tags := createLabel(t, env, formly.Label{FormID: form.ID, Position: 2, Repeatable: true, Name: "tags"})
+	first, err := env.Submit(form.ID, map[int64][]string{mood.ID: {"ok"}, tags.ID: {"a", "b"}})
+	must(t, err)
+	_, err = env.LabelModel.DeleteByID(mood.ID)
+	must(t, err)
+	createLabel(t, env, formly.Label{FormID: form.ID, Position: 2, Name: "mood"})
+	table, err := formly.NewExportTable(env, formly.SubmissionQuery{FormID: form.ID}, formly.ExportOptions{})
+	must(t, err)
+	if got, want := strings.Join(table.Columns, ","), "submission_id,created_at,tags,mood,mood#"; !strings.HasPrefix(got, want) {
+		t.Errorf("Columns = %v, want %v<id>", got, want)
+	}
+	if len(table.Rows) != 1 || strings.Join(table.Rows[0].Cells()[2:], ",") != "a; b,,ok" {
+		t.Errorf("Rows = %v", table.Rows)
+	}
+	table, err = formly.NewExportTable(env, formly.SubmissionQuery{FormID: form.ID}, formly.ExportOptions{Expand: true})
+	must(t, err)
+	if len(table.Rows) != 2 || table.Rows[1].SubmissionID != first.ID || table.Rows[1].Cells()[2] != "b" {
+		t.Errorf("expanded Rows = %v", table.Rows)
+	}
+	table, err = formly.NewExportTable(env, formly.SubmissionQuery{FormID: form.ID, Since: first.CreateAt.Add(time.Second)}, formly.ExportOptions{})
+	must(t, err)
+	if len(table.Rows) != 0 {
+		t.Errorf("Rows since after the submission = %v", table.Rows)
+	}
+	values := []string{`a; b\c`, "d;", ""}
+	_, err = env.Submit(form.ID, map[int64][]string{tags.ID: values})
+	must(t, err)
+	table, err = formly.NewExportTable(env, formly.SubmissionQuery{FormID: form.ID}, formly.ExportOptions{})
+	must(t, err)
+	exporter, err := formly.GetExporter("csv")
+	must(t, err)
+	csv := strings.Builder{}
+	must(t, exporter.Export(&csv, table))
+	if got, want := strings.Split(csv.String(), "\n")[2], `,a\; b\\c; d\;,`; !strings.Contains(got, want) {
+		t.Errorf("csv row of values holding the separator = %s, want it to hold %s", got, want)
+	}
+	if got := formly.SplitValues(formly.JoinValues(values)); strings.Join(got, "|") != "a; b\\c|d;|" {
+		t.Errorf("SplitValues(JoinValues(%q)) = %q", values, got)
+	}
+	// expanding two repeatable labels takes every combination of their values
+	people := createLabel(t, env, formly.Label{FormID: form.ID, Position: 3, Repeatable: true, Name: "people"})
+	last, err := env.Submit(form.ID, map[int64][]string{tags.ID: {"a", "b"}, people.ID: {"x", "y", "z"}})
+	must(t, err)
+	table, err = formly.NewExportTable(env, formly.SubmissionQuery{FormID: form.ID}, formly.ExportOptions{Expand: true})
+	must(t, err)
+	rows := 0
+	for _, row := range table.Rows {
+		if row.SubmissionID == last.ID {
+			rows++
+		}
+	}
+	if rows != 6 {
+		t.Errorf("expanded Rows of two repeatable labels = %v, want 6 for the last submission", table.Rows)
+	}
+}
+
+func searchHighlights(t *testing.T, env *formly.Env, query string, options formly.SearchOptions) string {
+	t.Helper()
+	results, err := env.Search(query, options)
+	must(t, err)
+	highlights := []string{}
+	for _, result := range results {
+		highlights = append(highlights, result.Highlight)
+	}
+	return strings.Join(highlights, "|")
+}
+
+func testSearch(t *testing.T, env *formly.Env) {
+	journal := createForm(t, env, "journal")
+	note := createLabel(t, env, formly.Label{FormID: journal.ID, Position: 1, Name: "note", Repeatable: true})
+	title := createLabel(t, env, formly.Label{FormID: journal.ID, Position: 2, Name: "title"})
+	worklog := createForm(t, env, "worklog")
+	done := createLabel(t, env, formly.Label{FormID: worklog.ID, Position: 1, Name: "note"})
+	submission, err := env.Submit(journal.ID, map[int64][]string{
+		note.ID:  {"the quick brown fox jumps over the lazy dog", "Fox and fox"},
+		title.ID: {"foxes"},
+	})
+	must(t, err)
+	_, err = env.Submit(worklog.ID, map[int64][]string{done.ID: {"fed the fox"}})
+	must(t, err)
+	for _, tt := range []struct {
+		query   string
+		options formly.SearchOptions
+		want    string
+	}{
+		{"fox", formly.SearchOptions{}, "**Fox** and **fox**|fed the **fox**|the quick brown **fox** jumps over the lazy dog"},
+		{"QUICK fox", formly.SearchOptions{}, "the **quick** brown **fox** jumps over the lazy dog"},
+		{"fox*", formly.SearchOptions{Form: "journal", Label: "title"}, "**foxes**"},
+		{"fox", formly.SearchOptions{Form: "journal", MarkStart: "<", MarkEnd: ">"}, "<Fox> and <fox>|the quick brown <fox> jumps over the lazy dog"},
+		{"fox", formly.SearchOptions{Label: "title"}, ""},
+		{"cat", formly.SearchOptions{}, ""},
+	} {
+		if got := searchHighlights(t, env, tt.query, tt.options); got != tt.want {
+			t.Errorf("Search(%q, %+v) = %q, want %q", tt.query, tt.options, got, tt.want)
+		}
+	}
+	results, err := env.Search("lazy", formly.SearchOptions{})
+	must(t, err)
+	if len(results) != 1 || results[0].SubmissionID != submission.ID || results[0].Form != "journal" ||
+		results[0].Label != "note" || !results[0].CreateAt.Equal(submission.CreateAt) {
+		t.Errorf("Search(lazy) = %+v", results)
+	}
+	if _, err := env.Search("  ", formly.SearchOptions{}); err != formly.ErrEmptySearch {
+		t.Errorf("Search of nothing = %v, want %v", err, formly.ErrEmptySearch)
+	}
+	// the search follows edits and deletes of the entries
+	_, err = env.SubmissionModel.Edit(submission.ID, map[int64][]string{title.ID: {"cats"}})
+	must(t, err)
+	_, err = env.FormModel.DeleteByName("worklog")
+	must(t, err)
+	if got := searchHighlights(t, env, "cat*", formly.SearchOptions{}); got != "**cats**" {
+		t.Errorf("Search(cat*) after an edit = %q, want **cats**", got)
+	}
+	if got := searchHighlights(t, env, "fed", formly.SearchOptions{}); got != "" {
+		t.Errorf("Search(fed) after deleting its form = %q, want nothing", got)
+	}
+}
+
+func testQuery(t *testing.T, env *formly.Env) {
+	form := createForm(t, env, "journal")
+	mood := createLabel(t, env, formly.Label{FormID: form.ID, Position: 1, Name: "mood", Type: formly.IntLabel})
+	tags := createLabel(t, env, formly.Label{FormID: form.ID, Position: 2, Name: "tags", Repeatable: true})
+	note := createLabel(t, env, formly.Label{FormID: form.ID, Position: 3, Name: "note"})
+	day := createLabel(t, env, formly.Label{FormID: form.ID, Position: 4, Name: "day", Type: formly.DateLabel})
+	stored, err := env.SubmitAll(form.ID, []formly.NewSubmission{
+		{
+			CreateAt: time.Date(2025, 12, 31, 10, 0, 0, 0, time.UTC),
+			Values:   map[int64][]string{mood.ID: {"9"}, tags.ID: {"gym"}, note.ID: {"ran"}, day.ID: {"2025-12-31"}},
+		},
+		{
+			CreateAt: time.Date(2026, 1, 2, 10, 0, 0, 0, time.UTC),
+			Values:   map[int64][]string{mood.ID: {"10"}, tags.ID: {"gym", "work"}, note.ID: {"a long day at the gym"}},
+		},
+		{
+			CreateAt: time.Date(2026, 1, 3, 10, 0, 0, 0, time.UTC),
+			Values:   map[int64][]string{mood.ID: {"3"}, tags.ID: {"gymnastics"}, day.ID: {"2026-01-03"}},
+		},
+		{
+			CreateAt: time.Date(2026, 1, 4, 10, 0, 0, 0, time.UTC),
+			Values:   map[int64][]string{mood.ID: {"7"}},
+		},
+	})
+	must(t, err)
+	ids := func(query formly.SubmissionQuery) string {
+		t.Helper()
+		query.FormID = form.ID
+		submissions, err := env.SubmissionModel.Query(query)
+		must(t, err)
+		found := []string{}
+		for _, submission := range submissions {
+			for i, s := range stored {
+				if s.ID == submission.ID {
+					found = append(found, fmt.Sprint(i))
+				}
+			}
+		}
+		return strings.Join(found, ",")
+	}
+	since := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, tt := range []struct {
+		where string
+		query formly.SubmissionQuery
+		want  string
+	}{
+		{"", formly.SubmissionQuery{}, "0,1,2,3"},
+		{"mood >= 7", formly.SubmissionQuery{}, "0,1,3"},
+		{`mood >= 7 and tags contains "gym"`, formly.SubmissionQuery{Since: since}, "1"},
+		{"mood > 9", formly.SubmissionQuery{}, "1"},
+		{"tags contains gym", formly.SubmissionQuery{Limit: 1}, "0"},
+		{"note contains gym or tags = work", formly.SubmissionQuery{}, "1"},
+		{"not (tags = gym) and mood != 7", formly.SubmissionQuery{}, "2"},
+		{"day < 2026-01-01", formly.SubmissionQuery{}, "0"},
+		{"NOT tags contains gym", formly.SubmissionQuery{Until: time.Date(2026, 1, 4, 10, 0, 0, 0, time.UTC)}, "2"},
+		{"note = 'a long day at the gym'", formly.SubmissionQuery{}, "1"},
+	} {
+		query := tt.query
+		if tt.where != "" {
+			where, err := formly.ParseCondition(tt.where)
+			must(t, err)
+			query.Where = &where
+		}
+		if got := ids(query); got != tt.want {
+			t.Errorf("Query(%q, %+v) = %v, want %v", tt.where, tt.query, got, tt.want)
+		}
+	}
+	built := formly.And(formly.Compare("mood", formly.OpGreaterEqual, "7"), formly.Not(formly.Compare("tags", formly.OpContains, "work")))
+	if got := ids(formly.SubmissionQuery{Where: &built}); got != "0,3" {
+		t.Errorf("Query(%v) = %v, want 0,3", built, got)
+	}
+	for _, where := range []string{"mood >", "mood ~ 3", "(mood = 3", "mood = 3 tags", "'mood' = 3", "mood = \"3"} {
+		if _, err := formly.ParseCondition(where); !errors.Is(err, formly.ErrInvalidCondition) {
+			t.Errorf("ParseCondition(%q) = %v, want %v", where, err, formly.ErrInvalidCondition)
+		}
+	}
+	for _, where := range []formly.Condition{formly.Compare("weather", formly.OpEqual, "sun"), formly.Compare("mood", formly.OpLess, "many")} {
+		where := where
+		if _, err := env.SubmissionModel.Query(formly.SubmissionQuery{FormID: form.ID, Where: &where}); !errors.Is(err, formly.ErrInvalidCondition) {
+			t.Errorf("Query(%v) = %v, want %v", where, err, formly.ErrInvalidCondition)
+		}
+	}
+}
+
+func testStats(t *testing.T, env *formly.Env) {
+	form := createForm(t, env, "standup")
+	mood := createLabel(t, env, formly.Label{FormID: form.ID, Position: 1, Name: "mood", Type: formly.IntLabel})
+	tags := createLabel(t, env, formly.Label{FormID: form.ID, Position: 2, Name: "tags", Repeatable: true})
+	day := func(d int) time.Time { return time.Date(2026, 3, d, 9, 0, 0, 0, time.UTC) }
+	_, err := env.SubmitAll(form.ID, []formly.NewSubmission{
+		{CreateAt: day(2), Values: map[int64][]string{mood.ID: {"4"}, tags.ID: {"gym", "work"}}},
+		{CreateAt: day(3), Values: map[int64][]string{mood.ID: {"8"}, tags.ID: {"work"}}},
+		{CreateAt: day(17), Values: map[int64][]string{mood.ID: {"6"}}},
+		{CreateAt: day(18), Values: map[int64][]string{mood.ID: {"9"}, tags.ID: {"work"}}},
+		{CreateAt: day(30), Values: map[int64][]string{}},
+	})
+	must(t, err)
+	if _, err := formly.NewFormStats(env, formly.SubmissionQuery{FormID: form.ID}, "year"); err != formly.ErrUnknownStatsPeriod {
+		t.Errorf("NewFormStats with a year = %v, want %v", err, formly.ErrUnknownStatsPeriod)
+	}
+	stats, err := formly.NewFormStats(env, formly.SubmissionQuery{FormID: form.ID, Until: day(20)}, formly.WeekPeriod)
+	must(t, err)
+	if stats.Submissions != 4 || !stats.First.Equal(day(2)) || !stats.Last.Equal(day(18)) {
+		t.Errorf("Submissions, First, Last = %v, %v, %v", stats.Submissions, stats.First, stats.Last)
+	}
+	weeks := []string{}
+	for _, week := range stats.PerPeriod {
+		weeks = append(weeks, fmt.Sprintf("%s:%v", week.Start.Format(formly.DateLayout), week.Submissions))
+	}
+	if got, want := strings.Join(weeks, ","), "2026-03-02:2,2026-03-09:0,2026-03-16:2"; got != want {
+		t.Errorf("PerPeriod = %v, want %v", got, want)
+	}
+	if len(stats.Labels) != 2 {
+		t.Fatalf("Labels = %v", stats.Labels)
+	}
+	moodStats := stats.Labels[0]
+	if moodStats.Count != 4 || moodStats.Min == nil || *moodStats.Min != 4 || *moodStats.Max != 9 ||
+		*moodStats.Mean != 6.75 || *moodStats.Median != 7 {
+		t.Errorf("mood stats = %+v", moodStats)
+	}
+	tagStats := stats.Labels[1]
+	if tagStats.Count != 4 || tagStats.Submissions != 3 || tagStats.Min != nil ||
+		fmt.Sprint(tagStats.Frequencies) != "[{work 3} {gym 1}]" {
+		t.Errorf("tags stats = %+v", tagStats)
+	}
+	stats, err = formly.NewFormStats(env, formly.SubmissionQuery{FormID: form.ID}, formly.MonthPeriod)
+	must(t, err)
+	if len(stats.PerPeriod) != 1 || stats.PerPeriod[0].Submissions != 5 || stats.Labels[0].Count != 4 {
+		t.Errorf("monthly stats = %+v", stats)
+	}
+	// the periods cover the query's window, not only the submissions
+	window := formly.SubmissionQuery{
+		FormID: form.ID,
+		Since:  time.Date(2026, 2, 10, 0, 0, 0, 0, time.UTC),
+		Until:  time.Date(2026, 4, 15, 0, 0, 0, 0, time.UTC),
+	}
+	stats, err = formly.NewFormStats(env, window, formly.MonthPeriod)
+	must(t, err)
+	months := []string{}
+	for _, month := range stats.PerPeriod {
+		months = append(months, fmt.Sprintf("%s:%v", month.Start.Format(formly.DateLayout), month.Submissions))
+	}
+	if got, want := strings.Join(months, ","), "2026-02-01:0,2026-03-01:5,2026-04-01:0"; got != want {
+		t.Errorf("PerPeriod of a window = %v, want %v", got, want)
+	}
+	empty := formly.SubmissionQuery{FormID: form.ID, Since: day(1).AddDate(0, -1, 0), Until: day(1).AddDate(0, -1, 2)}
+	stats, err = formly.NewFormStats(env, empty, formly.DayPeriod)
+	must(t, err)
+	if len(stats.PerPeriod) != 3 || stats.Submissions != 0 {
+		t.Errorf("stats of an empty window = %+v, want 3 empty days", stats)
+	}
+	// a window of more periods than a chart can show is refused, not filled
+	long := formly.SubmissionQuery{FormID: form.ID, Since: time.Date(1900, 1, 1, 0, 0, 0, 0, time.UTC)}
+	if _, err := formly.NewFormStats(env, long, formly.DayPeriod); !errors.Is(err, formly.ErrStatsWindowTooLong) {
+		t.Errorf("NewFormStats of a window since 1900 = %v, want %v", err, formly.ErrStatsWindowTooLong)
+	}
+	if _, err := formly.NewSeries(env, long, 0, formly.DayPeriod); !errors.Is(err, formly.ErrStatsWindowTooLong) {
+		t.Errorf("NewSeries of a window since 1900 = %v, want %v", err, formly.ErrStatsWindowTooLong)
+	}
+	long.Until = long.Since.AddDate(0, formly.MaxStatsPeriods, 0)
+	if _, err := formly.NewFormStats(env, long, formly.MonthPeriod); err != nil {
+		t.Errorf("NewFormStats of a window of MaxStatsPeriods months = %v", err)
+	}
+	series, err := formly.NewSeries(env, formly.SubmissionQuery{FormID: form.ID, Since: day(3)}, tags.ID, formly.WeekPeriod)
+	must(t, err)
+	points := []string{}
+	for _, point := range series {
+		points = append(points, fmt.Sprintf("%s:%v:%s", point.Start.Format(formly.DateLayout), point.Submissions, strings.Join(point.Values, "+")))
+	}
+	if got, want := strings.Join(points, ","), "2026-03-02:1:work,2026-03-09:0:,2026-03-16:2:work,2026-03-23:0:,2026-03-30:1:"; got != want {
+		t.Errorf("NewSeries = %v, want %v", got, want)
+	}
+}
+
+func testHooks(t *testing.T, env *formly.Env) {
+	standup := createForm(t, env, "standup")
+	mood := createLabel(t, env, formly.Label{FormID: standup.ID, Position: 1, Name: "mood", Type: formly.IntLabel})
+	other := createForm(t, env, "other")
+	for _, hook := range []formly.Hook{
+		{FormID: standup.ID, Event: "on-save", Command: "true"},
+		{FormID: standup.ID, Event: formly.PostSubmit, Command: " "},
+		{FormID: standup.ID, Event: formly.PostSubmit, Command: "true", Timeout: -time.Second},
+		{FormID: standup.ID, Event: formly.PostSubmit, Command: "true", OnFailure: "retry"},
+		{FormID: 4242, Event: formly.PostSubmit, Command: "true"},
+	} {
+		if _, err := env.HookModel.Create(hook); err == nil {
+			t.Errorf("Create(%+v) did not fail", hook)
+		}
+	}
+	dir := t.TempDir()
+	// hooks write what they were given to files named after them
+	write := func(name string) string {
+		return fmt.Sprintf(`cat > %s/%s.json; echo "$FORMLY_FORM $FORMLY_SUBMISSION_ID" > %s/%s.env`, dir, name, dir, name)
+	}
+	read := func(name string) string {
+		t.Helper()
+		b, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if os.IsNotExist(err) {
+			return ""
+		}
+		must(t, err)
+		return strings.TrimSpace(string(b))
+	}
+	post, err := env.HookModel.Create(formly.Hook{FormID: standup.ID, Event: formly.PostSubmit, Command: write("post")})
+	must(t, err)
+	if post.OnFailure != formly.IgnoreOnFailure || post.Timeout != 0 {
+		t.Errorf("post-submit hook defaults = %+v", post)
+	}
+	pre, err := env.HookModel.Create(formly.Hook{
+		FormID: standup.ID, Event: formly.PreSubmit, Timeout: time.Minute,
+		Command: write("pre") + `; grep -q '"value":"1"' ` + dir + `/pre.json && { echo "mood is too low" >&2; exit 1; }; true`,
+	})
+	must(t, err)
+	if pre.OnFailure != formly.AbortOnFailure || pre.Timeout != time.Minute {
+		t.Errorf("pre-submit hook defaults = %+v", pre)
+	}
+	slow, err := env.HookModel.Create(formly.Hook{
+		FormID: standup.ID, Event: formly.PostSubmit, Command: "sleep 5", Timeout: 100 * time.Millisecond,
+	})
+	must(t, err)
+	_, err = env.HookModel.Create(formly.Hook{FormID: other.ID, Event: formly.PreSubmit, Command: "exit 1"})
+	must(t, err)
+	hooks, err := env.HookModel.GetHooks(standup.ID)
+	must(t, err)
+	if len(hooks) != 3 || hooks[0] != post || hooks[1] != pre || hooks[2] != slow {
+		t.Errorf("GetHooks = %+v, want %+v", hooks, []formly.Hook{post, pre, slow})
+	}
+
+	// a failing pre-submit hook rejects the submission with its message
+	_, ignored, err := env.SubmitWithHooks(standup.ID, map[int64][]string{mood.ID: {"1"}})
+	hookErr := &formly.HookError{}
+	if !errors.As(err, &hookErr) || !hookErr.Rejected() || hookErr.Message != "mood is too low" || len(ignored) != 0 {
+		t.Errorf("SubmitWithHooks of a rejected submission = %v, %v", err, ignored)
+	}
+	if got := read("pre.env"); got != "standup 0" {
+		t.Errorf("pre-submit hook env = %q", got)
+	}
+	submissions, err := env.SubmissionModel.GetSubmissions(standup.ID)
+	must(t, err)
+	if len(submissions) != 0 || read("post.json") != "" {
+		t.Fatalf("a rejected submission was stored or ran post-submit hooks")
+	}
+	// invalid submissions never reach the hooks
+	must(t, os.Remove(filepath.Join(dir, "pre.json")))
+	if _, _, err := env.SubmitWithHooks(standup.ID, map[int64][]string{mood.ID: {"seven"}}); err == nil || read("pre.json") != "" {
+		t.Errorf("SubmitWithHooks of an invalid submission = %v", err)
+	}
+
+	submission, ignored, err := env.SubmitWithHooks(standup.ID, map[int64][]string{mood.ID: {"07"}})
+	must(t, err)
+	if len(ignored) != 1 || !errors.As(ignored[0], &hookErr) || hookErr.Hook.ID != slow.ID || !strings.Contains(hookErr.Error(), "timed out") {
+		t.Errorf("ignored = %v, want the slow hook timing out", ignored)
+	}
+	if got, want := read("post.env"), fmt.Sprintf("standup %v", submission.ID); got != want {
+		t.Errorf("post-submit hook env = %q, want %q", got, want)
+	}
+	doc := struct {
+		ID      int64
+		Form    string
+		Entries []struct {
+			Label, Value string
+		}
+	}{}
+	must(t, json.Unmarshal([]byte(read("post.json")), &doc))
+	if doc.ID != submission.ID || doc.Form != "standup" || len(doc.Entries) != 1 ||
+		doc.Entries[0].Label != "mood" || doc.Entries[0].Value != "7" {
+		t.Errorf("post-submit hook stdin = %+v", doc)
+	}
+
+	// a failing post-submit hook set to abort fails the submit but keeps the submission
+	_, err = env.HookModel.DeleteByID(slow.ID)
+	must(t, err)
+	if _, err := env.HookModel.DeleteByID(slow.ID); err != sql.ErrNoRows {
+		t.Errorf("DeleteByID of a deleted hook = %v, want %v", err, sql.ErrNoRows)
+	}
+	_, err = env.HookModel.Create(formly.Hook{
+		FormID: standup.ID, Event: formly.PostSubmit, Command: "echo unreachable; exit 3", OnFailure: formly.AbortOnFailure,
+	})
+	must(t, err)
+	submission, _, err = env.SubmitWithHooks(standup.ID, map[int64][]string{mood.ID: {"8"}})
+	if !errors.As(err, &hookErr) || hookErr.Rejected() || hookErr.Message != "unreachable" || submission.ID == 0 {
+		t.Errorf("SubmitWithHooks with a failing post-submit hook = %+v, %v", submission, err)
+	}
+	if _, err := env.SubmissionModel.GetByID(submission.ID); err != nil {
+		t.Errorf("submission of a failing post-submit hook was not kept: %v", err)
+	}
+
+	// a rejected submission rejects the whole batch, the post-submit hooks
+	// run for every submission of a stored one
+	submissions, err = env.SubmissionModel.GetSubmissions(standup.ID)
+	must(t, err)
+	_, _, err = env.SubmitAllWithHooks(standup.ID, []formly.NewSubmission{
+		{Values: map[int64][]string{mood.ID: {"5"}}},
+		{Values: map[int64][]string{mood.ID: {"1"}}},
+	})
+	if !errors.As(err, &hookErr) || !hookErr.Rejected() || !strings.HasPrefix(err.Error(), "submission 2: ") {
+		t.Errorf("SubmitAllWithHooks of a rejected submission = %v", err)
+	}
+	if stored, err := env.SubmissionModel.GetSubmissions(standup.ID); err != nil || len(stored) != len(submissions) {
+		t.Errorf("a rejected batch stored %v submissions, %v", len(stored)-len(submissions), err)
+	}
+	batch, _, err := env.SubmitAllWithHooks(standup.ID, []formly.NewSubmission{
+		{Values: map[int64][]string{mood.ID: {"5"}}},
+		{Values: map[int64][]string{mood.ID: {"6"}}},
+	})
+	if len(batch) != 2 || !errors.As(err, &hookErr) || hookErr.Rejected() || !strings.HasPrefix(err.Error(), "submission 1: ") {
+		t.Fatalf("SubmitAllWithHooks with a failing post-submit hook = %+v, %v", batch, err)
+	}
+	must(t, json.Unmarshal([]byte(read("post.json")), &doc))
+	if doc.ID != batch[1].ID {
+		t.Errorf("post-submit hook stdin after a batch = %+v, want submission %v", doc, batch[1].ID)
+	}
+
+	_, err = env.FormModel.DeleteByID(standup.ID)
+	must(t, err)
+	hooks, err = env.HookModel.GetHooks(standup.ID)
+	must(t, err)
+	if len(hooks) != 0 {
+		t.Errorf("hooks of a deleted form = %+v", hooks)
+	}
+}
+
+func testWebhooks(t *testing.T, env *formly.Env) {
+	standup := createForm(t, env, "standup")
+	mood := createLabel(t, env, formly.Label{FormID: standup.ID, Position: 1, Name: "mood", Type: formly.IntLabel})
+	for _, webhook := range []formly.Webhook{
+		{FormID: standup.ID, URL: ""},
+		{FormID: standup.ID, URL: "example.com/hook"},
+		{FormID: standup.ID, URL: "ftp://example.com/hook"},
+		{FormID: 4242, URL: "http://example.com/hook"},
+	} {
+		if _, err := env.WebhookModel.Create(webhook); err == nil {
+			t.Errorf("Create(%+v) did not fail", webhook)
+		}
+	}
+
+	type request struct {
+		body                []byte
+		signature, delivery string
+	}
+	requests := make(chan request, 16)
+	status := http.StatusOK
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		requests <- request{body, r.Header.Get(formly.SignatureHeader), r.Header.Get("X-Formly-Delivery")}
+		w.WriteHeader(status)
+		if status != http.StatusOK {
+			fmt.Fprintln(w, "try again later")
+		}
+	}))
+	defer receiver.Close()
+	gone := httptest.NewServer(http.NotFoundHandler())
+	gone.Close()
+
+	ok, err := env.WebhookModel.Create(formly.Webhook{FormID: standup.ID, URL: receiver.URL + "/ok", Secret: "s3cret"})
+	must(t, err)
+	generated, err := env.WebhookModel.Create(formly.Webhook{FormID: standup.ID, URL: gone.URL})
+	must(t, err)
+	if ok.Secret != "s3cret" || len(generated.Secret) < 32 {
+		t.Errorf("secrets = %q, %q", ok.Secret, generated.Secret)
+	}
+	webhooks, err := env.WebhookModel.GetWebhooks(standup.ID)
+	must(t, err)
+	if len(webhooks) != 2 || webhooks[0] != ok || webhooks[1] != generated {
+		t.Errorf("GetWebhooks = %+v, want %+v", webhooks, []formly.Webhook{ok, generated})
+	}
+
+	_, err = env.SubmitAll(standup.ID, []formly.NewSubmission{{Values: map[int64][]string{mood.ID: {"6"}}, SkipWebhooks: true}})
+	must(t, err)
+	submission, _, err := env.SubmitWithHooks(standup.ID, map[int64][]string{mood.ID: {"7"}})
+	must(t, err)
+	deliveries, err := env.WebhookModel.GetDeliveries(standup.ID)
+	must(t, err)
+	if len(deliveries) != 2 {
+		t.Fatalf("GetDeliveries = %+v, want a delivery per webhook, none for the skipped submission", deliveries)
+	}
+	for _, delivery := range deliveries {
+		if delivery.Status != formly.DeliveryPending || delivery.SubmissionID != submission.ID || delivery.Attempts != 0 {
+			t.Errorf("queued delivery = %+v", delivery)
+		}
+	}
+
+	now := time.Now().UTC().Truncate(time.Second).Add(time.Second)
+	attempted, err := env.DeliverWebhooks(nil, now)
+	must(t, err)
+	if len(attempted) != 2 {
+		t.Fatalf("DeliverWebhooks attempted %+v, want both deliveries", attempted)
+	}
+	delivered, retried := attempted[0], attempted[1]
+	if delivered.WebhookID != ok.ID || delivered.Status != formly.DeliveryDelivered || delivered.StatusCode != http.StatusOK ||
+		delivered.Attempts != 1 || !delivered.DeliveredAt.Equal(now) {
+		t.Errorf("delivery to a webhook that answers 200 = %+v", delivered)
+	}
+	if retried.WebhookID != generated.ID || retried.Status != formly.DeliveryPending || retried.StatusCode != 0 ||
+		retried.Error == "" || !retried.NextAttemptAt.Equal(now.Add(formly.WebhookRetryDelay)) {
+		t.Errorf("delivery to a webhook that is gone = %+v", retried)
+	}
+	got := <-requests
+	if !formly.VerifyWebhook("s3cret", got.body, got.signature) || got.delivery != fmt.Sprint(delivered.ID) {
+		t.Errorf("delivery %q was signed %q", got.delivery, got.signature)
+	}
+	doc := struct {
+		ID   int64
+		Form string
+	}{}
+	must(t, json.Unmarshal(got.body, &doc))
+	if doc.ID != submission.ID || doc.Form != "standup" || string(got.body) != delivered.Payload {
+		t.Errorf("delivered payload = %s", got.body)
+	}
+
+	// nothing is due until the retry delay is over, every retry after waits
+	// twice as long
+	if attempted, err := env.DeliverWebhooks(nil, now.Add(formly.WebhookRetryDelay-time.Second)); err != nil || len(attempted) != 0 {
+		t.Errorf("DeliverWebhooks before the retry is due = %+v, %v", attempted, err)
+	}
+	for attempts := int64(2); attempts <= formly.MaxWebhookAttempts; attempts++ {
+		now = retried.NextAttemptAt
+		attempted, err := env.DeliverWebhooks(nil, now)
+		must(t, err)
+		if len(attempted) != 1 || attempted[0].Attempts != attempts {
+			t.Fatalf("attempt %v = %+v", attempts, attempted)
+		}
+		delay := attempted[0].NextAttemptAt.Sub(retried.NextAttemptAt)
+		if attempts < formly.MaxWebhookAttempts && delay != formly.WebhookRetryDelay<<uint(attempts-1) {
+			t.Errorf("delay after attempt %v = %v", attempts, delay)
+		}
+		retried = attempted[0]
+	}
+	if retried.Status != formly.DeliveryFailed {
+		t.Errorf("delivery out of attempts = %+v", retried)
+	}
+	if attempted, err := env.DeliverWebhooks(nil, now.Add(24*time.Hour)); err != nil || len(attempted) != 0 {
+		t.Errorf("DeliverWebhooks of failed deliveries = %+v, %v", attempted, err)
+	}
+
+	// a failing answer is retried with the answer as the error
+	_, err = env.WebhookModel.DeleteByID(generated.ID)
+	must(t, err)
+	if _, err := env.WebhookModel.GetByID(generated.ID); err != sql.ErrNoRows {
+		t.Errorf("GetByID of a deleted webhook = %v, want %v", err, sql.ErrNoRows)
+	}
+	status = http.StatusServiceUnavailable
+	second, _, err := env.SubmitWithHooks(standup.ID, map[int64][]string{mood.ID: {"8"}})
+	must(t, err)
+	now = time.Now().UTC().Truncate(time.Second).Add(time.Second)
+	attempted, err = env.DeliverWebhooks(nil, now)
+	must(t, err)
+	<-requests
+	if len(attempted) != 1 || attempted[0].StatusCode != http.StatusServiceUnavailable ||
+		!strings.Contains(attempted[0].Error, "try again later") || attempted[0].Status != formly.DeliveryPending {
+		t.Errorf("delivery to a webhook that answers 503 = %+v", attempted)
+	}
+	status = http.StatusOK
+	attempted, err = env.DeliverWebhooks(nil, attempted[0].NextAttemptAt)
+	must(t, err)
+	<-requests
+	if len(attempted) != 1 || attempted[0].Status != formly.DeliveryDelivered || attempted[0].Attempts != 2 || attempted[0].Error != "" {
+		t.Errorf("retried delivery = %+v", attempted)
+	}
+
+	// Submit queues the submission along with storing it, claimed
+	// deliveries are skipped by others draining the queue
+	third, err := env.SubmissionModel.Submit(standup.ID, map[int64][]string{mood.ID: {"9"}})
+	must(t, err)
+	queued, err := env.WebhookModel.GetDeliveries(standup.ID)
+	must(t, err)
+	if queued[0].SubmissionID != third.ID || !strings.Contains(queued[0].Payload, `"value":"9"`) {
+		t.Fatalf("delivery of a plain submit = %+v", queued[0])
+	}
+	now = time.Now().UTC().Truncate(time.Second).Add(time.Second)
+	claimed, err := env.WebhookModel.ClaimDelivery(now, now.Add(time.Minute))
+	must(t, err)
+	if claimed.ID != queued[0].ID || !claimed.NextAttemptAt.Equal(now.Add(time.Minute)) {
+		t.Errorf("ClaimDelivery = %+v, want %+v", claimed, queued[0])
+	}
+	if _, err := env.WebhookModel.ClaimDelivery(now, now.Add(time.Minute)); err != sql.ErrNoRows {
+		t.Errorf("ClaimDelivery of a claimed delivery = %v, want %v", err, sql.ErrNoRows)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if attempted, err := env.DeliverWebhooksContext(ctx, nil, now.Add(2*time.Minute)); err != context.Canceled || len(attempted) != 0 {
+		t.Errorf("DeliverWebhooksContext of a done context = %+v, %v", attempted, err)
+	}
+
+	deliveries, err = env.WebhookModel.GetDeliveries(standup.ID)
+	must(t, err)
+	if len(deliveries) != 3 || deliveries[0].SubmissionID != third.ID || deliveries[1].SubmissionID != second.ID ||
+		deliveries[2].SubmissionID != submission.ID {
+		t.Errorf("GetDeliveries = %+v, want the deliveries of the remaining webhook, newest first", deliveries)
+	}
+	_, err = env.FormModel.DeleteByID(standup.ID)
+	must(t, err)
+	webhooks, err = env.WebhookModel.GetWebhooks(standup.ID)
+	must(t, err)
+	deliveries, err = env.WebhookModel.GetDeliveries(standup.ID)
+	must(t, err)
+	if len(webhooks) != 0 || len(deliveries) != 0 {
+		t.Errorf("webhooks and deliveries of a deleted form = %+v, %+v", webhooks, deliveries)
+	}
+}
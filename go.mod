@@ -2,4 +2,7 @@ module github.com/pablothedeveloper/formly
 
 go 1.16
 
-require github.com/mattn/go-sqlite3 v1.14.7
+require (
+	github.com/mattn/go-sqlite3 v1.14.7
+	gopkg.in/yaml.v3 v3.0.1
+)
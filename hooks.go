@@ -0,0 +1,316 @@
+package formly
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultHookTimeout ...
+const DefaultHookTimeout = 10 * time.Second
+
+// maxHookMessage bounds how much of a hook's output ends up in its error.
+const maxHookMessage = 1 << 10
+
+// ErrUnknownHookEvent ...
+var ErrUnknownHookEvent error = errors.New("hook event is not one of: pre-submit, post-submit")
+
+// ErrUnknownHookFailure ...
+var ErrUnknownHookFailure error = errors.New("hook on failure is not one of: abort, ignore")
+
+// ErrEmptyHookCommand ...
+var ErrEmptyHookCommand error = errors.New("hook command is empty")
+
+// ErrNegativeHookTimeout ...
+var ErrNegativeHookTimeout error = errors.New("hook timeout cannot be negative")
+
+// ValidateHook checks a hook and returns it with OnFailure defaulted for
+// its event.
+func ValidateHook(hook Hook) (Hook, error) {
+	switch hook.Event {
+	case PreSubmit, PostSubmit:
+	default:
+		return Hook{}, ErrUnknownHookEvent
+	}
+	if strings.TrimSpace(hook.Command) == "" {
+		return Hook{}, ErrEmptyHookCommand
+	}
+	if hook.Timeout < 0 {
+		return Hook{}, ErrNegativeHookTimeout
+	}
+	// timeouts are stored in milliseconds
+	hook.Timeout = hook.Timeout.Truncate(time.Millisecond)
+	switch hook.OnFailure {
+	case "":
+		hook.OnFailure = IgnoreOnFailure
+		if hook.Event == PreSubmit {
+			hook.OnFailure = AbortOnFailure
+		}
+	case AbortOnFailure, IgnoreOnFailure:
+	default:
+		return Hook{}, ErrUnknownHookFailure
+	}
+	return hook, nil
+}
+
+// HookError is a hook that failed, because its command exited with an
+// error or ran out of time.
+type HookError struct {
+	Hook Hook
+	// Message is what the command wrote to stderr, or to stdout when it
+	// wrote nothing to stderr.
+	Message string
+	Err     error
+}
+
+func (e *HookError) Error() string {
+	message := e.Message
+	if message == "" {
+		message = e.Err.Error()
+	}
+	if e.Rejected() {
+		return fmt.Sprintf("submission rejected by pre-submit hook %v: %s", e.Hook.ID, message)
+	}
+	return fmt.Sprintf("%s hook %v (%s) failed: %s", e.Hook.Event, e.Hook.ID, e.Hook.Command, message)
+}
+
+// Unwrap ...
+func (e *HookError) Unwrap() error {
+	return e.Err
+}
+
+// Rejected reports whether the hook turned the submission down.
+func (e *HookError) Rejected() bool {
+	return e.Hook.Event == PreSubmit && e.Hook.OnFailure == AbortOnFailure
+}
+
+// hookSubmission is the json document hooks read on stdin and webhooks are
+// posted, the submission document of form's --output json. Pre-submit hooks
+// get the submission as it is about to be stored, without ids.
+type hookSubmission struct {
+	ID        int64       `json:"id"`
+	Form      string      `json:"form"`
+	Version   int64       `json:"version"`
+	CreatedAt time.Time   `json:"created_at"`
+	Entries   []hookEntry `json:"entries"`
+}
+
+type hookEntry struct {
+	ID      int64  `json:"id"`
+	LabelID int64  `json:"label_id"`
+	Label   string `json:"label"`
+	Value   string `json:"value"`
+}
+
+func newHookSubmission(form Form, submission Submission, labels []Label) hookSubmission {
+	names := map[int64]string{}
+	for _, label := range labels {
+		names[label.ID] = label.Name
+	}
+	doc := hookSubmission{
+		ID:        submission.ID,
+		Form:      form.Name,
+		Version:   submission.Version,
+		CreatedAt: submission.CreateAt,
+		Entries:   []hookEntry{},
+	}
+	for _, entry := range submission.Entries {
+		doc.Entries = append(doc.Entries, hookEntry{
+			ID:      entry.ID,
+			LabelID: entry.LabelID,
+			Label:   names[entry.LabelID],
+			Value:   entry.Txt,
+		})
+	}
+	return doc
+}
+
+// SubmitWithHooks submits like Submit, running the form's pre-submit hooks
+// before the submission is stored and its post-submit hooks after. Every
+// hook runs with sh and gets the submission as json on stdin, the form's
+// name in $FORMLY_FORM and the submission's id, 0 before it is stored, in
+// $FORMLY_SUBMISSION_ID. Failing hooks set to ignore are returned in
+// ignored, a failing hook set to abort is returned as err, a *HookError.
+// The submission is kept when a post-submit hook fails. Submit queues it
+// for the form's webhooks along with storing it, DeliverWebhooks sends them.
+func (env *Env) SubmitWithHooks(formID int64, values map[int64][]string) (submission Submission, ignored []error, err error) {
+	submissions, ignored, err := env.submitWithHooks(formID, []NewSubmission{{Values: values}}, false)
+	if len(submissions) != 0 {
+		submission = submissions[0]
+	}
+	return submission, ignored, err
+}
+
+// SubmitAllWithHooks submits like SubmitAll, running the hooks the way
+// SubmitWithHooks does for every submission. The pre-submit hooks of all
+// of them run before any is stored, one turned down stores none. The
+// post-submit hooks run for every stored submission, even after one set to
+// abort failed, and err is the first of those failures.
+func (env *Env) SubmitAllWithHooks(formID int64, newSubmissions []NewSubmission) (submissions []Submission, ignored []error, err error) {
+	return env.submitWithHooks(formID, newSubmissions, true)
+}
+
+// submitWithHooks stores newSubmissions with SubmitAll when all is set and
+// the only one with Submit otherwise, whose errors do not name it.
+func (env *Env) submitWithHooks(formID int64, newSubmissions []NewSubmission, all bool) ([]Submission, []error, error) {
+	store := func(newSubmissions []NewSubmission) ([]Submission, error) {
+		if all {
+			return env.SubmissionModel.SubmitAll(formID, newSubmissions)
+		}
+		submission, err := env.SubmissionModel.Submit(formID, newSubmissions[0].Values)
+		if err != nil {
+			return nil, err
+		}
+		return []Submission{submission}, nil
+	}
+	numbered := func(i int, err error) error {
+		if all {
+			return fmt.Errorf("submission %v: %w", i+1, err)
+		}
+		return err
+	}
+	hooks, err := env.HookModel.GetHooks(formID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(hooks) == 0 {
+		submissions, err := store(newSubmissions)
+		return submissions, nil, err
+	}
+	form, err := env.FormModel.GetByID(formID)
+	if err != nil {
+		return nil, nil, err
+	}
+	labels, err := env.LabelModel.GetLabels(formID)
+	if err != nil {
+		return nil, nil, err
+	}
+	versions, err := env.FormModel.GetVersions(formID)
+	if err != nil {
+		return nil, nil, err
+	}
+	ignored := []error{}
+	canonical := make([]NewSubmission, len(newSubmissions))
+	for i, newSubmission := range newSubmissions {
+		// hooks only ever see submissions that validate
+		values, err := ValidateSubmission(labels, newSubmission.Values)
+		if err != nil {
+			return nil, ignored, numbered(i, err)
+		}
+		canonical[i] = NewSubmission{CreateAt: newSubmission.CreateAt, Values: values}
+		pending := Submission{
+			FormID:   formID,
+			Version:  versions[len(versions)-1].Version,
+			CreateAt: newSubmission.CreateAt.UTC().Truncate(time.Second),
+		}
+		if newSubmission.CreateAt.IsZero() {
+			pending.CreateAt = time.Now().UTC().Truncate(time.Second)
+		}
+		for _, label := range labels {
+			for _, txt := range values[label.ID] {
+				pending.Entries = append(pending.Entries, Entry{LabelID: label.ID, Txt: txt})
+			}
+		}
+		if err := runHooks(hooks, PreSubmit, form, newHookSubmission(form, pending, labels), &ignored); err != nil {
+			return nil, ignored, numbered(i, err)
+		}
+	}
+	submissions, err := store(canonical)
+	if err != nil {
+		return nil, ignored, err
+	}
+	var failed error
+	for i, submission := range submissions {
+		doc := newHookSubmission(form, submission, labels)
+		if err := runHooks(hooks, PostSubmit, form, doc, &ignored); err != nil && failed == nil {
+			failed = numbered(i, err)
+		}
+	}
+	return submissions, ignored, failed
+}
+
+// runHooks runs the hooks of an event in order, up to the first failing
+// hook set to abort.
+func runHooks(hooks []Hook, event HookEvent, form Form, doc hookSubmission, ignored *[]error) error {
+	input, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	for _, hook := range hooks {
+		if hook.Event != event {
+			continue
+		}
+		err := runHook(hook, form, doc.ID, input)
+		if err == nil {
+			continue
+		}
+		if hook.OnFailure == AbortOnFailure {
+			return err
+		}
+		*ignored = append(*ignored, err)
+	}
+	return nil
+}
+
+func runHook(hook Hook, form Form, submissionID int64, input []byte) error {
+	timeout := hook.Timeout
+	if timeout == 0 {
+		timeout = DefaultHookTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	// the command talks through files rather than pipes, so commands it
+	// leaves running in the background cannot keep the submit waiting
+	files := make([]*os.File, 3)
+	for i := range files {
+		file, err := ioutil.TempFile("", "formly-hook-*")
+		if err != nil {
+			return err
+		}
+		defer os.Remove(file.Name())
+		defer file.Close()
+		files[i] = file
+	}
+	stdin, stdout, stderr := files[0], files[1], files[2]
+	if _, err := stdin.Write(input); err != nil {
+		return err
+	}
+	if _, err := stdin.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	cmd := exec.CommandContext(ctx, "sh", "-c", hook.Command)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = stdin, stdout, stderr
+	cmd.Env = append(
+		os.Environ(),
+		"FORMLY_FORM="+form.Name,
+		"FORMLY_HOOK_EVENT="+string(hook.Event),
+		"FORMLY_SUBMISSION_ID="+strconv.FormatInt(submissionID, 10),
+	)
+	err := cmd.Run()
+	if err == nil {
+		return nil
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		return &HookError{Hook: hook, Err: fmt.Errorf("timed out after %v", timeout)}
+	}
+	message := ""
+	for _, output := range []*os.File{stderr, stdout} {
+		if message != "" {
+			break
+		}
+		if _, err := output.Seek(0, io.SeekStart); err != nil {
+			continue
+		}
+		txt, _ := ioutil.ReadAll(io.LimitReader(output, maxHookMessage))
+		message = strings.TrimSpace(string(txt))
+	}
+	return &HookError{Hook: hook, Message: message, Err: err}
+}
@@ -0,0 +1,259 @@
+package formly
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ImportRow is a row of an import file mapped onto a form's labels. Line is
+// the row's line in the file, for reporting, which for csv counts records
+// so the header is line 1. Err is set when the row could not be read.
+type ImportRow struct {
+	Line       int
+	Submission NewSubmission
+	Err        error
+	// submissionID is the row's submission_id column, only used to find
+	// the rows of an expanded export
+	submissionID string
+}
+
+// ErrUnknownImportFormat ...
+var ErrUnknownImportFormat error = errors.New("unknown import format, expected csv or jsonl")
+
+// ImportFormat guesses the format of an import file from its extension.
+func ImportFormat(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".jsonl", ".ndjson":
+		return "jsonl"
+	}
+	return "csv"
+}
+
+// ParseImport reads submissions from a csv file with a header row or from
+// json lines, the way form export writes them. Columns are matched to the
+// labels by name, an optional created_at column holds when the submission
+// was made and a submission_id column is only used to refuse the rows of
+// exports made with Expand, which hold a submission on several rows. In csv
+// the values of a repeatable label are joined as by JoinValues.
+func ParseImport(r io.Reader, format string, labels []Label) ([]ImportRow, error) {
+	byName := map[string]Label{}
+	for _, label := range labels {
+		byName[label.Name] = label
+	}
+	var rows []ImportRow
+	var err error
+	switch format {
+	case "csv":
+		rows, err = parseCSVImport(r, byName)
+	case "jsonl":
+		rows, err = parseJSONLImport(r, byName)
+	default:
+		return nil, ErrUnknownImportFormat
+	}
+	if err != nil {
+		return nil, err
+	}
+	lines := map[string]int{}
+	for i, row := range rows {
+		if row.submissionID == "" || row.Err != nil {
+			continue
+		}
+		if line, ok := lines[row.submissionID]; ok {
+			rows[i].Err = fmt.Errorf("submission_id %s is also on line %v, exports made with --expand can not be imported", row.submissionID, line)
+			continue
+		}
+		lines[row.submissionID] = row.Line
+	}
+	return rows, nil
+}
+
+func parseCSVImport(r io.Reader, labels map[string]Label) ([]ImportRow, error) {
+	reader := csv.NewReader(r)
+	header, err := reader.Read()
+	if err == io.EOF {
+		return []ImportRow{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, column := range header {
+		if err := checkImportColumn(labels, column); err != nil {
+			return nil, err
+		}
+	}
+	rows := []ImportRow{}
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+		row := ImportRow{Line: line, Submission: NewSubmission{Values: map[int64][]string{}}}
+		if err != nil {
+			// a malformed record can not be skipped, the rest of the file
+			// can not be read reliably after it
+			var parseErr *csv.ParseError
+			if !errors.As(err, &parseErr) || parseErr.Err != csv.ErrFieldCount {
+				return nil, err
+			}
+			row.Err = fmt.Errorf("expected %v columns, got %v", len(header), len(record))
+			rows = append(rows, row)
+			continue
+		}
+		for i, cell := range record {
+			if err := row.set(labels, header[i], cell, true); err != nil {
+				row.Err = err
+				break
+			}
+		}
+		rows = append(rows, row)
+	}
+}
+
+func parseJSONLImport(r io.Reader, labels map[string]Label) ([]ImportRow, error) {
+	rows := []ImportRow{}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		row := ImportRow{Line: line, Submission: NewSubmission{Values: map[int64][]string{}}}
+		object := map[string]interface{}{}
+		decoder := json.NewDecoder(strings.NewReader(scanner.Text()))
+		decoder.UseNumber()
+		if err := decoder.Decode(&object); err != nil {
+			row.Err = err
+			rows = append(rows, row)
+			continue
+		}
+		row.Err = row.setJSON(labels, object)
+		rows = append(rows, row)
+	}
+	return rows, scanner.Err()
+}
+
+// ParseSubmission reads a single submission from a json object with a key
+// per label, holding a value or, for repeatable labels, an array of values.
+func ParseSubmission(r io.Reader, labels []Label) (map[int64][]string, error) {
+	byName := map[string]Label{}
+	for _, label := range labels {
+		byName[label.Name] = label
+	}
+	decoder := json.NewDecoder(r)
+	decoder.UseNumber()
+	object := map[string]interface{}{}
+	if err := decoder.Decode(&object); err != nil {
+		return nil, fmt.Errorf("reading submission: %w", err)
+	}
+	if err := decoder.Decode(&struct{}{}); err != io.EOF {
+		return nil, errors.New("reading submission: expected a single json object")
+	}
+	for key := range object {
+		if _, ok := byName[key]; !ok {
+			return nil, fmt.Errorf("key '%s' does not match a label of the form", key)
+		}
+	}
+	row := ImportRow{Submission: NewSubmission{Values: map[int64][]string{}}}
+	if err := row.setJSON(byName, object); err != nil {
+		return nil, err
+	}
+	return row.Submission.Values, nil
+}
+
+func checkImportColumn(labels map[string]Label, column string) error {
+	if _, ok := labels[column]; ok || column == "created_at" || column == "submission_id" {
+		return nil
+	}
+	return fmt.Errorf("column '%s' does not match a label of the form", column)
+}
+
+// setJSON stores the values of a json object with a key per column.
+func (row *ImportRow) setJSON(labels map[string]Label, object map[string]interface{}) error {
+	columns := []string{}
+	for column := range object {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+	for _, column := range columns {
+		if err := checkImportColumn(labels, column); err != nil {
+			return err
+		}
+		values, ok := object[column].([]interface{})
+		if !ok {
+			values = []interface{}{object[column]}
+		}
+		for _, value := range values {
+			txt, err := jsonText(value)
+			if err == nil {
+				err = row.set(labels, column, txt, false)
+			}
+			if err != nil {
+				return fmt.Errorf("%s: %w", column, err)
+			}
+		}
+	}
+	return nil
+}
+
+// set stores the value of a column, splitting the values of repeatable
+// labels when split is true.
+func (row *ImportRow) set(labels map[string]Label, column, txt string, split bool) error {
+	switch column {
+	case "submission_id":
+		row.submissionID = strings.TrimSpace(txt)
+		return nil
+	case "created_at":
+		createAt, err := parseImportTime(txt)
+		if err != nil {
+			return err
+		}
+		row.Submission.CreateAt = createAt
+		return nil
+	}
+	label := labels[column]
+	values := []string{txt}
+	if split && label.Repeatable {
+		values = SplitValues(txt)
+	}
+	row.Submission.Values[label.ID] = append(row.Submission.Values[label.ID], values...)
+	return nil
+}
+
+// importTimeLayouts are tried in order, times without a zone are in utc.
+var importTimeLayouts = []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02T15:04:05", DateLayout}
+
+func parseImportTime(txt string) (time.Time, error) {
+	txt = strings.TrimSpace(txt)
+	if txt == "" {
+		return time.Time{}, nil
+	}
+	for _, layout := range importTimeLayouts {
+		if t, err := time.Parse(layout, txt); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("created_at '%s' is not a date or time", txt)
+}
+
+func jsonText(value interface{}) (string, error) {
+	switch value := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return value, nil
+	case bool:
+		return strconv.FormatBool(value), nil
+	case json.Number:
+		return value.String(), nil
+	}
+	return "", errors.New("values have to be strings, numbers, booleans or arrays of them")
+}
@@ -0,0 +1,1135 @@
+package formly
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// memoryStore holds the tables of an in-memory env. Every model shares the
+// store and takes its lock for the whole of a call, so calls are atomic.
+type memoryStore struct {
+	mu sync.Mutex
+	// lastIDs holds the last id handed out per table, which sqlite numbers
+	// separately too
+	lastIDs     map[string]int64
+	forms       map[int64]Form
+	versions    map[int64][]FormVersion
+	labels      map[int64]Label
+	deleted     map[int64]bool
+	submissions map[int64]Submission
+	entries     map[int64]Entry
+	changes     map[int64]SubmissionChange
+	hooks       map[int64]Hook
+	webhooks    map[int64]Webhook
+	deliveries  map[int64]WebhookDelivery
+}
+
+func (store *memoryStore) nextID(table string) int64 {
+	store.lastIDs[table]++
+	return store.lastIDs[table]
+}
+
+// NewMemoryEnv returns an env that keeps everything in memory and behaves
+// like the sqlite one, for library users and tests that want no database.
+func NewMemoryEnv() *Env {
+	store := &memoryStore{
+		lastIDs:     map[string]int64{},
+		forms:       map[int64]Form{},
+		versions:    map[int64][]FormVersion{},
+		labels:      map[int64]Label{},
+		deleted:     map[int64]bool{},
+		submissions: map[int64]Submission{},
+		entries:     map[int64]Entry{},
+		changes:     map[int64]SubmissionChange{},
+		hooks:       map[int64]Hook{},
+		webhooks:    map[int64]Webhook{},
+		deliveries:  map[int64]WebhookDelivery{},
+	}
+	return &Env{
+		FormModel:       memoryFormModel{store: store},
+		LabelModel:      memoryLabelModel{store: store},
+		SubmissionModel: memorySubmissionModel{store: store},
+		EntryModel:      memoryEntryModel{store: store},
+		HookModel:       memoryHookModel{store: store},
+		WebhookModel:    memoryWebhookModel{store: store},
+		close: func() error {
+			return nil
+		},
+	}
+}
+
+// checkLength mirrors the length checks of the sqlite schema, which count
+// characters rather than bytes.
+func checkLength(s string, min, max int, err error) error {
+	if n := utf8.RuneCountInString(s); n < min || n > max {
+		return err
+	}
+	return nil
+}
+
+type memoryFormModel struct {
+	store *memoryStore
+}
+
+func (model memoryFormModel) check(formID int64, name, usage string) error {
+	if err := checkLength(name, 1, 16, ErrInvalidLengthName); err != nil {
+		return err
+	}
+	if err := checkLength(usage, 5, 252, ErrInvalidLengthUsage); err != nil {
+		return err
+	}
+	for _, form := range model.store.forms {
+		if form.ID != formID && form.Name == name {
+			return fmt.Errorf("form with name '%s' already exists", name)
+		}
+	}
+	return nil
+}
+func (model memoryFormModel) Create(name, usage string) (Form, error) {
+	model.store.mu.Lock()
+	defer model.store.mu.Unlock()
+	if err := model.check(0, name, usage); err != nil {
+		return Form{}, err
+	}
+	form := Form{ID: model.store.nextID("forms"), Name: name, Usage: usage}
+	model.store.forms[form.ID] = form
+	model.store.versions[form.ID] = []FormVersion{
+		{FormID: form.ID, Version: 1, CreatedAt: time.Now().UTC().Truncate(time.Second), Labels: []Label{}},
+	}
+	return form, nil
+}
+func (model memoryFormModel) GetByName(name string) (Form, error) {
+	model.store.mu.Lock()
+	defer model.store.mu.Unlock()
+	for _, form := range model.store.forms {
+		if form.Name == name {
+			return form, nil
+		}
+	}
+	return Form{}, sql.ErrNoRows
+}
+func (model memoryFormModel) GetByID(id int64) (Form, error) {
+	model.store.mu.Lock()
+	defer model.store.mu.Unlock()
+	return model.getByID(id)
+}
+func (model memoryFormModel) getByID(id int64) (Form, error) {
+	form, ok := model.store.forms[id]
+	if !ok {
+		return Form{}, sql.ErrNoRows
+	}
+	return form, nil
+}
+func (model memoryFormModel) GetAll() ([]Form, error) {
+	model.store.mu.Lock()
+	defer model.store.mu.Unlock()
+	forms := []Form{}
+	for _, form := range model.store.forms {
+		forms = append(forms, form)
+	}
+	sort.Slice(forms, func(i, j int) bool { return forms[i].ID < forms[j].ID })
+	return forms, nil
+}
+func (model memoryFormModel) DeleteByID(id int64) (Form, error) {
+	model.store.mu.Lock()
+	defer model.store.mu.Unlock()
+	form, err := model.getByID(id)
+	if err != nil {
+		return Form{}, err
+	}
+	model.delete(id)
+	return form, nil
+}
+func (model memoryFormModel) DeleteByName(name string) (Form, error) {
+	form, err := model.GetByName(name)
+	if err != nil {
+		return Form{}, err
+	}
+	return model.DeleteByID(form.ID)
+}
+
+// delete removes the form and cascades to its labels and submissions.
+func (model memoryFormModel) delete(id int64) {
+	delete(model.store.forms, id)
+	delete(model.store.versions, id)
+	for _, label := range model.store.labels {
+		if label.FormID == id {
+			memoryLabelModel(model).delete(label.ID)
+		}
+	}
+	for _, submission := range model.store.submissions {
+		if submission.FormID == id {
+			memorySubmissionModel(model).delete(submission.ID)
+		}
+	}
+	for _, hook := range model.store.hooks {
+		if hook.FormID == id {
+			delete(model.store.hooks, hook.ID)
+		}
+	}
+	for _, webhook := range model.store.webhooks {
+		if webhook.FormID == id {
+			memoryWebhookModel(model).delete(webhook.ID)
+		}
+	}
+}
+func (model memoryFormModel) Update(formID int64, name, usage string) (Form, error) {
+	model.store.mu.Lock()
+	defer model.store.mu.Unlock()
+	if err := model.check(formID, name, usage); err != nil {
+		return Form{}, err
+	}
+	form := Form{ID: formID, Name: name, Usage: usage}
+	if _, ok := model.store.forms[formID]; ok {
+		model.store.forms[formID] = form
+	}
+	return form, nil
+}
+func (model memoryFormModel) Save(form Form, labels []Label) (Form, []Label, error) {
+	model.store.mu.Lock()
+	defer model.store.mu.Unlock()
+	labelModel := memoryLabelModel(model)
+	stored := []Label{}
+	if form.ID != 0 {
+		var err error
+		if stored, err = labelModel.getLabels(form.ID); err != nil {
+			return Form{}, nil, fmt.Errorf("form with form_id:%v does not exists", form.ID)
+		}
+	}
+	if err := model.check(form.ID, form.Name, form.Usage); err != nil {
+		return Form{}, nil, err
+	}
+	labels, err := orderLabels(form.ID, stored, labels)
+	if err != nil {
+		return Form{}, nil, err
+	}
+	byID := map[int64]Label{}
+	for _, label := range stored {
+		byID[label.ID] = label
+	}
+	// everything is checked before the store changes, so a failing save
+	// leaves it as it was
+	for i, label := range labels {
+		if labels[i], err = labelModel.check(label); err != nil {
+			return Form{}, nil, fmt.Errorf("label '%s': %v", label.Name, err)
+		}
+	}
+	changed := form.ID == 0
+	if form.ID == 0 {
+		form.ID = model.store.nextID("forms")
+	}
+	model.store.forms[form.ID] = form
+	kept := map[int64]bool{}
+	for _, label := range labels {
+		kept[label.ID] = true
+	}
+	for _, label := range stored {
+		if !kept[label.ID] {
+			model.store.deleted[label.ID] = true
+			changed = true
+		}
+	}
+	for _, label := range labels {
+		label.FormID = form.ID
+		if label.ID == 0 {
+			label.ID = model.store.nextID("labels")
+		} else if sameLabel(byID[label.ID], label) {
+			continue
+		}
+		model.store.labels[label.ID] = copyLabel(label)
+		changed = true
+	}
+	if changed {
+		labelModel.newVersion(form.ID)
+	}
+	labels, err = labelModel.getLabels(form.ID)
+	return form, labels, err
+}
+func (model memoryFormModel) GetVersions(formID int64) ([]FormVersion, error) {
+	model.store.mu.Lock()
+	defer model.store.mu.Unlock()
+	if _, err := model.getByID(formID); err != nil {
+		return nil, fmt.Errorf("form with form_id:%v does not exists", formID)
+	}
+	versions := []FormVersion{}
+	for _, version := range model.store.versions[formID] {
+		versions = append(versions, copyVersion(version))
+	}
+	return versions, nil
+}
+func (model memoryFormModel) GetVersion(formID, version int64) (FormVersion, error) {
+	model.store.mu.Lock()
+	defer model.store.mu.Unlock()
+	for _, formVersion := range model.store.versions[formID] {
+		if formVersion.Version == version {
+			return copyVersion(formVersion), nil
+		}
+	}
+	return FormVersion{}, sql.ErrNoRows
+}
+func copyVersion(version FormVersion) FormVersion {
+	labels := []Label{}
+	for _, label := range version.Labels {
+		labels = append(labels, copyLabel(label))
+	}
+	version.Labels = labels
+	return version
+}
+
+type memoryLabelModel struct {
+	store *memoryStore
+}
+
+func (model memoryLabelModel) check(label Label) (Label, error) {
+	if label.Type == "" {
+		label.Type = TextLabel
+	}
+	if err := ValidateType(label.Type, label.Choices); err != nil {
+		return Label{}, err
+	}
+	if err := ValidateConstraints(label.Type, label.Constraints); err != nil {
+		return Label{}, err
+	}
+	def, err := ValidateDefault(label)
+	if err != nil {
+		return Label{}, err
+	}
+	label.Default = def
+	if err := checkLength(label.Name, 1, 16, ErrInvalidLengthName); err != nil {
+		return Label{}, err
+	}
+	if err := checkLength(label.Usage, 5, 252, ErrInvalidLengthUsage); err != nil {
+		return Label{}, err
+	}
+	if label.Position < 1 {
+		return Label{}, fmt.Errorf("position has to be at least 1")
+	}
+	return label, nil
+}
+
+// copyLabel keeps callers from sharing the choices and bounds of a stored
+// label.
+func copyLabel(label Label) Label {
+	if label.Choices != nil {
+		label.Choices = append([]string{}, label.Choices...)
+	}
+	if label.Constraints.Min != nil {
+		min := *label.Constraints.Min
+		label.Constraints.Min = &min
+	}
+	if label.Constraints.Max != nil {
+		max := *label.Constraints.Max
+		label.Constraints.Max = &max
+	}
+	return label
+}
+func (model memoryLabelModel) Create(label Label) (Label, error) {
+	model.store.mu.Lock()
+	defer model.store.mu.Unlock()
+	if _, err := memoryFormModel(model).getByID(label.FormID); err != nil {
+		return Label{}, err
+	}
+	label, err := model.check(label)
+	if err != nil {
+		return Label{}, err
+	}
+	label.ID = model.store.nextID("labels")
+	model.store.labels[label.ID] = copyLabel(label)
+	model.newVersion(label.FormID)
+	return label, nil
+}
+
+// newVersion records the current labels of the form as its next version.
+func (model memoryLabelModel) newVersion(formID int64) {
+	labels, err := model.getLabels(formID)
+	if err != nil {
+		return
+	}
+	versions := model.store.versions[formID]
+	model.store.versions[formID] = append(versions, FormVersion{
+		FormID:    formID,
+		Version:   int64(len(versions) + 1),
+		CreatedAt: time.Now().UTC().Truncate(time.Second),
+		Labels:    labels,
+	})
+}
+func (model memoryLabelModel) GetByID(id int64) (Label, error) {
+	model.store.mu.Lock()
+	defer model.store.mu.Unlock()
+	return model.getByID(id)
+}
+func (model memoryLabelModel) getByID(id int64) (Label, error) {
+	label, ok := model.store.labels[id]
+	if !ok || model.store.deleted[id] {
+		return Label{}, sql.ErrNoRows
+	}
+	return copyLabel(label), nil
+}
+func (model memoryLabelModel) GetLabels(formID int64) ([]Label, error) {
+	model.store.mu.Lock()
+	defer model.store.mu.Unlock()
+	return model.getLabels(formID)
+}
+func (model memoryLabelModel) getLabels(formID int64) ([]Label, error) {
+	if _, err := memoryFormModel(model).getByID(formID); err != nil {
+		return nil, fmt.Errorf("GetLabels: form with form_id: %v does not exists", formID)
+	}
+	labels := []Label{}
+	for _, label := range model.store.labels {
+		if label.FormID == formID && !model.store.deleted[label.ID] {
+			labels = append(labels, copyLabel(label))
+		}
+	}
+	sort.Slice(labels, func(i, j int) bool {
+		if labels[i].Position != labels[j].Position {
+			return labels[i].Position < labels[j].Position
+		}
+		return labels[i].ID < labels[j].ID
+	})
+	return labels, nil
+}
+func (model memoryLabelModel) Update(label Label) ([]Label, error) {
+	model.store.mu.Lock()
+	defer model.store.mu.Unlock()
+	labels, err := model.update(label)
+	if err != nil {
+		return nil, err
+	}
+	model.newVersion(label.FormID)
+	return labels, nil
+}
+func (model memoryLabelModel) update(label Label) ([]Label, error) {
+	labels, err := model.getLabels(label.FormID)
+	if err != nil {
+		return nil, err
+	}
+	if int(label.Position) > len(labels) || int(label.Position) < 1 {
+		return nil, fmt.Errorf("position has to be in range between: %v - %v", 1, len(labels))
+	}
+	if label, err = model.check(label); err != nil {
+		return nil, err
+	}
+	var updatingLabel Label
+	var swapLabel Label
+	for _, l := range labels {
+		if l.ID == label.ID {
+			updatingLabel = l
+		}
+		if l.ID != label.ID && l.Name == label.Name {
+			return nil, fmt.Errorf("suggested new label name '%s' already exists", label.Name)
+		}
+		if l.Position == label.Position {
+			swapLabel = l
+		}
+	}
+	if stored, ok := model.store.labels[label.ID]; ok {
+		// like the sql update, the label stays in the form it belongs to
+		updated := copyLabel(label)
+		updated.FormID = stored.FormID
+		model.store.labels[label.ID] = updated
+	}
+	swapLabel.Position = updatingLabel.Position
+	if swapLabel.ID == label.ID || swapLabel.ID == 0 {
+		return []Label{label}, nil
+	}
+	swapped := model.store.labels[swapLabel.ID]
+	swapped.Position = swapLabel.Position
+	model.store.labels[swapLabel.ID] = swapped
+	return []Label{label, swapLabel}, nil
+}
+func (model memoryLabelModel) DeleteByID(id int64) (Label, error) {
+	model.store.mu.Lock()
+	defer model.store.mu.Unlock()
+	label, err := model.getByID(id)
+	if err != nil {
+		return Label{}, err
+	}
+	labels, err := model.getLabels(label.FormID)
+	if err != nil {
+		return Label{}, err
+	}
+	// the label is only marked as deleted so that submissions of earlier
+	// versions of the form keep their entries
+	model.store.deleted[id] = true
+	for _, l := range labels {
+		if l.Position > label.Position {
+			l.Position--
+			model.store.labels[l.ID] = l
+		}
+	}
+	model.newVersion(label.FormID)
+	return label, nil
+}
+
+// delete removes the label and cascades to its entries.
+func (model memoryLabelModel) delete(id int64) {
+	delete(model.store.labels, id)
+	delete(model.store.deleted, id)
+	for _, entry := range model.store.entries {
+		if entry.LabelID == id {
+			delete(model.store.entries, entry.ID)
+		}
+	}
+}
+
+type memorySubmissionModel struct {
+	store *memoryStore
+}
+
+func (model memorySubmissionModel) Create(formID int64) (Submission, error) {
+	model.store.mu.Lock()
+	defer model.store.mu.Unlock()
+	if _, err := memoryFormModel(model).getByID(formID); err != nil {
+		return Submission{}, fmt.Errorf("form with form_id:%v does not exists", formID)
+	}
+	// CURRENT_TIMESTAMP is in utc and has a resolution of a second
+	versions := model.store.versions[formID]
+	submission := Submission{
+		ID:       model.store.nextID("submissions"),
+		FormID:   formID,
+		Version:  versions[len(versions)-1].Version,
+		CreateAt: time.Now().UTC().Truncate(time.Second),
+	}
+	model.store.submissions[submission.ID] = submission
+	return submission, nil
+}
+func (model memorySubmissionModel) Submit(formID int64, values map[int64][]string) (Submission, error) {
+	model.store.mu.Lock()
+	defer model.store.mu.Unlock()
+	labels, err := model.labels(formID)
+	if err != nil {
+		return Submission{}, err
+	}
+	canonical, err := ValidateSubmission(labels, values)
+	if err != nil {
+		return Submission{}, err
+	}
+	return model.submit(formID, labels, canonical, NewSubmission{}), nil
+}
+func (model memorySubmissionModel) SubmitAll(formID int64, newSubmissions []NewSubmission) ([]Submission, error) {
+	model.store.mu.Lock()
+	defer model.store.mu.Unlock()
+	labels, err := model.labels(formID)
+	if err != nil {
+		return nil, err
+	}
+	// everything is validated before anything is stored, which makes the
+	// submissions all or nothing
+	canonical := make([]map[int64][]string, len(newSubmissions))
+	for i, newSubmission := range newSubmissions {
+		if canonical[i], err = ValidateSubmission(labels, newSubmission.Values); err != nil {
+			return nil, fmt.Errorf("submission %v: %w", i+1, err)
+		}
+	}
+	submissions := []Submission{}
+	for i, newSubmission := range newSubmissions {
+		submissions = append(submissions, model.submit(formID, labels, canonical[i], newSubmission))
+	}
+	return submissions, nil
+}
+func (model memorySubmissionModel) labels(formID int64) ([]Label, error) {
+	if _, err := memoryFormModel(model).getByID(formID); err != nil {
+		return nil, fmt.Errorf("form with form_id:%v does not exists", formID)
+	}
+	return memoryLabelModel(model).getLabels(formID)
+}
+func (model memorySubmissionModel) submit(formID int64, labels []Label, values map[int64][]string, newSubmission NewSubmission) Submission {
+	createAt := newSubmission.CreateAt
+	if createAt.IsZero() {
+		createAt = time.Now()
+	}
+	// CURRENT_TIMESTAMP is in utc and has a resolution of a second
+	versions := model.store.versions[formID]
+	submission := Submission{
+		ID:       model.store.nextID("submissions"),
+		FormID:   formID,
+		Version:  versions[len(versions)-1].Version,
+		CreateAt: createAt.UTC().Truncate(time.Second),
+	}
+	model.store.submissions[submission.ID] = submission
+	submission.Entries = []Entry{}
+	for _, label := range labels {
+		for _, txt := range values[label.ID] {
+			entry := Entry{ID: model.store.nextID("entries"), LabelID: label.ID, SubmissionID: submission.ID, Txt: txt}
+			model.store.entries[entry.ID] = entry
+			submission.Entries = append(submission.Entries, entry)
+		}
+	}
+	// queued along with the submission, like sqlite does in its transaction
+	if webhooks := memoryWebhookModel(model).getWebhooks(formID); len(webhooks) != 0 && !newSubmission.SkipWebhooks {
+		form := model.store.forms[formID]
+		// a hook submission always marshals
+		payload, _ := json.Marshal(newHookSubmission(form, submission, labels))
+		memoryWebhookModel(model).enqueue(formID, submission.ID, string(payload), time.Now())
+	}
+	return submission
+}
+func (model memorySubmissionModel) GetSubmissions(formID int64) ([]Submission, error) {
+	model.store.mu.Lock()
+	defer model.store.mu.Unlock()
+	return model.getSubmissions(formID)
+}
+func (model memorySubmissionModel) getSubmissions(formID int64) ([]Submission, error) {
+	if _, err := memoryFormModel(model).getByID(formID); err != nil {
+		return nil, fmt.Errorf("form with form_id:%v does not exists", formID)
+	}
+	submissions := []Submission{}
+	for _, submission := range model.store.submissions {
+		if submission.FormID == formID {
+			submissions = append(submissions, submission)
+		}
+	}
+	sort.Slice(submissions, func(i, j int) bool {
+		if !submissions[i].CreateAt.Equal(submissions[j].CreateAt) {
+			return submissions[i].CreateAt.Before(submissions[j].CreateAt)
+		}
+		return submissions[i].ID < submissions[j].ID
+	})
+	return submissions, nil
+}
+func (model memorySubmissionModel) Query(query SubmissionQuery) ([]Submission, error) {
+	model.store.mu.Lock()
+	defer model.store.mu.Unlock()
+	return model.query(query)
+}
+func (model memorySubmissionModel) QueryEntries(query SubmissionQuery) ([]Entry, error) {
+	model.store.mu.Lock()
+	defer model.store.mu.Unlock()
+	submissions, err := model.query(query)
+	if err != nil {
+		return nil, err
+	}
+	order := map[int64]int{}
+	for i, submission := range submissions {
+		order[submission.ID] = i
+	}
+	entries := []Entry{}
+	for _, entry := range model.store.entries {
+		if _, ok := order[entry.SubmissionID]; ok {
+			entries = append(entries, entry)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].SubmissionID != entries[j].SubmissionID {
+			return order[entries[i].SubmissionID] < order[entries[j].SubmissionID]
+		}
+		return entries[i].ID < entries[j].ID
+	})
+	return entries, nil
+}
+func (model memorySubmissionModel) query(query SubmissionQuery) ([]Submission, error) {
+	all, err := model.getSubmissions(query.FormID)
+	if err != nil {
+		return nil, err
+	}
+	labels, where, err := model.resolveWhere(query)
+	if err != nil {
+		return nil, err
+	}
+	submissions := []Submission{}
+	for _, submission := range all {
+		if query.Limit > 0 && len(submissions) == query.Limit {
+			break
+		}
+		if !query.Since.IsZero() && submission.CreateAt.Before(query.Since) {
+			continue
+		}
+		if !query.Until.IsZero() && !submission.CreateAt.Before(query.Until) {
+			continue
+		}
+		if where != nil {
+			values := map[int64][]string{}
+			for _, entry := range model.store.entries {
+				if entry.SubmissionID == submission.ID {
+					values[entry.LabelID] = append(values[entry.LabelID], entry.Txt)
+				}
+			}
+			if !matchCondition(*where, labels, values) {
+				continue
+			}
+		}
+		submissions = append(submissions, submission)
+	}
+	return submissions, nil
+}
+
+// resolveWhere resolves the condition of query against the current labels
+// of its form.
+func (model memorySubmissionModel) resolveWhere(query SubmissionQuery) (map[string]Label, *Condition, error) {
+	if query.Where == nil {
+		return nil, nil, nil
+	}
+	current, err := memoryLabelModel(model).getLabels(query.FormID)
+	if err != nil {
+		return nil, nil, err
+	}
+	labels := map[string]Label{}
+	for _, label := range current {
+		labels[label.Name] = label
+	}
+	where, err := resolveCondition(*query.Where, labels)
+	if err != nil {
+		return nil, nil, err
+	}
+	return labels, &where, nil
+}
+
+// delete removes the submission and cascades to its entries.
+func (model memorySubmissionModel) delete(id int64) {
+	delete(model.store.submissions, id)
+	for _, entry := range model.store.entries {
+		if entry.SubmissionID == id {
+			delete(model.store.entries, entry.ID)
+		}
+	}
+	for _, change := range model.store.changes {
+		if change.SubmissionID == id {
+			delete(model.store.changes, change.ID)
+		}
+	}
+}
+func (model memorySubmissionModel) GetByID(id int64) (Submission, error) {
+	model.store.mu.Lock()
+	defer model.store.mu.Unlock()
+	submission, _, err := model.getByID(id)
+	return submission, err
+}
+func (model memorySubmissionModel) Edit(id int64, values map[int64][]string) (Submission, error) {
+	model.store.mu.Lock()
+	defer model.store.mu.Unlock()
+	submission, labels, err := model.getByID(id)
+	if err != nil {
+		return Submission{}, err
+	}
+	current := entryValues(submission.Entries)
+	edits, err := ValidateEdit(labels, current, values)
+	if err != nil {
+		return Submission{}, err
+	}
+	for _, label := range labels {
+		if to, ok := edits[label.ID]; ok {
+			model.replaceValues(id, label.ID, current[label.ID], to)
+		}
+	}
+	submission, _, err = model.getByID(id)
+	return submission, err
+}
+func (model memorySubmissionModel) DeleteByID(id int64) (Submission, error) {
+	model.store.mu.Lock()
+	defer model.store.mu.Unlock()
+	submission, _, err := model.getByID(id)
+	if err != nil {
+		return Submission{}, err
+	}
+	model.delete(id)
+	return submission, nil
+}
+func (model memorySubmissionModel) GetChanges(id int64) ([]SubmissionChange, error) {
+	model.store.mu.Lock()
+	defer model.store.mu.Unlock()
+	if _, ok := model.store.submissions[id]; !ok {
+		return nil, sql.ErrNoRows
+	}
+	changes := []SubmissionChange{}
+	for _, change := range model.store.changes {
+		if change.SubmissionID == id {
+			change.From = append([]string{}, change.From...)
+			change.To = append([]string{}, change.To...)
+			changes = append(changes, change)
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].ID < changes[j].ID })
+	return changes, nil
+}
+
+// getByID returns the submission with its entries, in the order of the
+// labels of its version, and those labels.
+func (model memorySubmissionModel) getByID(id int64) (Submission, []Label, error) {
+	submission, ok := model.store.submissions[id]
+	if !ok {
+		return Submission{}, nil, sql.ErrNoRows
+	}
+	labels := []Label{}
+	for _, version := range model.store.versions[submission.FormID] {
+		if version.Version == submission.Version {
+			labels = copyVersion(version).Labels
+		}
+	}
+	position := map[int64]int64{}
+	for _, label := range labels {
+		position[label.ID] = label.Position
+	}
+	submission.Entries = []Entry{}
+	for _, entry := range model.store.entries {
+		if entry.SubmissionID == id {
+			submission.Entries = append(submission.Entries, entry)
+		}
+	}
+	// entries of labels the version does not know come last, like sqlite
+	// sorts the missing positions of its join
+	sort.Slice(submission.Entries, func(i, j int) bool {
+		a, aok := position[submission.Entries[i].LabelID]
+		b, bok := position[submission.Entries[j].LabelID]
+		if aok != bok {
+			return aok
+		}
+		if a != b {
+			return a < b
+		}
+		return submission.Entries[i].ID < submission.Entries[j].ID
+	})
+	return submission, labels, nil
+}
+
+// replaceValues swaps the entries of a label in a submission for new ones
+// and records the change, unless the values stay the same.
+func (model memorySubmissionModel) replaceValues(submissionID, labelID int64, from, to []string) {
+	if sameValues(from, to) {
+		return
+	}
+	for _, entry := range model.store.entries {
+		if entry.SubmissionID == submissionID && entry.LabelID == labelID {
+			delete(model.store.entries, entry.ID)
+		}
+	}
+	for _, txt := range to {
+		entry := Entry{ID: model.store.nextID("entries"), LabelID: labelID, SubmissionID: submissionID, Txt: txt}
+		model.store.entries[entry.ID] = entry
+	}
+	model.recordChange(submissionID, labelID, from, to)
+}
+func (model memorySubmissionModel) recordChange(submissionID, labelID int64, from, to []string) {
+	change := SubmissionChange{
+		ID:           model.store.nextID("submission_changes"),
+		SubmissionID: submissionID,
+		LabelID:      labelID,
+		ChangedAt:    time.Now().UTC().Truncate(time.Second),
+		From:         append([]string{}, from...),
+		To:           append([]string{}, to...),
+	}
+	model.store.changes[change.ID] = change
+}
+
+type memoryEntryModel struct {
+	store *memoryStore
+}
+
+func (model memoryEntryModel) Create(submissionID, labelID int64, txt string) (Entry, error) {
+	model.store.mu.Lock()
+	defer model.store.mu.Unlock()
+	label, err := memoryLabelModel(model).getByID(labelID)
+	if err != nil {
+		return Entry{}, fmt.Errorf("label with label_id:%v does not exists", labelID)
+	}
+	if txt, err = ValidateEntry(label, txt); err != nil {
+		return Entry{}, err
+	}
+	if _, ok := model.store.submissions[submissionID]; !ok {
+		return Entry{}, fmt.Errorf("submission with submission_id:%v does not exists", submissionID)
+	}
+	entry := Entry{ID: model.store.nextID("entries"), LabelID: labelID, SubmissionID: submissionID, Txt: txt}
+	model.store.entries[entry.ID] = entry
+	return entry, nil
+}
+func (model memoryEntryModel) GetEntries(submissionID, labelID int64) ([]Entry, error) {
+	model.store.mu.Lock()
+	defer model.store.mu.Unlock()
+	entries := []Entry{}
+	for _, entry := range model.store.entries {
+		if entry.SubmissionID == submissionID && entry.LabelID == labelID {
+			entries = append(entries, entry)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].ID < entries[j].ID })
+	return entries, nil
+}
+func (model memoryEntryModel) CountEntries(labelID int64) (int64, error) {
+	model.store.mu.Lock()
+	defer model.store.mu.Unlock()
+	var count int64
+	for _, entry := range model.store.entries {
+		if entry.LabelID == labelID {
+			count++
+		}
+	}
+	return count, nil
+}
+func (model memoryEntryModel) Update(id int64, txt string) (Entry, error) {
+	if txt == "" {
+		return Entry{}, ErrEmptyEntry
+	}
+	model.store.mu.Lock()
+	defer model.store.mu.Unlock()
+	entry, labels, current, i, err := model.getByID(id)
+	if err != nil {
+		return Entry{}, err
+	}
+	from := current[entry.LabelID]
+	to := append([]string{}, from...)
+	to[i] = txt
+	edits, err := ValidateEdit(labels, current, map[int64][]string{entry.LabelID: to})
+	if err != nil {
+		return Entry{}, err
+	}
+	entry.Txt = edits[entry.LabelID][i]
+	model.store.entries[id] = entry
+	if !sameValues(from, edits[entry.LabelID]) {
+		memorySubmissionModel(model).recordChange(entry.SubmissionID, entry.LabelID, from, edits[entry.LabelID])
+	}
+	return entry, nil
+}
+func (model memoryEntryModel) DeleteByID(id int64) (Entry, error) {
+	model.store.mu.Lock()
+	defer model.store.mu.Unlock()
+	entry, labels, current, i, err := model.getByID(id)
+	if err != nil {
+		return Entry{}, err
+	}
+	from := current[entry.LabelID]
+	to := append(append([]string{}, from[:i]...), from[i+1:]...)
+	edits, err := ValidateEdit(labels, current, map[int64][]string{entry.LabelID: to})
+	if err != nil {
+		return Entry{}, err
+	}
+	if !sameValues(to, edits[entry.LabelID]) {
+		// the label's default takes the place of its last value
+		memorySubmissionModel(model).replaceValues(entry.SubmissionID, entry.LabelID, from, edits[entry.LabelID])
+		return entry, nil
+	}
+	delete(model.store.entries, id)
+	memorySubmissionModel(model).recordChange(entry.SubmissionID, entry.LabelID, from, to)
+	return entry, nil
+}
+func (model memoryEntryModel) getByID(id int64) (Entry, []Label, map[int64][]string, int, error) {
+	entry, ok := model.store.entries[id]
+	if !ok {
+		return Entry{}, nil, nil, 0, sql.ErrNoRows
+	}
+	submission, labels, err := memorySubmissionModel(model).getByID(entry.SubmissionID)
+	if err != nil {
+		return Entry{}, nil, nil, 0, err
+	}
+	return entry, labels, entryValues(submission.Entries), entryIndex(submission.Entries, entry), nil
+}
+func (model memoryEntryModel) Search(query string, options SearchOptions) ([]SearchResult, error) {
+	terms, err := parseSearch(query)
+	if err != nil {
+		return nil, err
+	}
+	options.defaultMarks()
+	model.store.mu.Lock()
+	defer model.store.mu.Unlock()
+	results := []SearchResult{}
+	matches := map[int64][][2]int{}
+	for _, entry := range model.store.entries {
+		submission := model.store.submissions[entry.SubmissionID]
+		form := model.store.forms[submission.FormID]
+		label := model.store.labels[entry.LabelID]
+		if (options.Form != "" && form.Name != options.Form) || (options.Label != "" && label.Name != options.Label) {
+			continue
+		}
+		found, ok := matchTerms(entry.Txt, terms)
+		if !ok {
+			continue
+		}
+		matches[entry.ID] = found
+		results = append(results, SearchResult{
+			Entry:     entry,
+			FormID:    form.ID,
+			Form:      form.Name,
+			Label:     label.Name,
+			CreateAt:  submission.CreateAt,
+			Highlight: highlight(entry.Txt, found, options.MarkStart, options.MarkEnd),
+		})
+	}
+	rankResults(results, matches)
+	return results, nil
+}
+
+type memoryHookModel struct {
+	store *memoryStore
+}
+
+func (model memoryHookModel) Create(hook Hook) (Hook, error) {
+	model.store.mu.Lock()
+	defer model.store.mu.Unlock()
+	if _, err := memoryFormModel(model).getByID(hook.FormID); err != nil {
+		return Hook{}, err
+	}
+	hook, err := ValidateHook(hook)
+	if err != nil {
+		return Hook{}, err
+	}
+	hook.ID = model.store.nextID("hooks")
+	model.store.hooks[hook.ID] = hook
+	return hook, nil
+}
+func (model memoryHookModel) GetHooks(formID int64) ([]Hook, error) {
+	model.store.mu.Lock()
+	defer model.store.mu.Unlock()
+	hooks := []Hook{}
+	for _, hook := range model.store.hooks {
+		if hook.FormID == formID {
+			hooks = append(hooks, hook)
+		}
+	}
+	sort.Slice(hooks, func(i, j int) bool { return hooks[i].ID < hooks[j].ID })
+	return hooks, nil
+}
+func (model memoryHookModel) DeleteByID(id int64) (Hook, error) {
+	model.store.mu.Lock()
+	defer model.store.mu.Unlock()
+	hook, ok := model.store.hooks[id]
+	if !ok {
+		return Hook{}, sql.ErrNoRows
+	}
+	delete(model.store.hooks, id)
+	return hook, nil
+}
+
+type memoryWebhookModel struct {
+	store *memoryStore
+}
+
+func (model memoryWebhookModel) Create(webhook Webhook) (Webhook, error) {
+	model.store.mu.Lock()
+	defer model.store.mu.Unlock()
+	if _, err := memoryFormModel(model).getByID(webhook.FormID); err != nil {
+		return Webhook{}, err
+	}
+	webhook, err := ValidateWebhook(webhook)
+	if err != nil {
+		return Webhook{}, err
+	}
+	webhook.ID = model.store.nextID("webhooks")
+	model.store.webhooks[webhook.ID] = webhook
+	return webhook, nil
+}
+func (model memoryWebhookModel) GetByID(id int64) (Webhook, error) {
+	model.store.mu.Lock()
+	defer model.store.mu.Unlock()
+	webhook, ok := model.store.webhooks[id]
+	if !ok {
+		return Webhook{}, sql.ErrNoRows
+	}
+	return webhook, nil
+}
+func (model memoryWebhookModel) GetWebhooks(formID int64) ([]Webhook, error) {
+	model.store.mu.Lock()
+	defer model.store.mu.Unlock()
+	return model.getWebhooks(formID), nil
+}
+func (model memoryWebhookModel) getWebhooks(formID int64) []Webhook {
+	webhooks := []Webhook{}
+	for _, webhook := range model.store.webhooks {
+		if webhook.FormID == formID {
+			webhooks = append(webhooks, webhook)
+		}
+	}
+	sort.Slice(webhooks, func(i, j int) bool { return webhooks[i].ID < webhooks[j].ID })
+	return webhooks
+}
+func (model memoryWebhookModel) DeleteByID(id int64) (Webhook, error) {
+	model.store.mu.Lock()
+	defer model.store.mu.Unlock()
+	webhook, ok := model.store.webhooks[id]
+	if !ok {
+		return Webhook{}, sql.ErrNoRows
+	}
+	model.delete(id)
+	return webhook, nil
+}
+func (model memoryWebhookModel) delete(id int64) {
+	delete(model.store.webhooks, id)
+	for _, delivery := range model.store.deliveries {
+		if delivery.WebhookID == id {
+			delete(model.store.deliveries, delivery.ID)
+		}
+	}
+}
+func (model memoryWebhookModel) Enqueue(formID, submissionID int64, payload string, now time.Time) ([]WebhookDelivery, error) {
+	model.store.mu.Lock()
+	defer model.store.mu.Unlock()
+	return model.enqueue(formID, submissionID, payload, now), nil
+}
+func (model memoryWebhookModel) enqueue(formID, submissionID int64, payload string, now time.Time) []WebhookDelivery {
+	// times are kept to the second, like sqlite does
+	now = now.UTC().Truncate(time.Second)
+	deliveries := []WebhookDelivery{}
+	for _, webhook := range model.getWebhooks(formID) {
+		delivery := WebhookDelivery{
+			ID:            model.store.nextID("webhook_deliveries"),
+			WebhookID:     webhook.ID,
+			SubmissionID:  submissionID,
+			Payload:       payload,
+			Status:        DeliveryPending,
+			CreatedAt:     now,
+			NextAttemptAt: now,
+		}
+		model.store.deliveries[delivery.ID] = delivery
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries
+}
+func (model memoryWebhookModel) ClaimDelivery(now, until time.Time) (WebhookDelivery, error) {
+	model.store.mu.Lock()
+	defer model.store.mu.Unlock()
+	now = now.UTC().Truncate(time.Second)
+	claimed := WebhookDelivery{}
+	for _, delivery := range model.store.deliveries {
+		if delivery.Status != DeliveryPending || delivery.NextAttemptAt.After(now) {
+			continue
+		}
+		if claimed.ID == 0 || delivery.NextAttemptAt.Before(claimed.NextAttemptAt) ||
+			(delivery.NextAttemptAt.Equal(claimed.NextAttemptAt) && delivery.ID < claimed.ID) {
+			claimed = delivery
+		}
+	}
+	if claimed.ID == 0 {
+		return WebhookDelivery{}, sql.ErrNoRows
+	}
+	claimed.NextAttemptAt = until.UTC().Truncate(time.Second)
+	model.store.deliveries[claimed.ID] = claimed
+	return claimed, nil
+}
+func (model memoryWebhookModel) UpdateDelivery(delivery WebhookDelivery) (WebhookDelivery, error) {
+	model.store.mu.Lock()
+	defer model.store.mu.Unlock()
+	stored, ok := model.store.deliveries[delivery.ID]
+	if !ok {
+		return WebhookDelivery{}, sql.ErrNoRows
+	}
+	stored.Status = delivery.Status
+	stored.Attempts = delivery.Attempts
+	stored.StatusCode = delivery.StatusCode
+	stored.Error = delivery.Error
+	stored.NextAttemptAt = delivery.NextAttemptAt.UTC().Truncate(time.Second)
+	stored.DeliveredAt = time.Time{}
+	if !delivery.DeliveredAt.IsZero() {
+		stored.DeliveredAt = delivery.DeliveredAt.UTC().Truncate(time.Second)
+	}
+	model.store.deliveries[delivery.ID] = stored
+	return stored, nil
+}
+func (model memoryWebhookModel) GetDeliveries(formID int64) ([]WebhookDelivery, error) {
+	model.store.mu.Lock()
+	defer model.store.mu.Unlock()
+	deliveries := []WebhookDelivery{}
+	for _, delivery := range model.store.deliveries {
+		if webhook, ok := model.store.webhooks[delivery.WebhookID]; ok && webhook.FormID == formID {
+			deliveries = append(deliveries, delivery)
+		}
+	}
+	sort.Slice(deliveries, func(i, j int) bool {
+		if !deliveries[i].CreatedAt.Equal(deliveries[j].CreatedAt) {
+			return deliveries[i].CreatedAt.After(deliveries[j].CreatedAt)
+		}
+		return deliveries[i].ID > deliveries[j].ID
+	})
+	return deliveries, nil
+}
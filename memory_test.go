@@ -0,0 +1,14 @@
+package formly_test
+
+import (
+	"testing"
+
+	"github.com/pablothedeveloper/formly"
+	"github.com/pablothedeveloper/formly/formlytest"
+)
+
+func TestMemoryEnv(t *testing.T) {
+	formlytest.TestEnv(t, func(t *testing.T) *formly.Env {
+		return formly.NewMemoryEnv()
+	})
+}
@@ -0,0 +1,448 @@
+package formly
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Migration is a numbered change of the sqlite schema. AppliedAt is zero
+// for migrations that were not applied to a database yet.
+type Migration struct {
+	Version     int
+	Description string
+	AppliedAt   time.Time
+	up          func(tx *sql.Tx) error
+}
+
+// migrations are applied in order and never change once released, new
+// schema changes get a new migration at the end of the list.
+var migrations = []Migration{
+	{
+		Version:     1,
+		Description: "create forms, labels, submissions and entries",
+		up: execMigration(`
+			CREATE TABLE IF NOT EXISTS forms (
+				form_id INTEGER PRIMARY KEY AUTOINCREMENT,
+				editable BOOL DEFAULT TRUE,
+				deleteable BOOL DEFAULT TRUE,
+				name TEXT UNIQUE NOT NULL CHECK(length(name) >= 1 AND length(name) <= 16),
+				usage TEXT NOT NULL CHECK(length(usage) >= 5 AND length(usage) <= 252)
+			);
+
+			CREATE TABLE IF NOT EXISTS labels (
+				label_id INTEGER PRIMARY KEY AUTOINCREMENT,
+				form_id INTEGER NOT NULL,
+				position INTEGER NOT NULL CHECK(position >= 1),
+				repeatable BOOL DEFAULT FALSE,
+				editable BOOL DEFAULT TRUE,
+				deleteable BOOL DEFAULT TRUE,
+				name TEXT NOT NULL CHECK(length(name) >= 1 AND length(name) <= 16),
+				usage TEXT NOT NULL CHECK(length(usage) >= 5 AND length(usage) <= 252),
+				FOREIGN KEY (form_id) REFERENCES forms (form_id) ON UPDATE CASCADE ON DELETE CASCADE
+			);
+
+			CREATE TABLE IF NOT EXISTS submissions (
+				submission_id INTEGER PRIMARY KEY AUTOINCREMENT,
+				form_id INTEGER NOT NULL,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				FOREIGN KEY (form_id) REFERENCES forms (form_id) ON UPDATE CASCADE ON DELETE CASCADE
+			);
+
+			CREATE TABLE IF NOT EXISTS entries (
+				entry_id INTEGER PRIMARY KEY AUTOINCREMENT,
+				submission_id INTEGER NOT NULL,
+				label_id INTEGER NOT NULL,
+				txt TEXT,
+				FOREIGN KEY (label_id) REFERENCES labels (label_id) ON UPDATE CASCADE ON DELETE CASCADE,
+				FOREIGN KEY (submission_id) REFERENCES submissions (submission_id) ON UPDATE CASCADE ON DELETE CASCADE
+			);`),
+	},
+	{
+		Version:     2,
+		Description: "add types, choices, required and defaults to labels",
+		up: func(tx *sql.Tx) error {
+			for _, column := range []struct{ name, definition string }{
+				{"required", "BOOL DEFAULT FALSE"},
+				{"default_txt", "TEXT NOT NULL DEFAULT ''"},
+				{"type", "TEXT NOT NULL DEFAULT 'text' CHECK(type IN ('text', 'int', 'float', 'date', 'bool', 'enum'))"},
+				{"choices", "TEXT NOT NULL DEFAULT ''"},
+			} {
+				if err := addColumn(tx, "labels", column.name, column.definition); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		Version:     3,
+		Description: "add pattern, length and range constraints of labels",
+		up: execMigration(`
+			CREATE TABLE IF NOT EXISTS label_constraints (
+				label_id INTEGER PRIMARY KEY,
+				pattern TEXT NOT NULL DEFAULT '',
+				min_length INTEGER NOT NULL DEFAULT 0 CHECK(min_length >= 0),
+				max_length INTEGER NOT NULL DEFAULT 0 CHECK(max_length >= 0),
+				min REAL,
+				max REAL,
+				FOREIGN KEY (label_id) REFERENCES labels (label_id) ON UPDATE CASCADE ON DELETE CASCADE
+			);`),
+	},
+	{
+		Version:     4,
+		Description: "add form versions and keep the entries of deleted labels",
+		up: execMigration(`
+			CREATE TABLE form_versions (
+				form_id INTEGER NOT NULL,
+				version INTEGER NOT NULL CHECK(version >= 1),
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				PRIMARY KEY (form_id, version),
+				FOREIGN KEY (form_id) REFERENCES forms (form_id) ON UPDATE CASCADE ON DELETE CASCADE
+			);
+
+			CREATE TABLE label_versions (
+				form_id INTEGER NOT NULL,
+				version INTEGER NOT NULL,
+				label_id INTEGER NOT NULL,
+				position INTEGER NOT NULL,
+				repeatable BOOL NOT NULL,
+				required BOOL NOT NULL,
+				default_txt TEXT NOT NULL,
+				type TEXT NOT NULL,
+				choices TEXT NOT NULL,
+				name TEXT NOT NULL,
+				usage TEXT NOT NULL,
+				pattern TEXT NOT NULL,
+				min_length INTEGER NOT NULL,
+				max_length INTEGER NOT NULL,
+				min REAL,
+				max REAL,
+				PRIMARY KEY (form_id, version, label_id),
+				FOREIGN KEY (form_id, version) REFERENCES form_versions (form_id, version) ON UPDATE CASCADE ON DELETE CASCADE
+			);
+
+			ALTER TABLE labels ADD COLUMN deleted BOOL NOT NULL DEFAULT FALSE;
+			ALTER TABLE submissions ADD COLUMN form_version INTEGER NOT NULL DEFAULT 1;
+
+			-- the shape older submissions were made with is unknown, the
+			-- current labels become the first version of every form
+			INSERT INTO form_versions (form_id, version) SELECT form_id, 1 FROM forms;
+			INSERT INTO label_versions
+				SELECT labels.form_id, 1, labels.label_id, labels.position, IFNULL(labels.repeatable, FALSE),
+					IFNULL(labels.required, FALSE), labels.default_txt, labels.type, labels.choices, labels.name, labels.usage,
+					IFNULL(label_constraints.pattern, ''), IFNULL(label_constraints.min_length, 0),
+					IFNULL(label_constraints.max_length, 0), label_constraints.min, label_constraints.max
+				FROM labels LEFT JOIN label_constraints ON labels.label_id = label_constraints.label_id;`),
+	},
+	{
+		Version:     5,
+		Description: "add the change history of submissions",
+		up: execMigration(`
+			CREATE TABLE submission_changes (
+				change_id INTEGER PRIMARY KEY AUTOINCREMENT,
+				submission_id INTEGER NOT NULL,
+				label_id INTEGER NOT NULL,
+				changed_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				previous TEXT NOT NULL,
+				current TEXT NOT NULL,
+				FOREIGN KEY (submission_id) REFERENCES submissions (submission_id) ON UPDATE CASCADE ON DELETE CASCADE,
+				FOREIGN KEY (label_id) REFERENCES labels (label_id) ON UPDATE CASCADE ON DELETE CASCADE
+			);`),
+	},
+	{
+		Version:     6,
+		Description: "add the hooks of forms",
+		up: execMigration(`
+			CREATE TABLE hooks (
+				hook_id INTEGER PRIMARY KEY AUTOINCREMENT,
+				form_id INTEGER NOT NULL,
+				event TEXT NOT NULL CHECK(event IN ('pre-submit', 'post-submit')),
+				command TEXT NOT NULL CHECK(length(command) >= 1),
+				timeout_ms INTEGER NOT NULL DEFAULT 0 CHECK(timeout_ms >= 0),
+				on_failure TEXT NOT NULL CHECK(on_failure IN ('abort', 'ignore')),
+				FOREIGN KEY (form_id) REFERENCES forms (form_id) ON UPDATE CASCADE ON DELETE CASCADE
+			);`),
+	},
+	{
+		Version:     7,
+		Description: "add the webhooks of forms and their deliveries",
+		up: execMigration(`
+			CREATE TABLE webhooks (
+				webhook_id INTEGER PRIMARY KEY AUTOINCREMENT,
+				form_id INTEGER NOT NULL,
+				url TEXT NOT NULL CHECK(length(url) >= 1),
+				secret TEXT NOT NULL CHECK(length(secret) >= 1),
+				FOREIGN KEY (form_id) REFERENCES forms (form_id) ON UPDATE CASCADE ON DELETE CASCADE
+			);
+			CREATE TABLE webhook_deliveries (
+				delivery_id INTEGER PRIMARY KEY AUTOINCREMENT,
+				webhook_id INTEGER NOT NULL,
+				submission_id INTEGER NOT NULL,
+				payload TEXT NOT NULL,
+				status TEXT NOT NULL CHECK(status IN ('pending', 'delivered', 'failed')),
+				attempts INTEGER NOT NULL DEFAULT 0 CHECK(attempts >= 0),
+				status_code INTEGER NOT NULL DEFAULT 0,
+				error TEXT NOT NULL DEFAULT '',
+				created_at DATETIME NOT NULL,
+				next_attempt_at DATETIME NOT NULL,
+				delivered_at DATETIME,
+				FOREIGN KEY (webhook_id) REFERENCES webhooks (webhook_id) ON UPDATE CASCADE ON DELETE CASCADE
+			);
+			CREATE INDEX webhook_deliveries_due ON webhook_deliveries (status, next_attempt_at);`),
+	},
+	// the search index depends on whether sqlite was built with fts5, so it
+	// is not part of the schema: every build leaves the database at version 8
+	// as it is, and indexEntries brings the index in line with the build on
+	// open
+	{
+		Version:     8,
+		Description: "index the entries for full-text search, kept by each build on open",
+		up:          func(tx *sql.Tx) error { return nil },
+	},
+}
+
+// searchIndex is the fts5 index of the entries, which reads their txt from
+// the entries table, and the triggers keeping it in step with them.
+const searchIndex = `
+	CREATE VIRTUAL TABLE IF NOT EXISTS entry_search USING fts5(txt, content='entries', content_rowid='entry_id');
+	CREATE TRIGGER entry_search_insert AFTER INSERT ON entries BEGIN
+		INSERT INTO entry_search (rowid, txt) VALUES (new.entry_id, new.txt);
+	END;
+	CREATE TRIGGER entry_search_delete AFTER DELETE ON entries BEGIN
+		INSERT INTO entry_search (entry_search, rowid, txt) VALUES ('delete', old.entry_id, old.txt);
+	END;
+	CREATE TRIGGER entry_search_update AFTER UPDATE OF txt ON entries BEGIN
+		INSERT INTO entry_search (entry_search, rowid, txt) VALUES ('delete', old.entry_id, old.txt);
+		INSERT INTO entry_search (rowid, txt) VALUES (new.entry_id, new.txt);
+	END;
+	INSERT INTO entry_search (entry_search) VALUES ('rebuild');`
+
+var searchTriggers = []string{"entry_search_insert", "entry_search_delete", "entry_search_update"}
+
+// searchIndexState tells whether sqlite has fts5 and how many of the
+// triggers of the search index the database has.
+func searchIndexState(q querier) (fts5 bool, triggers int, err error) {
+	if err := q.QueryRow("SELECT sqlite_compileoption_used('ENABLE_FTS5')").Scan(&fts5); err != nil {
+		return false, 0, err
+	}
+	err = q.QueryRow(
+		"SELECT COUNT(*) FROM sqlite_master WHERE type = 'trigger' AND name IN (?, ?, ?)",
+		searchTriggers[0], searchTriggers[1], searchTriggers[2],
+	).Scan(&triggers)
+	return fts5, triggers, err
+}
+
+// syncSearchIndex creates the search index when sqlite has fts5 and the
+// index is not kept up to date yet. Builds without fts5 can not run its
+// triggers and drop them, so that writes keep working; the next build with
+// fts5 to open the database then rebuilds the index from the entries. It
+// reports whether the index can be searched.
+func syncSearchIndex(tx *sql.Tx) (bool, error) {
+	fts5, triggers, err := searchIndexState(tx)
+	if err != nil {
+		return false, err
+	}
+	if fts5 && triggers == len(searchTriggers) || !fts5 && triggers == 0 {
+		return fts5, nil
+	}
+	for _, trigger := range searchTriggers {
+		if _, err := tx.Exec("DROP TRIGGER IF EXISTS " + trigger); err != nil {
+			return false, err
+		}
+	}
+	if !fts5 {
+		return false, nil
+	}
+	_, err = tx.Exec(searchIndex)
+	return err == nil, err
+}
+
+// indexEntries brings the search index in line with the build opening the
+// database, only taking the write lock when something has to change.
+func indexEntries(db *sql.DB) (bool, error) {
+	fts5, triggers, err := searchIndexState(db)
+	if err != nil {
+		return false, err
+	}
+	if fts5 && triggers == len(searchTriggers) || !fts5 && triggers == 0 {
+		return fts5, nil
+	}
+	err = inTx(db, func(tx *sql.Tx) (err error) {
+		fts5, err = syncSearchIndex(tx)
+		return err
+	})
+	return fts5, err
+}
+
+func execMigration(query string) func(tx *sql.Tx) error {
+	return func(tx *sql.Tx) error {
+		_, err := tx.Exec(query)
+		return err
+	}
+}
+
+// addColumn adds a column unless the table has it already, which is the
+// case for databases created before their schema was versioned.
+func addColumn(tx *sql.Tx, table, column, definition string) error {
+	var count int
+	if err := tx.QueryRow(
+		"SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?",
+		table,
+		column,
+	).Scan(&count); err != nil {
+		return err
+	}
+	if count != 0 {
+		return nil
+	}
+	_, err := tx.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
+	return err
+}
+
+// LatestSchemaVersion is the schema version this formly creates and
+// upgrades databases to.
+func LatestSchemaVersion() int {
+	return migrations[len(migrations)-1].Version
+}
+
+// ErrSchemaTooNew ...
+var ErrSchemaTooNew error = errors.New("database was created by a newer version of formly")
+
+const schemaVersionTable = `
+	CREATE TABLE IF NOT EXISTS schema_version (
+		version INTEGER PRIMARY KEY,
+		applied_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);`
+
+func schemaVersion(q interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}) (int, error) {
+	var version int
+	err := q.QueryRow("SELECT IFNULL(MAX(version), 0) FROM schema_version").Scan(&version)
+	return version, err
+}
+
+// migrate brings the database up to the latest schema version, applying
+// each pending migration in its own transaction.
+func migrate(db *sql.DB) ([]Migration, error) {
+	if _, err := db.Exec(schemaVersionTable); err != nil {
+		return nil, err
+	}
+	version, err := schemaVersion(db)
+	if err != nil {
+		return nil, err
+	}
+	if version > LatestSchemaVersion() {
+		return nil, fmt.Errorf(
+			"%w: it is at schema version %v, this formly knows up to %v", ErrSchemaTooNew, version, LatestSchemaVersion(),
+		)
+	}
+	applied := []Migration{}
+	for _, migration := range migrations {
+		if migration.Version <= version {
+			continue
+		}
+		tx, err := db.Begin()
+		if err != nil {
+			return applied, err
+		}
+		// another process may have migrated since the version was read
+		current, err := schemaVersion(tx)
+		if err != nil {
+			tx.Rollback()
+			return applied, err
+		}
+		if current >= migration.Version {
+			tx.Rollback()
+			continue
+		}
+		if err := migration.up(tx); err != nil {
+			tx.Rollback()
+			return applied, fmt.Errorf("migration %v (%s): %v", migration.Version, migration.Description, err)
+		}
+		if _, err := tx.Exec("INSERT INTO schema_version (version) VALUES (?)", migration.Version); err != nil {
+			tx.Rollback()
+			return applied, err
+		}
+		if err := tx.Commit(); err != nil {
+			return applied, err
+		}
+		migration.AppliedAt = time.Now().UTC().Truncate(time.Second)
+		applied = append(applied, migration)
+	}
+	return applied, nil
+}
+
+func openSqLite(path string) (*sql.DB, error) {
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return nil, err
+	}
+	// foreign keys are enabled per connection, so through the dsn rather
+	// than a pragma that only reaches one connection of the pool, and
+	// transactions take the write lock upfront so concurrent writers wait
+	// on each other instead of failing halfway
+	return sql.Open("sqlite3", path+"?_foreign_keys=on&_busy_timeout=5000&_txlock=immediate")
+}
+
+// MigrateSqLite applies the pending migrations to the sqlite database at
+// path and returns them.
+func MigrateSqLite(path string) ([]Migration, error) {
+	db, err := openSqLite(path)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	return migrate(db)
+}
+
+// SqLiteMigrations lists every known migration along with when it was
+// applied to the sqlite database at path, without applying any.
+func SqLiteMigrations(path string) (current int, list []Migration, err error) {
+	applied := map[int]time.Time{}
+	if _, err := os.Stat(path); err == nil {
+		db, err := openSqLite(path)
+		if err != nil {
+			return 0, nil, err
+		}
+		defer db.Close()
+		var exists int
+		if err := db.QueryRow(
+			"SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'schema_version'",
+		).Scan(&exists); err != nil {
+			return 0, nil, err
+		}
+		if exists != 0 {
+			rows, err := db.Query("SELECT version, applied_at FROM schema_version ORDER BY version")
+			if err != nil {
+				return 0, nil, err
+			}
+			defer rows.Close()
+			for rows.Next() {
+				var version int
+				var appliedAt time.Time
+				if err := rows.Scan(&version, &appliedAt); err != nil {
+					return 0, nil, err
+				}
+				applied[version] = appliedAt
+				if version > current {
+					current = version
+				}
+			}
+			if err := rows.Err(); err != nil {
+				return 0, nil, err
+			}
+		}
+	} else if !os.IsNotExist(err) {
+		return 0, nil, err
+	}
+	list = []Migration{}
+	for _, migration := range migrations {
+		migration.AppliedAt = applied[migration.Version]
+		list = append(list, migration)
+	}
+	return current, list, nil
+}
@@ -0,0 +1,147 @@
+package formly
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ChangeKind ...
+type ChangeKind string
+
+// Kinds of changes applying a definition makes.
+const (
+	CreateForm  ChangeKind = "create"
+	UpdateForm  ChangeKind = "update"
+	AddLabel    ChangeKind = "add"
+	RemoveLabel ChangeKind = "remove"
+	RenameLabel ChangeKind = "rename"
+	MoveLabel   ChangeKind = "move"
+	UpdateLabel ChangeKind = "update-label"
+)
+
+// Change is a single difference between a definition and the stored form.
+// Label is empty for changes to the form itself and Field is only set for
+// updates of a single attribute. Entries counts the entries of a removed
+// label, which are only kept with the earlier versions of the form.
+type Change struct {
+	Kind         ChangeKind
+	Label, Field string
+	From, To     string
+	Entries      int64
+}
+
+// Plan ...
+type Plan struct {
+	Form    string
+	Changes []Change
+}
+
+// HasChanges ...
+func (plan Plan) HasChanges() bool {
+	return len(plan.Changes) != 0
+}
+
+// MakePlan compares the definition with the stored form and lists what
+// Apply would change, without writing anything.
+func MakePlan(env *Env, def Definition) (Plan, error) {
+	if err := def.Validate(); err != nil {
+		return Plan{}, err
+	}
+	plan := Plan{Form: def.Name, Changes: []Change{}}
+	form, err := env.FormModel.GetByName(def.Name)
+	if err == sql.ErrNoRows {
+		plan.Changes = append(plan.Changes, Change{Kind: CreateForm, To: def.Usage})
+		for _, labelDef := range def.Labels {
+			plan.Changes = append(plan.Changes, Change{Kind: AddLabel, Label: labelDef.Name, To: string(labelDef.label(0, 0).Type)})
+		}
+		return plan, nil
+	}
+	if err != nil {
+		return Plan{}, err
+	}
+	if form.Usage != def.Usage {
+		plan.Changes = append(plan.Changes, Change{Kind: UpdateForm, Field: "usage", From: form.Usage, To: def.Usage})
+	}
+	labels, err := env.LabelModel.GetLabels(form.ID)
+	if err != nil {
+		return Plan{}, err
+	}
+	matches := matchLabels(labels, def.Labels)
+	kept := []Label{}
+	for _, label := range labels {
+		if _, ok := matches[label.ID]; ok {
+			kept = append(kept, label)
+			continue
+		}
+		count, err := env.EntryModel.CountEntries(label.ID)
+		if err != nil {
+			return Plan{}, err
+		}
+		plan.Changes = append(plan.Changes, Change{Kind: RemoveLabel, Label: label.Name, Entries: count})
+	}
+	// labels are moved when their order relative to the other kept labels
+	// changes, added and removed labels do not count as a move
+	wantedRank := map[int64]int{}
+	rank := 0
+	for i := range def.Labels {
+		for _, label := range kept {
+			if matches[label.ID] == i {
+				wantedRank[label.ID] = rank
+				rank++
+			}
+		}
+	}
+	matched := map[int]bool{}
+	for rank, label := range kept {
+		i := matches[label.ID]
+		matched[i] = true
+		wanted := def.Labels[i].label(form.ID, label.Position)
+		if label.Name != wanted.Name {
+			plan.Changes = append(plan.Changes, Change{Kind: RenameLabel, Label: wanted.Name, From: label.Name, To: wanted.Name})
+		}
+		if wantedRank[label.ID] != rank {
+			plan.Changes = append(plan.Changes, Change{
+				Kind:  MoveLabel,
+				Label: wanted.Name,
+				From:  strconv.Itoa(rank + 1),
+				To:    strconv.Itoa(wantedRank[label.ID] + 1),
+			})
+		}
+		plan.Changes = append(plan.Changes, labelFieldChanges(label, wanted)...)
+	}
+	for i, labelDef := range def.Labels {
+		if !matched[i] {
+			plan.Changes = append(plan.Changes, Change{Kind: AddLabel, Label: labelDef.Name, To: string(labelDef.label(0, 0).Type)})
+		}
+	}
+	return plan, nil
+}
+
+func labelFieldChanges(stored, wanted Label) []Change {
+	changes := []Change{}
+	field := func(name, from, to string) {
+		if from != to {
+			changes = append(changes, Change{Kind: UpdateLabel, Label: wanted.Name, Field: name, From: from, To: to})
+		}
+	}
+	bound := func(b *float64) string {
+		if b == nil {
+			return ""
+		}
+		return strconv.FormatFloat(*b, 'g', -1, 64)
+	}
+	field("usage", stored.Usage, wanted.Usage)
+	field("repeatable", strconv.FormatBool(stored.Repeatable), strconv.FormatBool(wanted.Repeatable))
+	field("required", strconv.FormatBool(stored.Required), strconv.FormatBool(wanted.Required))
+	field("type", string(stored.Type), string(wanted.Type))
+	field("choices", strings.Join(stored.Choices, ","), strings.Join(wanted.Choices, ","))
+	field("default", stored.Default, wanted.Default)
+	field("pattern", stored.Constraints.Pattern, wanted.Constraints.Pattern)
+	field("min_length", fmt.Sprint(stored.Constraints.MinLength), fmt.Sprint(wanted.Constraints.MinLength))
+	field("max_length", fmt.Sprint(stored.Constraints.MaxLength), fmt.Sprint(wanted.Constraints.MaxLength))
+	field("min", bound(stored.Constraints.Min), bound(wanted.Constraints.Min))
+	field("max", bound(stored.Constraints.Max), bound(wanted.Constraints.Max))
+	return changes
+}
@@ -0,0 +1,405 @@
+package formly
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// SubmissionQuery selects the submissions of a form, in the order they were
+// made.
+type SubmissionQuery struct {
+	FormID int64
+	// Where filters the submissions on their values, nil keeps them all.
+	Where *Condition
+	// Since and Until keep the submissions created at or after Since and
+	// before Until, a zero time leaves that side open.
+	Since, Until time.Time
+	// Limit keeps the first submissions only, zero keeps them all.
+	Limit int
+}
+
+// Operator ...
+type Operator string
+
+// Operators of conditions. The comparisons hold when any value of their
+// label does, numbers are compared as numbers and the rest as text.
+// Contains looks for a value of a repeatable label and for a piece of text
+// in other labels.
+const (
+	OpEqual        Operator = "="
+	OpNotEqual     Operator = "!="
+	OpLess         Operator = "<"
+	OpLessEqual    Operator = "<="
+	OpGreater      Operator = ">"
+	OpGreaterEqual Operator = ">="
+	OpContains     Operator = "contains"
+	OpAnd          Operator = "and"
+	OpOr           Operator = "or"
+	OpNot          Operator = "not"
+)
+
+// Condition is a filter on the values of a submission, either a comparison
+// of a label with a value or conditions combined with and, or and not.
+type Condition struct {
+	Op Operator
+	// Label and Value are the operands of a comparison, Label is a label's
+	// name.
+	Label, Value string
+	// Conditions are the operands of and, or and not.
+	Conditions []Condition
+}
+
+// Compare ...
+func Compare(label string, op Operator, value string) Condition {
+	return Condition{Op: op, Label: label, Value: value}
+}
+
+// And ...
+func And(conditions ...Condition) Condition {
+	return Condition{Op: OpAnd, Conditions: conditions}
+}
+
+// Or ...
+func Or(conditions ...Condition) Condition {
+	return Condition{Op: OpOr, Conditions: conditions}
+}
+
+// Not ...
+func Not(condition Condition) Condition {
+	return Condition{Op: OpNot, Conditions: []Condition{condition}}
+}
+
+// String writes the condition the way ParseCondition reads it.
+func (c Condition) String() string {
+	switch c.Op {
+	case OpAnd, OpOr:
+		parts := []string{}
+		for _, condition := range c.Conditions {
+			part := condition.String()
+			if condition.Op == OpAnd || condition.Op == OpOr {
+				part = "(" + part + ")"
+			}
+			parts = append(parts, part)
+		}
+		return strings.Join(parts, " "+string(c.Op)+" ")
+	case OpNot:
+		if len(c.Conditions) != 1 {
+			return "not ()"
+		}
+		return "not (" + c.Conditions[0].String() + ")"
+	}
+	return fmt.Sprintf("%s %s %s", c.Label, c.Op, strconv.Quote(c.Value))
+}
+
+// ErrInvalidCondition ...
+var ErrInvalidCondition error = errors.New("invalid condition")
+
+// ParseCondition reads a condition like mood >= 7 and tags contains "gym".
+// Values are quoted with " or ' when they hold spaces, parentheses or
+// operators, and not, and and or bind in that order.
+func ParseCondition(where string) (Condition, error) {
+	tokens, err := tokenizeCondition(where)
+	if err != nil {
+		return Condition{}, err
+	}
+	p := conditionParser{tokens: tokens}
+	condition, err := p.or()
+	if err != nil {
+		return Condition{}, err
+	}
+	if token := p.peek(); token.kind != endToken {
+		return Condition{}, p.errorf(token, "expected and, or or the end of the condition")
+	}
+	return condition, nil
+}
+
+type tokenKind int
+
+const (
+	endToken tokenKind = iota
+	wordToken
+	stringToken
+	opToken
+	openToken
+	closeToken
+)
+
+type conditionToken struct {
+	kind tokenKind
+	txt  string
+	// pos is the column the token starts at, counting from 1
+	pos int
+}
+
+func tokenizeCondition(where string) ([]conditionToken, error) {
+	tokens := []conditionToken{}
+	runes := []rune(where)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(' || r == ')':
+			kind := openToken
+			if r == ')' {
+				kind = closeToken
+			}
+			tokens = append(tokens, conditionToken{kind: kind, txt: string(r), pos: i + 1})
+			i++
+		case strings.ContainsRune("=!<>", r):
+			op := string(r)
+			if i+1 < len(runes) && strings.ContainsRune("=>", runes[i+1]) {
+				op += string(runes[i+1])
+			}
+			switch op {
+			case "==":
+				op = "="
+			case "<>":
+				op = "!="
+			}
+			switch Operator(op) {
+			case OpEqual, OpNotEqual, OpLess, OpLessEqual, OpGreater, OpGreaterEqual:
+			default:
+				return nil, fmt.Errorf("%w: unknown operator '%s' at column %v", ErrInvalidCondition, op, i+1)
+			}
+			tokens = append(tokens, conditionToken{kind: opToken, txt: op, pos: i + 1})
+			i += len([]rune(op))
+			if op == "=" && i < len(runes) && runes[i] == '=' {
+				i++
+			}
+		case r == '"' || r == '\'':
+			start := i
+			txt := strings.Builder{}
+			for i++; i < len(runes) && runes[i] != r; i++ {
+				if runes[i] == '\\' && i+1 < len(runes) {
+					i++
+				}
+				txt.WriteRune(runes[i])
+			}
+			if i == len(runes) {
+				return nil, fmt.Errorf("%w: unterminated string at column %v", ErrInvalidCondition, start+1)
+			}
+			tokens = append(tokens, conditionToken{kind: stringToken, txt: txt.String(), pos: start + 1})
+			i++
+		default:
+			start := i
+			for i < len(runes) && !unicode.IsSpace(runes[i]) && !strings.ContainsRune(`()=!<>"'`, runes[i]) {
+				i++
+			}
+			tokens = append(tokens, conditionToken{kind: wordToken, txt: string(runes[start:i]), pos: start + 1})
+		}
+	}
+	return append(tokens, conditionToken{kind: endToken, pos: len(runes) + 1}), nil
+}
+
+type conditionParser struct {
+	tokens []conditionToken
+	next   int
+}
+
+func (p *conditionParser) peek() conditionToken {
+	return p.tokens[p.next]
+}
+
+func (p *conditionParser) take() conditionToken {
+	token := p.tokens[p.next]
+	if token.kind != endToken {
+		p.next++
+	}
+	return token
+}
+
+// keyword reports whether the next token is the keyword op.
+func (p *conditionParser) keyword(op Operator) bool {
+	token := p.peek()
+	return token.kind == wordToken && strings.EqualFold(token.txt, string(op))
+}
+
+func (p *conditionParser) errorf(token conditionToken, format string, args ...interface{}) error {
+	got := "the end of the condition"
+	if token.kind != endToken {
+		got = "'" + token.txt + "'"
+	}
+	return fmt.Errorf(
+		"%w: %s, got %s at column %v", ErrInvalidCondition, fmt.Sprintf(format, args...), got, token.pos,
+	)
+}
+
+func (p *conditionParser) or() (Condition, error) {
+	return p.chain(OpOr, p.and)
+}
+
+func (p *conditionParser) and() (Condition, error) {
+	return p.chain(OpAnd, p.unary)
+}
+
+// chain reads operands joined by op into a single condition.
+func (p *conditionParser) chain(op Operator, operand func() (Condition, error)) (Condition, error) {
+	first, err := operand()
+	if err != nil {
+		return Condition{}, err
+	}
+	conditions := []Condition{first}
+	for p.keyword(op) {
+		p.take()
+		next, err := operand()
+		if err != nil {
+			return Condition{}, err
+		}
+		conditions = append(conditions, next)
+	}
+	if len(conditions) == 1 {
+		return first, nil
+	}
+	return Condition{Op: op, Conditions: conditions}, nil
+}
+
+func (p *conditionParser) unary() (Condition, error) {
+	// a label may be called not, it is only the keyword when not compared
+	if p.keyword(OpNot) && p.tokens[p.next+1].kind != opToken {
+		p.take()
+		condition, err := p.unary()
+		if err != nil {
+			return Condition{}, err
+		}
+		return Not(condition), nil
+	}
+	if p.peek().kind == openToken {
+		p.take()
+		condition, err := p.or()
+		if err != nil {
+			return Condition{}, err
+		}
+		if token := p.take(); token.kind != closeToken {
+			return Condition{}, p.errorf(token, "expected )")
+		}
+		return condition, nil
+	}
+	label := p.take()
+	if label.kind != wordToken {
+		return Condition{}, p.errorf(label, "expected a label name")
+	}
+	op := p.take()
+	switch {
+	case op.kind == opToken:
+	case op.kind == wordToken && strings.EqualFold(op.txt, string(OpContains)):
+		op.txt = string(OpContains)
+	default:
+		return Condition{}, p.errorf(op, "expected =, !=, <, <=, >, >= or contains after '%s'", label.txt)
+	}
+	value := p.take()
+	if value.kind != wordToken && value.kind != stringToken {
+		return Condition{}, p.errorf(value, "expected a value after '%s'", op.txt)
+	}
+	return Compare(label.txt, Operator(op.txt), value.txt), nil
+}
+
+// resolveCondition checks c against the labels of a form and returns it
+// with the values of comparisons in their labels' canonical form.
+func resolveCondition(c Condition, labels map[string]Label) (Condition, error) {
+	switch c.Op {
+	case OpAnd, OpOr, OpNot:
+		if len(c.Conditions) == 0 || (c.Op == OpNot && len(c.Conditions) != 1) {
+			return Condition{}, fmt.Errorf("%w: %s needs an operand", ErrInvalidCondition, c.Op)
+		}
+		resolved := Condition{Op: c.Op, Conditions: []Condition{}}
+		for _, condition := range c.Conditions {
+			condition, err := resolveCondition(condition, labels)
+			if err != nil {
+				return Condition{}, err
+			}
+			resolved.Conditions = append(resolved.Conditions, condition)
+		}
+		return resolved, nil
+	case OpEqual, OpNotEqual, OpLess, OpLessEqual, OpGreater, OpGreaterEqual, OpContains:
+	default:
+		return Condition{}, fmt.Errorf("%w: unknown operator '%s'", ErrInvalidCondition, c.Op)
+	}
+	label, ok := labels[c.Label]
+	if !ok {
+		return Condition{}, fmt.Errorf("%w: '%s' is not a label of the form", ErrInvalidCondition, c.Label)
+	}
+	value := c.Value
+	if c.Op != OpContains || label.Repeatable {
+		canonical, err := normalizeEntry(label, c.Value)
+		if err != nil {
+			return Condition{}, fmt.Errorf("%w: %v", ErrInvalidCondition, err)
+		}
+		value = canonical
+	}
+	return Compare(c.Label, c.Op, value), nil
+}
+
+// numericLabel reports whether a label's values are compared as numbers.
+func numericLabel(label Label) bool {
+	return label.Type == IntLabel || label.Type == FloatLabel
+}
+
+// compareValue reports whether a single value of label satisfies the
+// comparison c, the way the sqlite query does.
+func compareValue(label Label, c Condition, txt string) bool {
+	if c.Op == OpContains {
+		if label.Repeatable {
+			return txt == c.Value
+		}
+		return strings.Contains(txt, c.Value)
+	}
+	order := strings.Compare(txt, c.Value)
+	if numericLabel(label) {
+		a, _ := strconv.ParseFloat(txt, 64)
+		b, _ := strconv.ParseFloat(c.Value, 64)
+		order = 0
+		if a < b {
+			order = -1
+		} else if a > b {
+			order = 1
+		}
+	}
+	switch c.Op {
+	case OpEqual:
+		return order == 0
+	case OpNotEqual:
+		return order != 0
+	case OpLess:
+		return order < 0
+	case OpLessEqual:
+		return order <= 0
+	case OpGreater:
+		return order > 0
+	}
+	return order >= 0
+}
+
+// matchCondition evaluates a resolved condition against the values of a
+// submission, keyed by label id.
+func matchCondition(c Condition, labels map[string]Label, values map[int64][]string) bool {
+	switch c.Op {
+	case OpAnd:
+		for _, condition := range c.Conditions {
+			if !matchCondition(condition, labels, values) {
+				return false
+			}
+		}
+		return true
+	case OpOr:
+		for _, condition := range c.Conditions {
+			if matchCondition(condition, labels, values) {
+				return true
+			}
+		}
+		return false
+	case OpNot:
+		return !matchCondition(c.Conditions[0], labels, values)
+	}
+	label := labels[c.Label]
+	for _, txt := range values[label.ID] {
+		if compareValue(label, c, txt) {
+			return true
+		}
+	}
+	return false
+}
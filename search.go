@@ -0,0 +1,170 @@
+package formly
+
+import (
+	"errors"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// SearchOptions ...
+type SearchOptions struct {
+	// Form and Label limit the search to the entries of the forms and labels
+	// named so, empty searches them all.
+	Form, Label string
+	// MarkStart and MarkEnd are put around every match in
+	// SearchResult.Highlight, both default to DefaultSearchMark.
+	MarkStart, MarkEnd string
+}
+
+// DefaultSearchMark ...
+const DefaultSearchMark = "**"
+
+func (options *SearchOptions) defaultMarks() {
+	if options.MarkStart == "" && options.MarkEnd == "" {
+		options.MarkStart, options.MarkEnd = DefaultSearchMark, DefaultSearchMark
+	}
+}
+
+// SearchResult is an entry matching a search, with the names of its form
+// and label and the time its submission was made.
+type SearchResult struct {
+	Entry
+	FormID      int64
+	Form, Label string
+	CreateAt    time.Time
+	// Highlight is the entry's txt with the matches marked.
+	Highlight string
+}
+
+// ErrEmptySearch ...
+var ErrEmptySearch error = errors.New("the search has no terms")
+
+// searchTerm is a word of a search, a trailing * matches it as a prefix.
+type searchTerm struct {
+	word   string
+	prefix bool
+}
+
+// parseSearch splits a search into terms, an entry matches when it holds
+// every term as a word of its own.
+func parseSearch(query string) ([]searchTerm, error) {
+	terms := []searchTerm{}
+	for _, field := range strings.Fields(query) {
+		term := searchTerm{word: strings.Trim(field, `"`)}
+		if strings.HasSuffix(term.word, "*") {
+			term = searchTerm{word: strings.TrimRight(term.word, "*"), prefix: true}
+		}
+		if term.word != "" {
+			terms = append(terms, term)
+		}
+	}
+	if len(terms) == 0 {
+		return nil, ErrEmptySearch
+	}
+	return terms, nil
+}
+
+// ftsQuery quotes every term so the search is never read as fts5 syntax.
+func ftsQuery(terms []searchTerm) string {
+	quoted := []string{}
+	for _, term := range terms {
+		q := `"` + strings.ReplaceAll(term.word, `"`, `""`) + `"`
+		if term.prefix {
+			q += "*"
+		}
+		quoted = append(quoted, q)
+	}
+	return strings.Join(quoted, " ")
+}
+
+// matchTerms finds the matches of the terms in txt, in order. It is the
+// search used where sqlite has no fts5, so it also only matches words.
+func matchTerms(txt string, terms []searchTerm) ([][2]int, bool) {
+	matches := [][2]int{}
+	for _, term := range terms {
+		found := false
+		for _, loc := range regexp.MustCompile("(?i)"+regexp.QuoteMeta(term.word)).FindAllStringIndex(txt, -1) {
+			if !wordBoundary(txt, loc[0], true) || (!term.prefix && !wordBoundary(txt, loc[1], false)) {
+				continue
+			}
+			end := loc[1]
+			for term.prefix && end < len(txt) && !wordBoundary(txt, end, false) {
+				_, size := utf8.DecodeRuneInString(txt[end:])
+				end += size
+			}
+			matches = append(matches, [2]int{loc[0], end})
+			found = true
+		}
+		if !found {
+			return nil, false
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool { return matches[i][0] < matches[j][0] })
+	// overlapping matches of different terms are merged into one
+	merged := [][2]int{}
+	for _, match := range matches {
+		if n := len(merged); n != 0 && match[0] < merged[n-1][1] {
+			if match[1] > merged[n-1][1] {
+				merged[n-1][1] = match[1]
+			}
+			continue
+		}
+		merged = append(merged, match)
+	}
+	return merged, true
+}
+
+// wordBoundary reports whether a word can start, or end, at i.
+func wordBoundary(txt string, i int, start bool) bool {
+	if start {
+		if i == 0 {
+			return true
+		}
+		r, _ := utf8.DecodeLastRuneInString(txt[:i])
+		return !isWordRune(r)
+	}
+	if i == len(txt) {
+		return true
+	}
+	r, _ := utf8.DecodeRuneInString(txt[i:])
+	return !isWordRune(r)
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsNumber(r)
+}
+
+// highlight marks the matches in txt.
+func highlight(txt string, matches [][2]int, start, end string) string {
+	b := strings.Builder{}
+	last := 0
+	for _, match := range matches {
+		b.WriteString(txt[last:match[0]])
+		b.WriteString(start + txt[match[0]:match[1]] + end)
+		last = match[1]
+	}
+	b.WriteString(txt[last:])
+	return b.String()
+}
+
+// rankResults orders results found without fts5 by how much of the entry
+// the matches cover, like bm25 favours short entries matching often, and
+// then by newest first.
+func rankResults(results []SearchResult, matches map[int64][][2]int) {
+	score := func(result SearchResult) float64 {
+		return float64(len(matches[result.ID])) / float64(len(strings.Fields(result.Txt))+1)
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		if si, sj := score(results[i]), score(results[j]); si != sj {
+			return si > sj
+		}
+		if !results[i].CreateAt.Equal(results[j].CreateAt) {
+			return results[i].CreateAt.After(results[j].CreateAt)
+		}
+		return results[i].ID < results[j].ID
+	})
+}
@@ -0,0 +1,175 @@
+package server
+
+import (
+	"time"
+
+	"github.com/pablothedeveloper/formly"
+)
+
+// The documents are the ones of form's --output json, described in the
+// readme, plus the lists and errors of the api.
+
+type errorDocument struct {
+	Error string `json:"error"`
+}
+
+type formsDocument struct {
+	Forms []formDocument `json:"forms"`
+}
+
+type formDocument struct {
+	ID     int64           `json:"id"`
+	Name   string          `json:"name"`
+	Usage  string          `json:"usage"`
+	Labels []labelDocument `json:"labels"`
+}
+
+type labelDocument struct {
+	ID         int64    `json:"id"`
+	Position   int64    `json:"position"`
+	Name       string   `json:"name"`
+	Usage      string   `json:"usage"`
+	Type       string   `json:"type"`
+	Repeatable bool     `json:"repeatable"`
+	Required   bool     `json:"required"`
+	Default    string   `json:"default"`
+	Choices    []string `json:"choices"`
+	Pattern    string   `json:"pattern"`
+	MinLength  int64    `json:"min_length"`
+	MaxLength  int64    `json:"max_length"`
+	Min        *float64 `json:"min"`
+	Max        *float64 `json:"max"`
+}
+
+type submissionsDocument struct {
+	Form        string               `json:"form"`
+	Submissions []submissionDocument `json:"submissions"`
+}
+
+type submissionDocument struct {
+	ID        int64           `json:"id"`
+	Form      string          `json:"form"`
+	Version   int64           `json:"version"`
+	CreatedAt time.Time       `json:"created_at"`
+	Entries   []entryDocument `json:"entries"`
+}
+
+type entryDocument struct {
+	ID      int64  `json:"id"`
+	LabelID int64  `json:"label_id"`
+	Label   string `json:"label"`
+	Value   string `json:"value"`
+}
+
+// entryChangeDocument is an entry that was updated or deleted, along with
+// its submission after the change.
+type entryChangeDocument struct {
+	Entry      entryDocument      `json:"entry"`
+	Submission submissionDocument `json:"submission"`
+}
+
+type changesDocument struct {
+	SubmissionID int64                      `json:"submission_id"`
+	Changes      []submissionChangeDocument `json:"changes"`
+}
+
+type submissionChangeDocument struct {
+	ID        int64     `json:"id"`
+	LabelID   int64     `json:"label_id"`
+	Label     string    `json:"label"`
+	ChangedAt time.Time `json:"changed_at"`
+	From      []string  `json:"from"`
+	To        []string  `json:"to"`
+}
+
+type historyDocument struct {
+	Form     string            `json:"form"`
+	Versions []versionDocument `json:"versions"`
+}
+
+type versionDocument struct {
+	Version     int64           `json:"version"`
+	CreatedAt   time.Time       `json:"created_at"`
+	Submissions int             `json:"submissions"`
+	Labels      []labelDocument `json:"labels"`
+}
+
+func newFormDocument(form formly.Form, labels []formly.Label) formDocument {
+	return formDocument{ID: form.ID, Name: form.Name, Usage: form.Usage, Labels: newLabelDocuments(labels)}
+}
+
+func newLabelDocuments(labels []formly.Label) []labelDocument {
+	docs := []labelDocument{}
+	for _, label := range labels {
+		docs = append(docs, newLabelDocument(label))
+	}
+	return docs
+}
+
+func newLabelDocument(label formly.Label) labelDocument {
+	choices := []string{}
+	choices = append(choices, label.Choices...)
+	return labelDocument{
+		ID:         label.ID,
+		Position:   label.Position,
+		Name:       label.Name,
+		Usage:      label.Usage,
+		Type:       string(label.Type),
+		Repeatable: label.Repeatable,
+		Required:   label.Required,
+		Default:    label.Default,
+		Choices:    choices,
+		Pattern:    label.Constraints.Pattern,
+		MinLength:  label.Constraints.MinLength,
+		MaxLength:  label.Constraints.MaxLength,
+		Min:        label.Constraints.Min,
+		Max:        label.Constraints.Max,
+	}
+}
+
+// label is the label a request body describes, its id and form are left
+// to the handler.
+func (doc labelDocument) label() formly.Label {
+	return formly.Label{
+		Position:   doc.Position,
+		Name:       doc.Name,
+		Usage:      doc.Usage,
+		Type:       formly.LabelType(doc.Type),
+		Repeatable: doc.Repeatable,
+		Required:   doc.Required,
+		Default:    doc.Default,
+		Choices:    doc.Choices,
+		Constraints: formly.Constraints{
+			Pattern:   doc.Pattern,
+			MinLength: doc.MinLength,
+			MaxLength: doc.MaxLength,
+			Min:       doc.Min,
+			Max:       doc.Max,
+		},
+	}
+}
+
+// newSubmissionDocument names the entries after the labels of the version
+// the submission was made with.
+func newSubmissionDocument(form string, submission formly.Submission, labels []formly.Label) submissionDocument {
+	names := map[int64]string{}
+	for _, label := range labels {
+		names[label.ID] = label.Name
+	}
+	doc := submissionDocument{
+		ID:        submission.ID,
+		Form:      form,
+		Version:   submission.Version,
+		CreatedAt: submission.CreateAt,
+		Entries:   []entryDocument{},
+	}
+	for _, entry := range submission.Entries {
+		doc.Entries = append(doc.Entries, entryDocument{
+			ID:      entry.ID,
+			LabelID: entry.LabelID,
+			Label:   names[entry.LabelID],
+			Value:   entry.Txt,
+		})
+	}
+	return doc
+}
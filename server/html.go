@@ -0,0 +1,303 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pablothedeveloper/formly"
+)
+
+// The html pages let people without a terminal fill in forms. They live
+// under /ui and post to themselves, so submissions take the same path as
+// the api and the cli.
+
+// page serves an html page, params holds the path segments matched by *.
+type page func(w http.ResponseWriter, r *http.Request, params []string)
+
+var pageTemplate = template.Must(template.New("page").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; max-width: 40em; margin: 2em auto; padding: 0 1em; }
+.field { margin: 1.2em 0; }
+.field > label { display: block; font-weight: bold; }
+.hint { font-weight: normal; color: #666; }
+.help { display: block; color: #444; margin: .2em 0; }
+input, select { display: block; margin: .2em 0; padding: .3em; min-width: 16em; }
+.error { border-left: 4px solid #c00; padding: .5em 1em; background: #fee; }
+.saved { border-left: 4px solid #080; padding: .5em 1em; background: #efe; }
+</style>
+</head>
+<body>
+{{- if not .Index}}
+<p><a href="/ui">all forms</a></p>
+{{- end}}
+<h1>{{.Title}}</h1>
+{{- with .Usage}}
+<p>{{.}}</p>
+{{- end}}
+{{- with .Saved}}
+<p class="saved">submission {{.}} saved</p>
+{{- end}}
+{{- with .Error}}
+<p class="error">{{.}}</p>
+{{- end}}
+{{- if .Index}}
+<ul>
+{{- range .Forms}}
+<li><a href="/ui/{{.Name}}">{{.Name}}</a> - {{.Usage}}</li>
+{{- else}}
+<li>no forms yet</li>
+{{- end}}
+</ul>
+{{- else if .Fields}}
+<form method="post" action="/ui/{{.Title}}">
+{{- range .Fields}}
+<div class="field">
+<label for="{{.Name}}">{{.Name}}{{with .Hint}} <span class="hint">({{.}})</span>{{end}}</label>
+{{- with .Usage}}
+<span class="help">{{.}}</span>
+{{- end}}
+<div id="{{.Name}}-values">
+{{- $field := .}}
+{{- range $i, $value := .Values}}
+{{- if $field.Options}}
+<select {{if eq $i 0}}id="{{$field.Name}}" {{end}}name="{{$field.Name}}"{{if and $field.Required (eq $i 0)}} required{{end}}>
+<option value=""></option>
+{{- range $field.Options}}
+<option{{if eq . $value}} selected{{end}}>{{.}}</option>
+{{- end}}
+</select>
+{{- else}}
+<input {{if eq $i 0}}id="{{$field.Name}}" {{end}}name="{{$field.Name}}" type="{{$field.Type}}" value="{{$value}}"
+{{- with $field.Step}} step="{{.}}"{{end}}
+{{- with $field.Min}} min="{{.}}"{{end}}
+{{- with $field.Max}} max="{{.}}"{{end}}
+{{- with $field.MinLength}} minlength="{{.}}"{{end}}
+{{- with $field.MaxLength}} maxlength="{{.}}"{{end}}
+{{- with $field.Default}} placeholder="{{.}}"{{end}}
+{{- if and $field.Required (eq $i 0)}} required{{end}}>
+{{- end}}
+{{- end}}
+</div>
+{{- if .Repeatable}}
+<button type="button" onclick="addAnother('{{.Name}}')">add another</button>
+{{- end}}
+</div>
+{{- end}}
+<button type="submit">submit</button>
+</form>
+<script>
+function addAnother(name) {
+	var values = document.getElementById(name + "-values");
+	var input = values.lastElementChild.cloneNode(true);
+	input.removeAttribute("id");
+	input.removeAttribute("required");
+	input.value = "";
+	values.appendChild(input);
+	input.focus();
+}
+</script>
+{{- else if not .Error}}
+<p>no labels yet</p>
+{{- end}}
+</body>
+</html>
+`))
+
+type pageDocument struct {
+	Title, Usage string
+	// Index lists Forms instead of showing a form.
+	Index  bool
+	Forms  []formly.Form
+	Fields []field
+	Saved  int64
+	Error  string
+}
+
+// field is the input, or inputs for a repeatable label, of a label.
+type field struct {
+	Name, Usage, Hint, Default string
+	Repeatable, Required       bool
+	// Type is the type of the input, Options the choices of a select used
+	// in its place.
+	Type                                 string
+	Options                              []string
+	Step, Min, Max, MinLength, MaxLength string
+	// Values holds a value per input.
+	Values []string
+}
+
+func newField(label formly.Label, values []string) field {
+	f := field{
+		Name:       label.Name,
+		Usage:      label.Usage,
+		Default:    label.Default,
+		Repeatable: label.Repeatable,
+		// a label with a default is never missing
+		Required: label.Required && label.Default == "",
+		Type:     "text",
+		Values:   append([]string{}, values...),
+	}
+	hints := []string{}
+	switch label.Type {
+	case formly.IntLabel, formly.FloatLabel:
+		f.Type, f.Step = "number", "1"
+		if label.Type == formly.FloatLabel {
+			f.Step = "any"
+		}
+		f.Min, f.Max = formatBound(label.Constraints.Min), formatBound(label.Constraints.Max)
+		hints = append(hints, string(label.Type))
+	case formly.DateLabel:
+		f.Type = "date"
+		hints = append(hints, "YYYY-MM-DD")
+	case formly.BoolLabel:
+		f.Options = []string{"true", "false"}
+	case formly.EnumLabel:
+		f.Options = label.Choices
+	}
+	if label.Constraints.MinLength != 0 {
+		f.MinLength = strconv.FormatInt(label.Constraints.MinLength, 10)
+	}
+	if label.Constraints.MaxLength != 0 {
+		f.MaxLength = strconv.FormatInt(label.Constraints.MaxLength, 10)
+	}
+	if label.Required {
+		hints = append(hints, "required")
+	}
+	if label.Default != "" {
+		hints = append(hints, "default: "+label.Default)
+	}
+	if label.Repeatable {
+		hints = append(hints, "repeatable")
+	}
+	f.Hint = strings.Join(hints, ", ")
+	if len(f.Values) == 0 {
+		f.Values = []string{""}
+	}
+	return f
+}
+
+func formatBound(bound *float64) string {
+	if bound == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*bound, 'f', -1, 64)
+}
+
+func writePage(w http.ResponseWriter, status int, doc pageDocument) {
+	b := bytes.Buffer{}
+	if err := pageTemplate.Execute(&b, doc); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	w.Write(b.Bytes())
+}
+
+func writePageError(w http.ResponseWriter, err error) {
+	writePage(w, errorStatus(err), pageDocument{Title: "formly", Error: err.Error()})
+}
+
+func (s *server) index(w http.ResponseWriter, r *http.Request, params []string) {
+	forms, err := s.env.FormModel.GetAll()
+	if err != nil {
+		writePageError(w, err)
+		return
+	}
+	writePage(w, http.StatusOK, pageDocument{Title: "forms", Index: true, Forms: forms})
+}
+
+// formPage renders the inputs of form's labels in position order, holding
+// values when a submission is sent back to be fixed.
+func (s *server) formPage(form formly.Form, values url.Values) (pageDocument, []formly.Label, error) {
+	labels, err := s.env.LabelModel.GetLabels(form.ID)
+	if err != nil {
+		return pageDocument{}, nil, err
+	}
+	sort.SliceStable(labels, func(i, j int) bool { return labels[i].Position < labels[j].Position })
+	doc := pageDocument{Title: form.Name, Usage: form.Usage, Fields: []field{}}
+	for _, label := range labels {
+		doc.Fields = append(doc.Fields, newField(label, values[label.Name]))
+	}
+	return doc, labels, nil
+}
+
+func (s *server) getPage(w http.ResponseWriter, r *http.Request, params []string) {
+	form, err := s.form(params[0])
+	if err != nil {
+		writePageError(w, err)
+		return
+	}
+	doc, _, err := s.formPage(form, nil)
+	if err != nil {
+		writePageError(w, err)
+		return
+	}
+	if saved := r.URL.Query().Get("saved"); saved != "" {
+		doc.Saved, _ = strconv.ParseInt(saved, 10, 64)
+	}
+	writePage(w, http.StatusOK, doc)
+}
+
+// postPage submits the form, sending it back with the error and the values
+// entered when they do not validate.
+func (s *server) postPage(w http.ResponseWriter, r *http.Request, params []string) {
+	form, err := s.form(params[0])
+	if err != nil {
+		writePageError(w, err)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		writePageError(w, err)
+		return
+	}
+	doc, labels, err := s.formPage(form, r.PostForm)
+	if err != nil {
+		writePageError(w, err)
+		return
+	}
+	values := map[int64][]string{}
+	for _, label := range labels {
+		if txts, ok := r.PostForm[label.Name]; ok {
+			values[label.ID] = txts
+		}
+	}
+	submission, err := s.submitWithHooks(form, values)
+	if err != nil && submission.ID != 0 {
+		// the submission is stored, the values are not offered again
+		doc, _, _ = s.formPage(form, nil)
+		doc.Saved = submission.ID
+	}
+	if err != nil {
+		doc.Error = err.Error()
+		writePage(w, errorStatus(err), doc)
+		return
+	}
+	http.Redirect(w, r, fmt.Sprintf("/ui/%s?saved=%v", url.PathEscape(form.Name), submission.ID), http.StatusSeeOther)
+}
+
+// sameOrigin refuses requests made by pages of other sites, which browsers
+// would otherwise send along with the credentials of the page. ServeHTTP
+// asks it about every request that is not a safeMethod.
+func sameOrigin(r *http.Request) bool {
+	if r.Header.Get("Sec-Fetch-Site") == "cross-site" {
+		return false
+	}
+	origin := r.Header.Get("Origin")
+	if origin == "" || origin == "null" {
+		return origin == ""
+	}
+	u, err := url.Parse(origin)
+	return err == nil && u.Host == r.Host
+}
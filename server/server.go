@@ -0,0 +1,714 @@
+// Package server exposes a formly env over a json rest api. The documents
+// it reads and writes are the ones form prints with --output json, and
+// the api is described by the OpenAPI document served at /openapi.json.
+// Html pages to fill in the forms are served under /ui.
+package server
+
+import (
+	"crypto/subtle"
+	"database/sql"
+	_ "embed" // for the openapi document
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pablothedeveloper/formly"
+)
+
+//go:embed openapi.json
+var openAPI []byte
+
+// ErrNotFound ...
+var ErrNotFound error = errors.New("not found")
+
+// ErrUnsupportedMediaType ...
+var ErrUnsupportedMediaType error = errors.New("the body has to be application/json")
+
+// maxBody bounds the size of a request body.
+const maxBody = 1 << 20
+
+type server struct {
+	env    *formly.Env
+	token  string
+	routes []route
+}
+
+// handler serves a route, params holds the path segments matched by *.
+type handler func(r *http.Request, params []string) (int, interface{}, error)
+
+// route is served by handle, as json, or by page, as html.
+type route struct {
+	method  string
+	pattern []string
+	handle  handler
+	page    page
+}
+
+// NewHandler serves the api over env, along with html pages to fill in
+// the forms under /ui. When token is not empty every request but the one
+// for the OpenAPI document has to carry it, the api as a bearer token and
+// the pages also as the password of basic auth, which browsers ask for.
+func NewHandler(env *formly.Env, token string) http.Handler {
+	s := &server{env: env, token: token}
+	s.routes = []route{
+		{method: http.MethodGet, pattern: []string{""}, page: home},
+		{method: http.MethodGet, pattern: []string{"ui"}, page: s.index},
+		{method: http.MethodGet, pattern: []string{"ui", "*"}, page: s.getPage},
+		{method: http.MethodPost, pattern: []string{"ui", "*"}, page: s.postPage},
+		{method: http.MethodGet, pattern: []string{"forms"}, handle: s.getForms},
+		{method: http.MethodPost, pattern: []string{"forms"}, handle: s.createForm},
+		{method: http.MethodGet, pattern: []string{"forms", "*"}, handle: s.getForm},
+		{method: http.MethodPatch, pattern: []string{"forms", "*"}, handle: s.updateForm},
+		{method: http.MethodDelete, pattern: []string{"forms", "*"}, handle: s.deleteForm},
+		{method: http.MethodGet, pattern: []string{"forms", "*", "versions"}, handle: s.getVersions},
+		{method: http.MethodGet, pattern: []string{"forms", "*", "labels"}, handle: s.getLabels},
+		{method: http.MethodPost, pattern: []string{"forms", "*", "labels"}, handle: s.createLabel},
+		{method: http.MethodPatch, pattern: []string{"forms", "*", "labels", "*"}, handle: s.updateLabel},
+		{method: http.MethodDelete, pattern: []string{"forms", "*", "labels", "*"}, handle: s.deleteLabel},
+		{method: http.MethodGet, pattern: []string{"forms", "*", "submissions"}, handle: s.getSubmissions},
+		{method: http.MethodPost, pattern: []string{"forms", "*", "submissions"}, handle: s.submit},
+		{method: http.MethodGet, pattern: []string{"submissions", "*"}, handle: s.getSubmission},
+		{method: http.MethodPatch, pattern: []string{"submissions", "*"}, handle: s.editSubmission},
+		{method: http.MethodDelete, pattern: []string{"submissions", "*"}, handle: s.deleteSubmission},
+		{method: http.MethodGet, pattern: []string{"submissions", "*", "changes"}, handle: s.getChanges},
+		{method: http.MethodPatch, pattern: []string{"entries", "*"}, handle: s.updateEntry},
+		{method: http.MethodDelete, pattern: []string{"entries", "*"}, handle: s.deleteEntry},
+	}
+	return s
+}
+
+func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/openapi.json" && r.Method == http.MethodGet {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(openAPI)
+		return
+	}
+	page := r.URL.Path == "/" || r.URL.Path == "/ui" || strings.HasPrefix(r.URL.Path, "/ui/")
+	if !s.authorized(r, page) {
+		if page {
+			w.Header().Set("WWW-Authenticate", `Basic realm="formly", charset="UTF-8"`)
+			http.Error(w, "the token is needed as the password", http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		writeJSON(w, http.StatusUnauthorized, errorDocument{Error: "missing or wrong bearer token"})
+		return
+	}
+	if !safeMethod(r.Method) && !sameOrigin(r) {
+		if page {
+			writePage(w, http.StatusForbidden, pageDocument{Title: "formly", Error: "submissions from other sites are refused"})
+			return
+		}
+		writeJSON(w, http.StatusForbidden, errorDocument{Error: "requests from other sites are refused"})
+		return
+	}
+	segments := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	allowed := []string{}
+	for _, route := range s.routes {
+		params, ok := route.match(segments)
+		if !ok {
+			continue
+		}
+		if route.method != r.Method {
+			allowed = append(allowed, route.method)
+			continue
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, maxBody)
+		if route.page != nil {
+			route.page(w, r, params)
+			return
+		}
+		// every api route that takes a body takes json, bodies of other
+		// media types are refused since browsers send those from any site
+		// without asking
+		if r.Method == http.MethodPost || r.Method == http.MethodPatch {
+			if !jsonBody(r) {
+				writeJSON(w, http.StatusUnsupportedMediaType, errorDocument{Error: ErrUnsupportedMediaType.Error()})
+				return
+			}
+		}
+		status, doc, err := route.handle(r, params)
+		if err != nil {
+			writeJSON(w, errorStatus(err), errorDocument{Error: err.Error()})
+			return
+		}
+		writeJSON(w, status, doc)
+		return
+	}
+	if len(allowed) != 0 {
+		w.Header().Set("Allow", strings.Join(allowed, ", "))
+		writeJSON(w, http.StatusMethodNotAllowed, errorDocument{Error: "method not allowed"})
+		return
+	}
+	writeJSON(w, http.StatusNotFound, errorDocument{Error: "no such endpoint"})
+}
+
+// authorized reports whether r carries the token, any is fine without one.
+// Basic auth is only taken when basic is true, browsers send it along with
+// the requests other sites make.
+func (s *server) authorized(r *http.Request, basic bool) bool {
+	if s.token == "" {
+		return true
+	}
+	token := ""
+	if bearer := r.Header.Get("Authorization"); strings.HasPrefix(bearer, "Bearer ") {
+		token = strings.TrimPrefix(bearer, "Bearer ")
+	}
+	if _, password, ok := r.BasicAuth(); ok && basic {
+		token = password
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) == 1
+}
+
+// safeMethod reports whether requests of method only read.
+func safeMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
+
+func home(w http.ResponseWriter, r *http.Request, params []string) {
+	http.Redirect(w, r, "/ui", http.StatusSeeOther)
+}
+
+func (route route) match(segments []string) ([]string, bool) {
+	if len(segments) != len(route.pattern) {
+		return nil, false
+	}
+	params := []string{}
+	for i, part := range route.pattern {
+		if part == "*" {
+			params = append(params, segments[i])
+			continue
+		}
+		if part != segments[i] {
+			return nil, false
+		}
+	}
+	return params, true
+}
+
+// errorStatus tells missing things apart from requests the models turned
+// down.
+func errorStatus(err error) int {
+	if errors.Is(err, ErrNotFound) || errors.Is(err, sql.ErrNoRows) {
+		return http.StatusNotFound
+	}
+	// only hooks fail once the submission is stored
+	hookErr := &formly.HookError{}
+	if errors.As(err, &hookErr) && hookErr.Hook.Event == formly.PostSubmit {
+		return http.StatusInternalServerError
+	}
+	return http.StatusBadRequest
+}
+
+func writeJSON(w http.ResponseWriter, status int, doc interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if doc == nil {
+		w.WriteHeader(status)
+		return
+	}
+	body, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprintf(w, "{\"error\": %q}\n", err.Error())
+		return
+	}
+	w.WriteHeader(status)
+	w.Write(append(body, '\n'))
+}
+
+// jsonBody reports whether the body of r is json.
+func jsonBody(r *http.Request) bool {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return err == nil && mediaType == "application/json"
+}
+
+// decode reads the json body into doc, which may hold defaults that the
+// body only overrides where it has a field.
+func decode(r *http.Request, doc interface{}) error {
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(doc); err != nil {
+		return fmt.Errorf("reading body: %w", err)
+	}
+	return nil
+}
+
+func (s *server) form(name string) (formly.Form, error) {
+	form, err := s.env.FormModel.GetByName(name)
+	if err == sql.ErrNoRows {
+		return formly.Form{}, fmt.Errorf("form '%s' %w", name, ErrNotFound)
+	}
+	return form, err
+}
+
+func (s *server) label(formName, name string) (formly.Form, formly.Label, error) {
+	form, err := s.form(formName)
+	if err != nil {
+		return formly.Form{}, formly.Label{}, err
+	}
+	labels, err := s.env.LabelModel.GetLabels(form.ID)
+	if err != nil {
+		return formly.Form{}, formly.Label{}, err
+	}
+	for _, label := range labels {
+		if label.Name == name {
+			return form, label, nil
+		}
+	}
+	return formly.Form{}, formly.Label{}, fmt.Errorf("label '%s' of form '%s' %w", name, formName, ErrNotFound)
+}
+
+func (s *server) formDocument(form formly.Form) (formDocument, error) {
+	labels, err := s.env.LabelModel.GetLabels(form.ID)
+	if err != nil {
+		return formDocument{}, err
+	}
+	return newFormDocument(form, labels), nil
+}
+
+func (s *server) getForms(r *http.Request, params []string) (int, interface{}, error) {
+	forms, err := s.env.FormModel.GetAll()
+	if err != nil {
+		return 0, nil, err
+	}
+	docs := []formDocument{}
+	for _, form := range forms {
+		doc, err := s.formDocument(form)
+		if err != nil {
+			return 0, nil, err
+		}
+		docs = append(docs, doc)
+	}
+	return http.StatusOK, formsDocument{Forms: docs}, nil
+}
+
+func (s *server) createForm(r *http.Request, params []string) (int, interface{}, error) {
+	doc := formDocument{}
+	if err := decode(r, &doc); err != nil {
+		return 0, nil, err
+	}
+	if err := validateForm(doc); err != nil {
+		return 0, nil, err
+	}
+	form, err := s.env.FormModel.Create(doc.Name, doc.Usage)
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusCreated, newFormDocument(form, nil), nil
+}
+
+// validateForm holds the name and usage of a form to the rules of form
+// definitions.
+func validateForm(doc formDocument) error {
+	if err := formly.ValidateName(doc.Name); err != nil {
+		return err
+	}
+	return formly.ValidateUsage(doc.Usage)
+}
+
+func (s *server) getForm(r *http.Request, params []string) (int, interface{}, error) {
+	form, err := s.form(params[0])
+	if err != nil {
+		return 0, nil, err
+	}
+	doc, err := s.formDocument(form)
+	return http.StatusOK, doc, err
+}
+
+func (s *server) updateForm(r *http.Request, params []string) (int, interface{}, error) {
+	form, err := s.form(params[0])
+	if err != nil {
+		return 0, nil, err
+	}
+	doc := formDocument{Name: form.Name, Usage: form.Usage}
+	if err := decode(r, &doc); err != nil {
+		return 0, nil, err
+	}
+	if err := validateForm(doc); err != nil {
+		return 0, nil, err
+	}
+	if form, err = s.env.FormModel.Update(form.ID, doc.Name, doc.Usage); err != nil {
+		return 0, nil, err
+	}
+	doc, err = s.formDocument(form)
+	return http.StatusOK, doc, err
+}
+
+func (s *server) deleteForm(r *http.Request, params []string) (int, interface{}, error) {
+	form, err := s.form(params[0])
+	if err != nil {
+		return 0, nil, err
+	}
+	doc, err := s.formDocument(form)
+	if err != nil {
+		return 0, nil, err
+	}
+	_, err = s.env.FormModel.DeleteByID(form.ID)
+	return http.StatusOK, doc, err
+}
+
+func (s *server) getVersions(r *http.Request, params []string) (int, interface{}, error) {
+	form, err := s.form(params[0])
+	if err != nil {
+		return 0, nil, err
+	}
+	versions, err := s.env.FormModel.GetVersions(form.ID)
+	if err != nil {
+		return 0, nil, err
+	}
+	submissions, err := s.env.SubmissionModel.GetSubmissions(form.ID)
+	if err != nil {
+		return 0, nil, err
+	}
+	counts := map[int64]int{}
+	for _, submission := range submissions {
+		counts[submission.Version]++
+	}
+	doc := historyDocument{Form: form.Name, Versions: []versionDocument{}}
+	for _, version := range versions {
+		doc.Versions = append(doc.Versions, versionDocument{
+			Version:     version.Version,
+			CreatedAt:   version.CreatedAt,
+			Submissions: counts[version.Version],
+			Labels:      newLabelDocuments(version.Labels),
+		})
+	}
+	return http.StatusOK, doc, nil
+}
+
+func (s *server) getLabels(r *http.Request, params []string) (int, interface{}, error) {
+	form, err := s.form(params[0])
+	if err != nil {
+		return 0, nil, err
+	}
+	doc, err := s.formDocument(form)
+	return http.StatusOK, doc.Labels, err
+}
+
+func (s *server) createLabel(r *http.Request, params []string) (int, interface{}, error) {
+	form, err := s.form(params[0])
+	if err != nil {
+		return 0, nil, err
+	}
+	labels, err := s.env.LabelModel.GetLabels(form.ID)
+	if err != nil {
+		return 0, nil, err
+	}
+	// new labels go last unless the body says otherwise
+	doc := labelDocument{Type: string(formly.TextLabel), Position: int64(len(labels) + 1)}
+	if err := decode(r, &doc); err != nil {
+		return 0, nil, err
+	}
+	label := doc.label()
+	label.FormID = form.ID
+	if err := formly.ValidateLabelName(label.Name); err != nil {
+		return 0, nil, err
+	}
+	if label, err = s.env.LabelModel.Create(label); err != nil {
+		return 0, nil, err
+	}
+	return http.StatusCreated, newLabelDocument(label), nil
+}
+
+func (s *server) updateLabel(r *http.Request, params []string) (int, interface{}, error) {
+	form, label, err := s.label(params[0], params[1])
+	if err != nil {
+		return 0, nil, err
+	}
+	doc := newLabelDocument(label)
+	if err := decode(r, &doc); err != nil {
+		return 0, nil, err
+	}
+	updated := doc.label()
+	updated.ID, updated.FormID = label.ID, form.ID
+	// a label named before its name was reserved keeps it
+	if updated.Name != label.Name {
+		if err := formly.ValidateLabelName(updated.Name); err != nil {
+			return 0, nil, err
+		}
+	}
+	labels, err := s.env.LabelModel.Update(updated)
+	if err != nil {
+		return 0, nil, err
+	}
+	for _, label := range labels {
+		if label.ID == updated.ID {
+			return http.StatusOK, newLabelDocument(label), nil
+		}
+	}
+	return http.StatusOK, newLabelDocument(updated), nil
+}
+
+func (s *server) deleteLabel(r *http.Request, params []string) (int, interface{}, error) {
+	_, label, err := s.label(params[0], params[1])
+	if err != nil {
+		return 0, nil, err
+	}
+	if label, err = s.env.LabelModel.DeleteByID(label.ID); err != nil {
+		return 0, nil, err
+	}
+	return http.StatusOK, newLabelDocument(label), nil
+}
+
+// submissionQuery reads the where, since, until and limit parameters of a
+// listing of submissions.
+func submissionQuery(r *http.Request, formID int64) (formly.SubmissionQuery, error) {
+	values := r.URL.Query()
+	query := formly.SubmissionQuery{FormID: formID}
+	if where := values.Get("where"); where != "" {
+		condition, err := formly.ParseCondition(where)
+		if err != nil {
+			return query, err
+		}
+		query.Where = &condition
+	}
+	for _, param := range []struct {
+		name string
+		t    *time.Time
+	}{{"since", &query.Since}, {"until", &query.Until}} {
+		txt := values.Get(param.name)
+		if txt == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, txt)
+		if err != nil {
+			if t, err = time.Parse(formly.DateLayout, txt); err != nil {
+				return query, fmt.Errorf("%s '%s' is neither a YYYY-MM-DD date nor an RFC 3339 time", param.name, txt)
+			}
+		}
+		*param.t = t
+	}
+	if limit := values.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 0 {
+			return query, fmt.Errorf("limit '%s' is not a positive number", limit)
+		}
+		query.Limit = n
+	}
+	return query, nil
+}
+
+func (s *server) getSubmissions(r *http.Request, params []string) (int, interface{}, error) {
+	form, err := s.form(params[0])
+	if err != nil {
+		return 0, nil, err
+	}
+	query, err := submissionQuery(r, form.ID)
+	if err != nil {
+		return 0, nil, err
+	}
+	submissions, err := s.env.SubmissionModel.Query(query)
+	if err != nil {
+		return 0, nil, err
+	}
+	doc := submissionsDocument{Form: form.Name, Submissions: []submissionDocument{}}
+	for _, submission := range submissions {
+		submissionDoc, err := s.submissionDocument(form, submission.ID)
+		if err != nil {
+			return 0, nil, err
+		}
+		doc.Submissions = append(doc.Submissions, submissionDoc)
+	}
+	return http.StatusOK, doc, nil
+}
+
+// submissionDocument names the entries of a submission after the labels of
+// the version it was made with.
+func (s *server) submissionDocument(form formly.Form, id int64) (submissionDocument, error) {
+	submission, err := s.env.SubmissionModel.GetByID(id)
+	if err != nil {
+		return submissionDocument{}, err
+	}
+	version, err := s.env.FormModel.GetVersion(form.ID, submission.Version)
+	if err != nil {
+		return submissionDocument{}, err
+	}
+	return newSubmissionDocument(form.Name, submission, version.Labels), nil
+}
+
+// submissionForm finds a submission's form and the labels of its version.
+func (s *server) submissionForm(id string) (formly.Submission, formly.Form, []formly.Label, error) {
+	submissionID, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return formly.Submission{}, formly.Form{}, nil, fmt.Errorf("submission %s %w", id, ErrNotFound)
+	}
+	submission, err := s.env.SubmissionModel.GetByID(submissionID)
+	if err == sql.ErrNoRows {
+		return formly.Submission{}, formly.Form{}, nil, fmt.Errorf("submission %s %w", id, ErrNotFound)
+	}
+	if err != nil {
+		return formly.Submission{}, formly.Form{}, nil, err
+	}
+	form, err := s.env.FormModel.GetByID(submission.FormID)
+	if err != nil {
+		return formly.Submission{}, formly.Form{}, nil, err
+	}
+	version, err := s.env.FormModel.GetVersion(form.ID, submission.Version)
+	if err != nil {
+		return formly.Submission{}, formly.Form{}, nil, err
+	}
+	return submission, form, version.Labels, nil
+}
+
+func (s *server) submit(r *http.Request, params []string) (int, interface{}, error) {
+	form, err := s.form(params[0])
+	if err != nil {
+		return 0, nil, err
+	}
+	labels, err := s.env.LabelModel.GetLabels(form.ID)
+	if err != nil {
+		return 0, nil, err
+	}
+	values, err := formly.ParseSubmission(r.Body, labels)
+	if err != nil {
+		return 0, nil, err
+	}
+	submission, err := s.submitWithHooks(form, values)
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusCreated, newSubmissionDocument(form.Name, submission, labels), nil
+}
+
+// submitWithHooks stores a submission through the form's hooks, logging
+// the hooks that failed without stopping it.
+func (s *server) submitWithHooks(form formly.Form, values map[int64][]string) (formly.Submission, error) {
+	submission, ignored, err := s.env.SubmitWithHooks(form.ID, values)
+	for _, hookErr := range ignored {
+		log.Printf("form '%s': %v", form.Name, hookErr)
+	}
+	if err != nil && submission.ID != 0 {
+		return submission, fmt.Errorf("submission %v was stored but %w", submission.ID, err)
+	}
+	return submission, err
+}
+
+func (s *server) getSubmission(r *http.Request, params []string) (int, interface{}, error) {
+	submission, form, labels, err := s.submissionForm(params[0])
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusOK, newSubmissionDocument(form.Name, submission, labels), nil
+}
+
+func (s *server) editSubmission(r *http.Request, params []string) (int, interface{}, error) {
+	submission, form, labels, err := s.submissionForm(params[0])
+	if err != nil {
+		return 0, nil, err
+	}
+	values, err := formly.ParseSubmission(r.Body, labels)
+	if err != nil {
+		return 0, nil, err
+	}
+	if submission, err = s.env.SubmissionModel.Edit(submission.ID, values); err != nil {
+		return 0, nil, err
+	}
+	return http.StatusOK, newSubmissionDocument(form.Name, submission, labels), nil
+}
+
+func (s *server) deleteSubmission(r *http.Request, params []string) (int, interface{}, error) {
+	submission, form, labels, err := s.submissionForm(params[0])
+	if err != nil {
+		return 0, nil, err
+	}
+	if submission, err = s.env.SubmissionModel.DeleteByID(submission.ID); err != nil {
+		return 0, nil, err
+	}
+	return http.StatusOK, newSubmissionDocument(form.Name, submission, labels), nil
+}
+
+func (s *server) getChanges(r *http.Request, params []string) (int, interface{}, error) {
+	submission, _, labels, err := s.submissionForm(params[0])
+	if err != nil {
+		return 0, nil, err
+	}
+	changes, err := s.env.SubmissionModel.GetChanges(submission.ID)
+	if err != nil {
+		return 0, nil, err
+	}
+	names := map[int64]string{}
+	for _, label := range labels {
+		names[label.ID] = label.Name
+	}
+	doc := changesDocument{SubmissionID: submission.ID, Changes: []submissionChangeDocument{}}
+	for _, change := range changes {
+		doc.Changes = append(doc.Changes, submissionChangeDocument{
+			ID:        change.ID,
+			LabelID:   change.LabelID,
+			Label:     names[change.LabelID],
+			ChangedAt: change.ChangedAt,
+			From:      change.From,
+			To:        change.To,
+		})
+	}
+	return http.StatusOK, doc, nil
+}
+
+func entryID(id string) (int64, error) {
+	entryID, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("entry %s %w", id, ErrNotFound)
+	}
+	return entryID, nil
+}
+
+// entryDocument returns the changed entry along with its submission as it
+// is after the change.
+func (s *server) entryDocument(entry formly.Entry) (entryChangeDocument, error) {
+	submission, form, labels, err := s.submissionForm(strconv.FormatInt(entry.SubmissionID, 10))
+	if err != nil {
+		return entryChangeDocument{}, err
+	}
+	doc := newSubmissionDocument(form.Name, submission, labels)
+	for _, label := range labels {
+		if label.ID == entry.LabelID {
+			return entryChangeDocument{
+				Entry:      entryDocument{ID: entry.ID, LabelID: entry.LabelID, Label: label.Name, Value: entry.Txt},
+				Submission: doc,
+			}, nil
+		}
+	}
+	return entryChangeDocument{Entry: entryDocument{ID: entry.ID, LabelID: entry.LabelID, Value: entry.Txt}, Submission: doc}, nil
+}
+
+func (s *server) updateEntry(r *http.Request, params []string) (int, interface{}, error) {
+	id, err := entryID(params[0])
+	if err != nil {
+		return 0, nil, err
+	}
+	doc := entryDocument{}
+	if err := decode(r, &doc); err != nil {
+		return 0, nil, err
+	}
+	entry, err := s.env.EntryModel.Update(id, doc.Value)
+	if err == sql.ErrNoRows {
+		return 0, nil, fmt.Errorf("entry %s %w", params[0], ErrNotFound)
+	}
+	if err != nil {
+		return 0, nil, err
+	}
+	changed, err := s.entryDocument(entry)
+	return http.StatusOK, changed, err
+}
+
+func (s *server) deleteEntry(r *http.Request, params []string) (int, interface{}, error) {
+	id, err := entryID(params[0])
+	if err != nil {
+		return 0, nil, err
+	}
+	entry, err := s.env.EntryModel.DeleteByID(id)
+	if err == sql.ErrNoRows {
+		return 0, nil, fmt.Errorf("entry %s %w", params[0], ErrNotFound)
+	}
+	if err != nil {
+		return 0, nil, err
+	}
+	changed, err := s.entryDocument(entry)
+	return http.StatusOK, changed, err
+}
@@ -0,0 +1,340 @@
+package server_test
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/pablothedeveloper/formly"
+	"github.com/pablothedeveloper/formly/server"
+)
+
+func must(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func createForm(t *testing.T, env *formly.Env, name string) formly.Form {
+	t.Helper()
+	form, err := env.FormModel.Create(name, "usage of "+name)
+	must(t, err)
+	return form
+}
+
+func createLabel(t *testing.T, env *formly.Env, label formly.Label) formly.Label {
+	t.Helper()
+	if label.Usage == "" {
+		label.Usage = "usage of " + label.Name
+	}
+	label, err := env.LabelModel.Create(label)
+	must(t, err)
+	return label
+}
+
+func labelNames(t *testing.T, env *formly.Env, formID int64) string {
+	t.Helper()
+	labels, err := env.LabelModel.GetLabels(formID)
+	must(t, err)
+	names := []string{}
+	for _, label := range labels {
+		names = append(names, label.Name)
+	}
+	return strings.Join(names, ",")
+}
+
+func TestAPI(t *testing.T) {
+	env := formly.NewMemoryEnv()
+	defer env.Close()
+	srv := httptest.NewServer(server.NewHandler(env, "secret"))
+	defer srv.Close()
+	// request sends body to path and decodes the response into doc
+	request := func(method, path, token, body string, doc interface{}) int {
+		t.Helper()
+		req, err := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+		must(t, err)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		if body != "" {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		resp, err := srv.Client().Do(req)
+		must(t, err)
+		defer resp.Body.Close()
+		if doc != nil {
+			must(t, json.NewDecoder(resp.Body).Decode(doc))
+		}
+		return resp.StatusCode
+	}
+	type entry struct {
+		ID    int64
+		Label string
+		Value string
+	}
+	type submission struct {
+		ID      int64
+		Form    string
+		Entries []entry
+	}
+	values := func(s submission) string {
+		txts := []string{}
+		for _, entry := range s.Entries {
+			txts = append(txts, entry.Label+"="+entry.Value)
+		}
+		return strings.Join(txts, ",")
+	}
+	for _, tt := range []struct {
+		method, path, token, body string
+		want                      int
+	}{
+		{"GET", "/forms", "", "", http.StatusUnauthorized},
+		{"GET", "/forms", "wrong", "", http.StatusUnauthorized},
+		{"GET", "/openapi.json", "", "", http.StatusOK},
+		{"POST", "/forms", "secret", `{"name": "standup", "usage": "daily standup"}`, http.StatusCreated},
+		{"POST", "/forms", "secret", `{"name": "standup", "usage": "daily standup"}`, http.StatusBadRequest},
+		{"POST", "/forms", "secret", `{"nmae": "typo"}`, http.StatusBadRequest},
+		{"POST", "/forms", "secret", `{"name": "stand up", "usage": "daily standup"}`, http.StatusBadRequest},
+		{"POST", "/forms", "secret", `{"name": "short", "usage": "hi"}`, http.StatusBadRequest},
+		{"POST", "/forms/standup/labels", "secret", `{"name": "mood", "usage": "mood today", "type": "int", "max": 10}`, http.StatusCreated},
+		{"POST", "/forms/standup/labels", "secret", `{"name": "tags", "usage": "what about", "repeatable": true}`, http.StatusCreated},
+		{"POST", "/forms/standup/labels", "secret", `{"name": "late", "usage": "late today", "type": "colour"}`, http.StatusBadRequest},
+		{"POST", "/forms/standup/labels", "secret", `{"name": "json", "usage": "a label named json"}`, http.StatusBadRequest},
+		{"PATCH", "/forms/standup/labels/tags", "secret", `{"name": "json"}`, http.StatusBadRequest},
+		{"PATCH", "/forms/standup/labels/tags", "secret", `{"name": "editor"}`, http.StatusBadRequest},
+		{"PATCH", "/forms/standup/labels/tags", "secret", `{"position": 1}`, http.StatusOK},
+		{"PATCH", "/forms/standup", "secret", `{"name": "standup2"}`, http.StatusBadRequest},
+		{"PATCH", "/forms/standup", "secret", `{"usage": "the daily standup"}`, http.StatusOK},
+		{"GET", "/forms/missing", "secret", "", http.StatusNotFound},
+		{"DELETE", "/forms/standup/labels/missing", "secret", "", http.StatusNotFound},
+		{"PUT", "/forms/standup", "secret", "", http.StatusMethodNotAllowed},
+		{"GET", "/nowhere", "secret", "", http.StatusNotFound},
+		{"POST", "/forms/standup/submissions", "secret", `{"mood": 11}`, http.StatusBadRequest},
+		{"GET", "/submissions/42", "secret", "", http.StatusNotFound},
+		{"DELETE", "/entries/42", "secret", "", http.StatusNotFound},
+	} {
+		if got := request(tt.method, tt.path, tt.token, tt.body, nil); got != tt.want {
+			t.Errorf("%s %s = %v, want %v", tt.method, tt.path, got, tt.want)
+		}
+	}
+	// what a page of another site can make a browser send
+	for _, tt := range []struct {
+		name   string
+		header map[string]string
+		basic  bool
+		want   int
+	}{
+		{"text/plain", map[string]string{"Content-Type": "text/plain"}, false, http.StatusUnsupportedMediaType},
+		{"a form", map[string]string{"Content-Type": "application/x-www-form-urlencoded"}, false, http.StatusUnsupportedMediaType},
+		{"another origin", map[string]string{"Content-Type": "application/json", "Origin": "https://evil.example"}, false, http.StatusForbidden},
+		{"basic auth", map[string]string{"Content-Type": "application/json"}, true, http.StatusUnauthorized},
+	} {
+		req, err := http.NewRequest("POST", srv.URL+"/forms", strings.NewReader(`{"name": "csrf", "usage": "made elsewhere"}`))
+		must(t, err)
+		if tt.basic {
+			req.SetBasicAuth("anyone", "secret")
+		} else {
+			req.Header.Set("Authorization", "Bearer secret")
+		}
+		for key, value := range tt.header {
+			req.Header.Set(key, value)
+		}
+		resp, err := srv.Client().Do(req)
+		must(t, err)
+		resp.Body.Close()
+		if resp.StatusCode != tt.want {
+			t.Errorf("POST /forms with %s = %v, want %v", tt.name, resp.StatusCode, tt.want)
+		}
+	}
+	if _, err := env.FormModel.GetByName("csrf"); err != sql.ErrNoRows {
+		t.Errorf("GetByName of a form created from another site = %v", err)
+	}
+	req, err := http.NewRequest("DELETE", srv.URL+"/forms/standup", nil)
+	must(t, err)
+	req.Header.Set("Authorization", "Bearer secret")
+	req.Header.Set("Sec-Fetch-Site", "cross-site")
+	resp, err := srv.Client().Do(req)
+	must(t, err)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("DELETE /forms/standup from another site = %v, want %v", resp.StatusCode, http.StatusForbidden)
+	}
+	form, err := env.FormModel.GetByName("standup")
+	must(t, err)
+	if form.Usage != "the daily standup" {
+		t.Errorf("usage after PATCH = %q", form.Usage)
+	}
+	if names := labelNames(t, env, form.ID); names != "tags,mood" {
+		t.Errorf("labels = %s, want tags,mood", names)
+	}
+
+	first := submission{}
+	if code := request("POST", "/forms/standup/submissions", "secret", `{"mood": 7, "tags": ["gym", "work"]}`, &first); code != http.StatusCreated {
+		t.Fatalf("POST submissions = %v", code)
+	}
+	if got := values(first); first.Form != "standup" || got != "tags=gym,tags=work,mood=7" {
+		t.Errorf("submitted %s %s", first.Form, got)
+	}
+	second := submission{}
+	request("POST", "/forms/standup/submissions", "secret", `{"mood": 3}`, &second)
+	list := struct{ Submissions []submission }{}
+	request("GET", "/forms/standup/submissions?where=mood+%3E%3D+5", "secret", "", &list)
+	if len(list.Submissions) != 1 || list.Submissions[0].ID != first.ID || values(list.Submissions[0]) != values(first) {
+		t.Errorf("GET submissions where mood >= 5 = %+v", list.Submissions)
+	}
+	if code := request("GET", "/forms/standup/submissions?where=mood+%3E%3D", "secret", "", nil); code != http.StatusBadRequest {
+		t.Errorf("GET submissions with a broken condition = %v", code)
+	}
+
+	edited := submission{}
+	request("PATCH", fmt.Sprintf("/submissions/%v", first.ID), "secret", `{"tags": "rest"}`, &edited)
+	if got := values(edited); got != "tags=rest,mood=7" {
+		t.Errorf("edited submission = %s", got)
+	}
+	// submissions are parsed from the body as they are, so they are only
+	// read as json as well
+	for _, path := range []string{"/forms/standup/submissions", fmt.Sprintf("/submissions/%v", first.ID)} {
+		method := "POST"
+		if strings.HasPrefix(path, "/submissions/") {
+			method = "PATCH"
+		}
+		req, err := http.NewRequest(method, srv.URL+path, strings.NewReader(`{"mood": 1}`))
+		must(t, err)
+		req.Header.Set("Authorization", "Bearer secret")
+		req.Header.Set("Content-Type", "text/plain")
+		resp, err := srv.Client().Do(req)
+		must(t, err)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusUnsupportedMediaType {
+			t.Errorf("%s %s with text/plain = %v, want %v", method, path, resp.StatusCode, http.StatusUnsupportedMediaType)
+		}
+	}
+	changes := struct {
+		Changes []struct {
+			Label    string
+			From, To []string
+		}
+	}{}
+	request("GET", fmt.Sprintf("/submissions/%v/changes", first.ID), "secret", "", &changes)
+	if len(changes.Changes) != 1 || changes.Changes[0].Label != "tags" ||
+		strings.Join(changes.Changes[0].From, ",") != "gym,work" || strings.Join(changes.Changes[0].To, ",") != "rest" {
+		t.Errorf("changes = %+v", changes.Changes)
+	}
+	changed := struct{ Submission submission }{}
+	mood := edited.Entries[1]
+	if code := request("PATCH", fmt.Sprintf("/entries/%v", mood.ID), "secret", `{"value": "eight"}`, nil); code != http.StatusBadRequest {
+		t.Errorf("PATCH entry with a word for an int = %v", code)
+	}
+	request("PATCH", fmt.Sprintf("/entries/%v", mood.ID), "secret", `{"value": "8"}`, &changed)
+	if got := values(changed.Submission); got != "tags=rest,mood=8" {
+		t.Errorf("submission after PATCH entry = %s", got)
+	}
+	request("DELETE", fmt.Sprintf("/entries/%v", mood.ID), "secret", "", &changed)
+	if got := values(changed.Submission); got != "tags=rest" {
+		t.Errorf("submission after DELETE entry = %s", got)
+	}
+	if code := request("DELETE", fmt.Sprintf("/submissions/%v", second.ID), "secret", "", nil); code != http.StatusOK {
+		t.Errorf("DELETE submission = %v", code)
+	}
+	if _, err := env.SubmissionModel.GetByID(second.ID); err != sql.ErrNoRows {
+		t.Errorf("GetByID of a submission deleted over http = %v", err)
+	}
+	if code := request("DELETE", "/forms/standup", "secret", "", nil); code != http.StatusOK {
+		t.Errorf("DELETE form = %v", code)
+	}
+	forms := struct{ Forms []struct{ Name string } }{}
+	request("GET", "/forms", "secret", "", &forms)
+	if len(forms.Forms) != 0 {
+		t.Errorf("forms after DELETE = %+v", forms.Forms)
+	}
+}
+
+func TestPages(t *testing.T) {
+	env := formly.NewMemoryEnv()
+	defer env.Close()
+	srv := httptest.NewServer(server.NewHandler(env, "secret"))
+	defer srv.Close()
+	client := srv.Client()
+	// pages are not followed to the page a post redirects to
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error { return http.ErrUseLastResponse }
+	request := func(method, path, origin string, form url.Values) (int, string) {
+		t.Helper()
+		req, err := http.NewRequest(method, srv.URL+path, strings.NewReader(form.Encode()))
+		must(t, err)
+		req.SetBasicAuth("anyone", "secret")
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		resp, err := client.Do(req)
+		must(t, err)
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		must(t, err)
+		return resp.StatusCode, string(body)
+	}
+	standup := createForm(t, env, "standup")
+	mood := createLabel(t, env, formly.Label{FormID: standup.ID, Position: 2, Name: "mood", Type: formly.IntLabel, Required: true})
+	tags := createLabel(t, env, formly.Label{FormID: standup.ID, Position: 1, Name: "tags", Repeatable: true, Usage: "what it was about"})
+	late := createLabel(t, env, formly.Label{
+		FormID: standup.ID, Position: 3, Name: "late", Type: formly.EnumLabel, Choices: []string{"yes", "no"},
+	})
+
+	code, body := request("GET", "/ui", "", nil)
+	if code != http.StatusOK || !strings.Contains(body, `href="/ui/standup"`) {
+		t.Errorf("GET /ui = %v %s", code, body)
+	}
+	code, body = request("GET", "/ui/standup", "", nil)
+	if code != http.StatusOK {
+		t.Fatalf("GET /ui/standup = %v %s", code, body)
+	}
+	// labels come in position order, with their usage as help
+	last := -1
+	for _, want := range []string{
+		"what it was about", `name="tags"`, "add another", `name="mood" type="number"`, `<select id="late" name="late">`,
+	} {
+		i := strings.Index(body, want)
+		if i <= last {
+			t.Errorf("GET /ui/standup does not hold %q in order:\n%s", want, body)
+		}
+		last = i
+	}
+
+	code, body = request("POST", "/ui/standup", "", url.Values{"tags": {"gym", "", "work"}, "mood": {"seven"}})
+	if code != http.StatusBadRequest || !strings.Contains(body, "expects an integer") || !strings.Contains(body, `value="seven"`) {
+		t.Errorf("POST of an invalid submission = %v %s", code, body)
+	}
+	if code, _ := request("POST", "/ui/standup", "http://elsewhere.example", url.Values{"mood": {"7"}}); code != http.StatusForbidden {
+		t.Errorf("POST from another site = %v, want %v", code, http.StatusForbidden)
+	}
+	submissions, err := env.SubmissionModel.GetSubmissions(standup.ID)
+	must(t, err)
+	if len(submissions) != 0 {
+		t.Fatalf("refused posts stored %v submissions", len(submissions))
+	}
+	code, _ = request("POST", "/ui/standup", srv.URL, url.Values{"tags": {"gym", "", "work"}, "mood": {"7"}, "late": {""}})
+	if code != http.StatusSeeOther {
+		t.Fatalf("POST /ui/standup = %v", code)
+	}
+	submissions, err = env.SubmissionModel.GetSubmissions(standup.ID)
+	must(t, err)
+	if len(submissions) != 1 {
+		t.Fatalf("stored %v submissions, want 1", len(submissions))
+	}
+	submission, err := env.SubmissionModel.GetByID(submissions[0].ID)
+	must(t, err)
+	got := map[int64][]string{}
+	for _, entry := range submission.Entries {
+		got[entry.LabelID] = append(got[entry.LabelID], entry.Txt)
+	}
+	if strings.Join(got[tags.ID], ",") != "gym,work" || strings.Join(got[mood.ID], ",") != "7" || len(got[late.ID]) != 0 {
+		t.Errorf("stored %v", got)
+	}
+}
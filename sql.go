@@ -2,9 +2,15 @@ package formly
 
 import (
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
 )
 
 type sqlModels struct {
@@ -14,67 +20,88 @@ type sqlModels struct {
 	sqlEntryModel
 }
 
-// NewLocalSqLiteEnv ...
+// NewLocalSqLiteEnv opens the database of the default workspace.
 func NewLocalSqLiteEnv() (*Env, error) {
-	schema := `
-		PRAGMA foreign_keys = ON;
-		CREATE TABLE IF NOT EXISTS forms (
-			form_id INTEGER PRIMARY KEY AUTOINCREMENT,
-			editable BOOL DEFAULT TRUE,
-			deleteable BOOL DEFAULT TRUE,
-			name TEXT UNIQUE NOT NULL CHECK(length(name) >= 1 AND length(name) <= 16),
-			usage TEXT NOT NULL CHECK(length(usage) >= 5 AND length(usage) <= 252)
-		);
-
-		CREATE TABLE IF NOT EXISTS labels (
-			label_id INTEGER PRIMARY KEY AUTOINCREMENT,
-			form_id INTEGER NOT NULL,
-			position INTEGER NOT NULL CHECK(position >= 1),
-			repeatable BOOL DEFAULT FALSE,
-			editable BOOL DEFAULT TRUE,
-			deleteable BOOL DEFAULT TRUE,
-			name TEXT NOT NULL CHECK(length(name) >= 1 AND length(name) <= 16),
-			usage TEXT NOT NULL CHECK(length(usage) >= 5 AND length(usage) <= 252),
-			FOREIGN KEY (form_id) REFERENCES forms (form_id) ON UPDATE CASCADE ON DELETE CASCADE
-		);
-
-		CREATE TABLE IF NOT EXISTS submissions (
-			submission_id INTEGER PRIMARY KEY AUTOINCREMENT,
-			form_id INTEGER NOT NULL,
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			FOREIGN KEY (form_id) REFERENCES forms (form_id) ON UPDATE CASCADE ON DELETE CASCADE
-		);
-
-		CREATE TABLE IF NOT EXISTS entries (
-			entry_id INTEGER PRIMARY KEY AUTOINCREMENT,
-			submission_id INTEGER NOT NULL,
-			label_id INTEGER NOT NULL,
-			txt TEXT,
-			FOREIGN KEY (label_id) REFERENCES labels (label_id) ON UPDATE CASCADE ON DELETE CASCADE,
-			FOREIGN KEY (submission_id) REFERENCES submissions (submission_id) ON UPDATE CASCADE ON DELETE CASCADE
-		);
-		`
+	path, err := WorkspacePath("")
+	if err != nil {
+		return nil, err
+	}
+	return NewSqLiteEnv(path)
+}
+
+// DataDir returns the directory formly keeps its databases in,
+// $XDG_DATA_HOME/formly or ~/.local/share/formly when that is not set.
+func DataDir() (string, error) {
+	if dataHome := os.Getenv("XDG_DATA_HOME"); dataHome != "" && filepath.IsAbs(dataHome) {
+		return filepath.Join(dataHome, "formly"), nil
+	}
 	homePath, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(homePath, ".local", "share", "formly"), nil
+}
+
+// ErrInvalidWorkspace ...
+var ErrInvalidWorkspace error = errors.New("workspace name is not composed only of letters, digits, '-' and '_'")
+
+// WorkspacePath returns the database file of the named workspace, the
+// empty name being the default workspace.
+func WorkspacePath(workspace string) (string, error) {
+	dataPath, err := DataDir()
+	if err != nil {
+		return "", err
+	}
+	if workspace == "" {
+		return filepath.Join(dataPath, "data.db"), nil
+	}
+	if !regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`).MatchString(workspace) {
+		return "", ErrInvalidWorkspace
+	}
+	return filepath.Join(dataPath, "workspaces", workspace+".db"), nil
+}
+
+// Workspaces lists the named workspaces that have a database.
+func Workspaces() ([]string, error) {
+	dataPath, err := DataDir()
 	if err != nil {
 		return nil, err
 	}
-	dataPath := filepath.Join(homePath, ".local", "share", "formly")
-	if err := os.MkdirAll(dataPath, os.ModePerm); err != nil {
+	paths, err := filepath.Glob(filepath.Join(dataPath, "workspaces", "*.db"))
+	if err != nil {
 		return nil, err
 	}
-	dbPath := filepath.Join(dataPath, "data.db")
-	db, err := sql.Open("sqlite3", dbPath)
+	workspaces := []string{}
+	for _, path := range paths {
+		workspaces = append(workspaces, strings.TrimSuffix(filepath.Base(path), ".db"))
+	}
+	return workspaces, nil
+}
+
+// NewSqLiteEnv opens, and creates if needed, the sqlite database at path.
+// The schema is upgraded to the latest version on open, databases created
+// by a newer version of formly are refused with ErrSchemaTooNew.
+func NewSqLiteEnv(path string) (*Env, error) {
+	db, err := openSqLite(path)
 	if err != nil {
 		return nil, err
 	}
-	if _, err := db.Exec(schema); err != nil {
+	if _, err := migrate(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+	fts5, err := indexEntries(db)
+	if err != nil {
+		db.Close()
 		return nil, err
 	}
 	return &Env{
 		FormModel:       sqlFormModel{db: db},
 		LabelModel:      sqlLabelModel{db: db},
 		SubmissionModel: sqlSubmissionModel{db: db},
-		EntryModel:      sqlEntryModel{db: db},
+		EntryModel:      sqlEntryModel{db: db, fts5: fts5},
+		HookModel:       sqlHookModel{db: db},
+		WebhookModel:    sqlWebhookModel{db: db},
 		close: func() error {
 			return db.Close()
 		},
@@ -94,6 +121,9 @@ func (model sqlFormModel) Create(name, usage string) (Form, error) {
 	).Scan(&form.ID); err != nil {
 		return Form{}, err
 	}
+	if _, err := model.db.Exec("INSERT INTO form_versions (form_id, version) VALUES (?, 1)", form.ID); err != nil {
+		return Form{}, err
+	}
 	return form, nil
 }
 func (model sqlFormModel) GetByName(name string) (Form, error) {
@@ -169,56 +199,374 @@ func (model sqlFormModel) Update(formID int64, name, usage string) (Form, error)
 	return Form{ID: formID, Name: name, Usage: usage}, nil
 }
 
+func (model sqlFormModel) Save(form Form, labels []Label) (Form, []Label, error) {
+	tx, err := model.db.Begin()
+	if err != nil {
+		return Form{}, nil, err
+	}
+	defer tx.Rollback()
+	changed := form.ID == 0
+	if form.ID == 0 {
+		if err := tx.QueryRow(
+			"INSERT INTO forms (name, usage) VALUES (?, ?) RETURNING form_id",
+			form.Name,
+			form.Usage,
+		).Scan(&form.ID); err != nil {
+			return Form{}, nil, err
+		}
+	} else {
+		result, err := tx.Exec("UPDATE forms SET name = ?, usage = ? WHERE form_id = ?", form.Name, form.Usage, form.ID)
+		if err != nil {
+			return Form{}, nil, err
+		}
+		if n, err := result.RowsAffected(); err != nil || n == 0 {
+			return Form{}, nil, fmt.Errorf("form with form_id:%v does not exists", form.ID)
+		}
+	}
+	stored, err := queryLabels(tx, form.ID)
+	if err != nil {
+		return Form{}, nil, err
+	}
+	if labels, err = orderLabels(form.ID, stored, labels); err != nil {
+		return Form{}, nil, err
+	}
+	kept := map[int64]bool{}
+	for _, label := range labels {
+		kept[label.ID] = true
+	}
+	byID := map[int64]Label{}
+	for _, label := range stored {
+		byID[label.ID] = label
+		if kept[label.ID] {
+			continue
+		}
+		if _, err := tx.Exec("UPDATE labels SET deleted = TRUE WHERE label_id = ?", label.ID); err != nil {
+			return Form{}, nil, err
+		}
+		changed = true
+	}
+	for _, label := range labels {
+		if label.ID == 0 {
+			if _, err := insertLabel(tx, label); err != nil {
+				return Form{}, nil, fmt.Errorf("label '%s': %v", label.Name, err)
+			}
+			changed = true
+			continue
+		}
+		valid, err := validateLabel(label)
+		if err != nil {
+			return Form{}, nil, fmt.Errorf("label '%s': %v", label.Name, err)
+		}
+		if sameLabel(byID[label.ID], valid) {
+			continue
+		}
+		if err := writeLabel(tx, byID[label.ID], valid); err != nil {
+			return Form{}, nil, fmt.Errorf("label '%s': %v", label.Name, err)
+		}
+		changed = true
+	}
+	if changed {
+		if err := newVersion(tx, form.ID); err != nil {
+			return Form{}, nil, err
+		}
+	}
+	if labels, err = queryLabels(tx, form.ID); err != nil {
+		return Form{}, nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return Form{}, nil, err
+	}
+	return form, labels, nil
+}
+
+func (model sqlFormModel) GetVersions(formID int64) ([]FormVersion, error) {
+	if _, err := model.GetByID(formID); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("form with form_id:%v does not exists", formID)
+		}
+		return nil, err
+	}
+	versions := []FormVersion{}
+	rows, err := model.db.Query(
+		"SELECT form_id, version, created_at FROM form_versions WHERE form_id = ? ORDER BY version ASC",
+		formID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		version := FormVersion{}
+		if err := rows.Scan(&version.FormID, &version.Version, &version.CreatedAt); err != nil {
+			return nil, err
+		}
+		versions = append(versions, version)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	for i := range versions {
+		if versions[i].Labels, err = model.versionLabels(formID, versions[i].Version); err != nil {
+			return nil, err
+		}
+	}
+	return versions, nil
+}
+func (model sqlFormModel) GetVersion(formID, version int64) (FormVersion, error) {
+	formVersion := FormVersion{}
+	if err := model.db.QueryRow(
+		"SELECT form_id, version, created_at FROM form_versions WHERE form_id = ? AND version = ?",
+		formID,
+		version,
+	).Scan(&formVersion.FormID, &formVersion.Version, &formVersion.CreatedAt); err != nil {
+		return FormVersion{}, err
+	}
+	var err error
+	formVersion.Labels, err = model.versionLabels(formID, version)
+	return formVersion, err
+}
+func (model sqlFormModel) versionLabels(formID, version int64) ([]Label, error) {
+	return queryVersionLabels(model.db, formID, version)
+}
+func queryVersionLabels(q querier, formID, version int64) ([]Label, error) {
+	labels := []Label{}
+	rows, err := q.Query(`
+		SELECT label_id, form_id, position, repeatable, required, default_txt, type, choices, name, usage,
+			pattern, min_length, max_length, min, max
+		FROM label_versions WHERE form_id = ? AND version = ? ORDER BY position ASC`,
+		formID,
+		version,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		label, err := scanLabel(rows)
+		if err != nil {
+			return nil, err
+		}
+		labels = append(labels, label)
+	}
+	return labels, rows.Err()
+}
+
 type sqlLabelModel struct {
 	db *sql.DB
 }
 
-func (model sqlLabelModel) Create(formID, position int64, repeatable bool, name, usage string) (Label, error) {
-	formModel := sqlFormModel{db: model.db}
-	if _, err := formModel.GetByID(formID); err != nil {
+func (model sqlLabelModel) Create(label Label) (Label, error) {
+	err := inTx(model.db, func(tx *sql.Tx) error {
+		var formID int64
+		if err := tx.QueryRow("SELECT form_id FROM forms WHERE form_id = ?", label.FormID).Scan(&formID); err != nil {
+			return err
+		}
+		var err error
+		if label, err = insertLabel(tx, label); err != nil {
+			return err
+		}
+		return newVersion(tx, label.FormID)
+	})
+	if err != nil {
 		return Label{}, err
 	}
-	label := Label{FormID: formID, Position: position, Name: name, Usage: usage}
-	if err := model.db.QueryRow(
-		"INSERT INTO labels (form_id, position, repeatable, Name, Usage) VALUES (?, ?, ?, ?, ?) RETURNING label_id",
-		formID,
-		position,
-		repeatable,
-		name,
-		usage,
+	return label, nil
+}
+
+// inTx runs fn in a transaction, committed when fn succeeds and rolled back
+// otherwise.
+func inTx(db *sql.DB, fn func(tx *sql.Tx) error) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// validateLabel checks the type, constraints and default of a label about
+// to be stored and returns it with its default in canonical form.
+func validateLabel(label Label) (Label, error) {
+	if label.Type == "" {
+		label.Type = TextLabel
+	}
+	if err := ValidateType(label.Type, label.Choices); err != nil {
+		return Label{}, err
+	}
+	if err := ValidateConstraints(label.Type, label.Constraints); err != nil {
+		return Label{}, err
+	}
+	def, err := ValidateDefault(label)
+	if err != nil {
+		return Label{}, err
+	}
+	label.Default = def
+	return label, nil
+}
+func insertLabel(db execer, label Label) (Label, error) {
+	label, err := validateLabel(label)
+	if err != nil {
+		return Label{}, err
+	}
+	if err := db.QueryRow(
+		"INSERT INTO labels (form_id, position, repeatable, required, default_txt, type, choices, name, usage) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?) RETURNING label_id",
+		label.FormID,
+		label.Position,
+		label.Repeatable,
+		label.Required,
+		label.Default,
+		label.Type,
+		joinChoices(label.Choices),
+		label.Name,
+		label.Usage,
 	).Scan(&label.ID); err != nil {
 		return Label{}, err
 	}
-	return Label{FormID: formID, Position: position, Repeatable: repeatable, Name: name, Usage: usage}, nil
+	if err := saveConstraints(db, label.ID, label.Constraints); err != nil {
+		return Label{}, err
+	}
+	return label, nil
 }
-func (model sqlLabelModel) GetByID(id int64) (Label, error) {
+
+// writeLabel stores label over stored, the same label as it is now. Its
+// entries are left as they are, they belong to earlier versions of the form
+// which keep the label's type and choices as they were.
+func writeLabel(db execer, stored, label Label) error {
+	if _, err := db.Exec(
+		"UPDATE labels SET name = ?, usage = ?, repeatable = ?, required = ?, default_txt = ?, type = ?, choices = ?, position = ? WHERE label_id = ? ",
+		label.Name,
+		label.Usage,
+		label.Repeatable,
+		label.Required,
+		label.Default,
+		label.Type,
+		joinChoices(label.Choices),
+		label.Position,
+		label.ID,
+	); err != nil {
+		return err
+	}
+	return saveConstraints(db, label.ID, label.Constraints)
+}
+
+// newVersion records the current labels of the form as its next version.
+func newVersion(db execer, formID int64) error {
+	var version int64
+	if err := db.QueryRow(
+		"INSERT INTO form_versions (form_id, version) SELECT ?, IFNULL(MAX(version), 0) + 1 FROM form_versions WHERE form_id = ? RETURNING version",
+		formID,
+		formID,
+	).Scan(&version); err != nil {
+		return err
+	}
+	_, err := db.Exec(`
+		INSERT INTO label_versions
+			SELECT labels.form_id, ?, labels.label_id, labels.position, labels.repeatable, labels.required,
+				labels.default_txt, labels.type, labels.choices, labels.name, labels.usage,
+				IFNULL(label_constraints.pattern, ''), IFNULL(label_constraints.min_length, 0),
+				IFNULL(label_constraints.max_length, 0), label_constraints.min, label_constraints.max
+			FROM labels LEFT JOIN label_constraints ON labels.label_id = label_constraints.label_id
+			WHERE labels.form_id = ? AND NOT labels.deleted`,
+		version,
+		formID,
+	)
+	return err
+}
+
+const labelColumns = `
+	labels.label_id, labels.form_id, labels.position, labels.repeatable, labels.required, labels.default_txt,
+	labels.type, labels.choices, labels.name, labels.usage,
+	IFNULL(label_constraints.pattern, ''), IFNULL(label_constraints.min_length, 0),
+	IFNULL(label_constraints.max_length, 0), label_constraints.min, label_constraints.max
+	FROM labels LEFT JOIN label_constraints ON labels.label_id = label_constraints.label_id`
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanLabel(row scanner) (Label, error) {
 	label := Label{}
-	if err := model.db.QueryRow(
-		"SELECT label_id, form_id, name, usage, position, repeatable FROM labels WHERE label_id = ?",
-		id,
-	).Scan(&label.ID, &label.FormID, &label.Name, &label.Usage, &label.Position, &label.Repeatable); err != nil {
+	var choices string
+	var min, max sql.NullFloat64
+	if err := row.Scan(
+		&label.ID, &label.FormID, &label.Position, &label.Repeatable, &label.Required, &label.Default,
+		&label.Type, &choices, &label.Name, &label.Usage,
+		&label.Constraints.Pattern, &label.Constraints.MinLength, &label.Constraints.MaxLength, &min, &max,
+	); err != nil {
 		return Label{}, err
 	}
+	label.Choices = splitChoices(choices)
+	if min.Valid {
+		label.Constraints.Min = &min.Float64
+	}
+	if max.Valid {
+		label.Constraints.Max = &max.Float64
+	}
 	return label, nil
 }
+func saveConstraints(db execer, labelID int64, constraints Constraints) error {
+	if constraints == (Constraints{}) {
+		_, err := db.Exec("DELETE FROM label_constraints WHERE label_id = ?", labelID)
+		return err
+	}
+	_, err := db.Exec(
+		"INSERT OR REPLACE INTO label_constraints (label_id, pattern, min_length, max_length, min, max) VALUES (?, ?, ?, ?, ?, ?)",
+		labelID,
+		constraints.Pattern,
+		constraints.MinLength,
+		constraints.MaxLength,
+		constraints.Min,
+		constraints.Max,
+	)
+	return err
+}
+func (model sqlLabelModel) GetByID(id int64) (Label, error) {
+	return scanLabel(model.db.QueryRow("SELECT "+labelColumns+" WHERE labels.label_id = ? AND NOT labels.deleted", id))
+}
 func (model sqlLabelModel) GetLabels(formID int64) ([]Label, error) {
-	formModel := sqlFormModel{db: model.db}
-	if _, err := formModel.GetByID(formID); err != nil {
-		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("GetLabels: form with form_id: %v does not exists", formID)
-		}
+	return formLabels(model.db, formID)
+}
+
+// formLabels reads the active labels of a form, failing when there is no
+// such form.
+func formLabels(q querier, formID int64) ([]Label, error) {
+	var exists int
+	if err := q.QueryRow("SELECT COUNT(*) FROM forms WHERE form_id = ?", formID).Scan(&exists); err != nil {
 		return nil, err
 	}
+	if exists == 0 {
+		return nil, fmt.Errorf("GetLabels: form with form_id: %v does not exists", formID)
+	}
+	return queryLabels(q, formID)
+}
+
+// querier is either the database or a transaction.
+type querier interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// execer is either the database or a transaction, for writes.
+type execer interface {
+	querier
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// queryLabels reads the active labels of a form.
+func queryLabels(q querier, formID int64) ([]Label, error) {
 	labels := []Label{}
-	rows, err := model.db.Query(
-		"SELECT label_id, form_id, position, repeatable, name, usage FROM labels WHERE form_id = ? ORDER BY position ASC", formID)
+	rows, err := q.Query(
+		"SELECT "+labelColumns+" WHERE labels.form_id = ? AND NOT labels.deleted ORDER BY labels.position ASC", formID)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 	for rows.Next() {
-		label := Label{}
-		if err := rows.Scan(&label.ID, &label.FormID, &label.Position, &label.Repeatable, &label.Name, &label.Usage); err != nil {
+		label, err := scanLabel(rows)
+		if err != nil {
 			return nil, err
 		}
 		labels = append(labels, label)
@@ -228,80 +576,86 @@ func (model sqlLabelModel) GetLabels(formID int64) ([]Label, error) {
 	}
 	return labels, nil
 }
-func (model sqlLabelModel) Update(formID, labelID, position int64, repeatable bool, name, usage string) ([]Label, error) {
-	labels, err := model.GetLabels(formID)
+func (model sqlLabelModel) Update(label Label) ([]Label, error) {
+	var labels []Label
+	err := inTx(model.db, func(tx *sql.Tx) error {
+		var err error
+		if labels, err = update(tx, label); err != nil {
+			return err
+		}
+		return newVersion(tx, label.FormID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return labels, nil
+}
+func update(tx *sql.Tx, label Label) ([]Label, error) {
+	labels, err := formLabels(tx, label.FormID)
 	if err != nil {
 		return nil, err
 	}
-	if int(position) > len(labels) || int(position) < 1 {
+	if int(label.Position) > len(labels) || int(label.Position) < 1 {
 		return nil, fmt.Errorf("position has to be in range between: %v - %v", 1, len(labels))
 	}
+	if label, err = validateLabel(label); err != nil {
+		return nil, err
+	}
 	// check that only name matches with the label with the same labelID
 	var updatingLabel Label
 	var swapLabel Label
-	for _, label := range labels {
-		if label.ID == labelID {
-			updatingLabel = label
+	for _, l := range labels {
+		if l.ID == label.ID {
+			updatingLabel = l
 		}
-		if label.ID != labelID && label.Name == name {
-			return nil, fmt.Errorf("suggested new label name '%s' already exists", name)
+		if l.ID != label.ID && l.Name == label.Name {
+			return nil, fmt.Errorf("suggested new label name '%s' already exists", label.Name)
 		}
-		if label.Position == position {
-			swapLabel = label
+		if l.Position == label.Position {
+			swapLabel = l
 		}
 	}
-	if _, err := model.db.Exec(
-		"UPDATE labels SET name = ?, usage = ?, repeatable = ?, position = ? WHERE label_id = ? ",
-		name,
-		usage,
-		repeatable,
-		position,
-		labelID,
-	); err != nil {
+	if err := writeLabel(tx, updatingLabel, label); err != nil {
 		return nil, err
 	}
 	swapLabel.Position = updatingLabel.Position
-	updatingLabel.Name = name
-	updatingLabel.Usage = usage
-	updatingLabel.Repeatable = repeatable
-	if swapLabel.ID == updatingLabel.ID || swapLabel == (Label{}) {
-		updatingLabel.Position = position
-		return []Label{updatingLabel}, nil
+	if swapLabel.ID == label.ID || swapLabel.ID == 0 {
+		return []Label{label}, nil
 	}
-	if _, err := model.db.Exec(
+	if _, err := tx.Exec(
 		"UPDATE labels SET position = ? WHERE label_id = ? ",
 		swapLabel.Position,
 		swapLabel.ID,
 	); err != nil {
 		return nil, err
 	}
-
-	updatingLabel.Position = position
-	return []Label{updatingLabel, swapLabel}, nil
+	return []Label{label, swapLabel}, nil
 }
+
 func (model sqlLabelModel) DeleteByID(id int64) (Label, error) {
-	label, err := model.GetByID(id)
-	if err != nil {
-		return Label{}, err
-	}
-	labels, err := model.GetLabels(label.FormID)
-	if err != nil {
-		return Label{}, err
-	}
-	if _, err := model.db.Exec("DELETE FROM labels WHERE label_id = ?", id); err != nil {
-		return Label{}, err
-	}
-	changePos := 0
-	for i, l := range labels {
-		if l.ID == id {
-			changePos = i
-		}
-	}
-	for _, l := range labels[changePos+1:] {
-		_, err := model.Update(l.FormID, l.ID, l.Position-1, l.Repeatable, l.Name, l.Usage)
+	var label Label
+	err := inTx(model.db, func(tx *sql.Tx) error {
+		var err error
+		label, err = scanLabel(tx.QueryRow("SELECT "+labelColumns+" WHERE labels.label_id = ? AND NOT labels.deleted", id))
 		if err != nil {
-			return Label{}, err
+			return err
+		}
+		// the label is only marked as deleted so that submissions of earlier
+		// versions of the form keep their entries
+		if _, err := tx.Exec("UPDATE labels SET deleted = TRUE WHERE label_id = ?", id); err != nil {
+			return err
 		}
+		if _, err := tx.Exec(
+			"UPDATE labels SET position = position - 1 WHERE form_id = ? AND NOT deleted AND position > ?",
+			label.FormID,
+			label.Position,
+		); err != nil {
+			return err
+		}
+		return newVersion(tx, label.FormID)
+	})
+	if err != nil {
+		return Label{}, err
 	}
 	return label, nil
 }
@@ -320,13 +674,138 @@ func (model sqlSubmissionModel) Create(formID int64) (Submission, error) {
 	}
 	submission := Submission{FormID: formID}
 	if err := model.db.QueryRow(
-		"INSERT INTO submissions (form_id) VALUES (?) RETURNING submission_id",
+		"INSERT INTO submissions (form_id, form_version) SELECT ?, MAX(version) FROM form_versions WHERE form_id = ? RETURNING submission_id",
+		formID,
+		formID,
+	).Scan(&submission.ID); err != nil {
+		return Submission{}, err
+	}
+	// created_at comes back as text through RETURNING, the column type is
+	// only known to the driver when selecting it
+	if err := model.db.QueryRow(
+		"SELECT form_version, created_at FROM submissions WHERE submission_id = ?",
+		submission.ID,
+	).Scan(&submission.Version, &submission.CreateAt); err != nil {
+		return Submission{}, err
+	}
+	return submission, nil
+}
+func (model sqlSubmissionModel) Submit(formID int64, values map[int64][]string) (Submission, error) {
+	var submission Submission
+	err := model.inTx(formID, func(tx *sql.Tx, labels []Label) (err error) {
+		submission, err = submit(tx, formID, labels, NewSubmission{Values: values})
+		return err
+	})
+	return submission, err
+}
+func (model sqlSubmissionModel) SubmitAll(formID int64, newSubmissions []NewSubmission) ([]Submission, error) {
+	submissions := []Submission{}
+	err := model.inTx(formID, func(tx *sql.Tx, labels []Label) error {
+		for i, newSubmission := range newSubmissions {
+			submission, err := submit(tx, formID, labels, newSubmission)
+			if err != nil {
+				return fmt.Errorf("submission %v: %w", i+1, err)
+			}
+			submissions = append(submissions, submission)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return submissions, nil
+}
+
+// inTx runs fn in a transaction along with the form's labels. The labels
+// are read inside the transaction, which holds the write lock, so they can
+// not change between validating and storing.
+func (model sqlSubmissionModel) inTx(formID int64, fn func(tx *sql.Tx, labels []Label) error) error {
+	return inTx(model.db, func(tx *sql.Tx) error {
+		var exists int
+		if err := tx.QueryRow("SELECT COUNT(*) FROM forms WHERE form_id = ?", formID).Scan(&exists); err != nil {
+			return err
+		}
+		if exists == 0 {
+			return fmt.Errorf("form with form_id:%v does not exists", formID)
+		}
+		labels, err := queryLabels(tx, formID)
+		if err != nil {
+			return err
+		}
+		return fn(tx, labels)
+	})
+}
+
+func submit(tx *sql.Tx, formID int64, labels []Label, newSubmission NewSubmission) (Submission, error) {
+	canonical, err := ValidateSubmission(labels, newSubmission.Values)
+	if err != nil {
+		return Submission{}, err
+	}
+	// stored the way CURRENT_TIMESTAMP is, so both sort alike
+	var createdAt interface{}
+	if !newSubmission.CreateAt.IsZero() {
+		createdAt = newSubmission.CreateAt.UTC().Format("2006-01-02 15:04:05")
+	}
+	submission := Submission{FormID: formID, Entries: []Entry{}}
+	if err := tx.QueryRow(
+		`INSERT INTO submissions (form_id, form_version, created_at)
+			SELECT ?, MAX(version), IFNULL(?, CURRENT_TIMESTAMP) FROM form_versions WHERE form_id = ? RETURNING submission_id`,
+		formID,
+		createdAt,
 		formID,
 	).Scan(&submission.ID); err != nil {
 		return Submission{}, err
 	}
+	if err := tx.QueryRow(
+		"SELECT form_version, created_at FROM submissions WHERE submission_id = ?",
+		submission.ID,
+	).Scan(&submission.Version, &submission.CreateAt); err != nil {
+		return Submission{}, err
+	}
+	for _, label := range labels {
+		for _, txt := range canonical[label.ID] {
+			entry := Entry{LabelID: label.ID, SubmissionID: submission.ID, Txt: txt}
+			if err := tx.QueryRow(
+				"INSERT INTO entries (label_id, submission_id, txt) VALUES (?, ?, ?) RETURNING entry_id",
+				label.ID,
+				submission.ID,
+				txt,
+			).Scan(&entry.ID); err != nil {
+				return Submission{}, err
+			}
+			submission.Entries = append(submission.Entries, entry)
+		}
+	}
+	if newSubmission.SkipWebhooks {
+		return submission, nil
+	}
+	if err := enqueueSubmission(tx, submission, labels); err != nil {
+		return Submission{}, err
+	}
 	return submission, nil
 }
+
+// enqueueSubmission queues the submission for the webhooks of its form in
+// the transaction storing it, so a stored submission always reaches them.
+func enqueueSubmission(tx *sql.Tx, submission Submission, labels []Label) error {
+	var webhooks int
+	if err := tx.QueryRow("SELECT COUNT(*) FROM webhooks WHERE form_id = ?", submission.FormID).Scan(&webhooks); err != nil {
+		return err
+	}
+	if webhooks == 0 {
+		return nil
+	}
+	form := Form{ID: submission.FormID}
+	if err := tx.QueryRow("SELECT name FROM forms WHERE form_id = ?", form.ID).Scan(&form.Name); err != nil {
+		return err
+	}
+	payload, err := json.Marshal(newHookSubmission(form, submission, labels))
+	if err != nil {
+		return err
+	}
+	_, err = enqueue(tx, form.ID, submission.ID, string(payload), time.Now())
+	return err
+}
 func (model sqlSubmissionModel) GetSubmissions(formID int64) ([]Submission, error) {
 	formModel := sqlFormModel{db: model.db}
 	if _, err := formModel.GetByID(formID); err != nil {
@@ -337,7 +816,7 @@ func (model sqlSubmissionModel) GetSubmissions(formID int64) ([]Submission, erro
 	}
 	submissions := []Submission{}
 	rows, err := model.db.Query(
-		"SELECT submission_id, form_id, created_at FROM submissions WHERE form_id = ? ORDER BY created_at ASC",
+		"SELECT submission_id, form_id, form_version, created_at FROM submissions WHERE form_id = ? ORDER BY created_at ASC",
 		formID,
 	)
 	if err != nil {
@@ -346,7 +825,7 @@ func (model sqlSubmissionModel) GetSubmissions(formID int64) ([]Submission, erro
 	defer rows.Close()
 	for rows.Next() {
 		submission := Submission{}
-		err := rows.Scan(&submission.ID, &submission.FormID, &submission.CreateAt)
+		err := rows.Scan(&submission.ID, &submission.FormID, &submission.Version, &submission.CreateAt)
 		if err != nil {
 			return nil, err
 		}
@@ -358,15 +837,328 @@ func (model sqlSubmissionModel) GetSubmissions(formID int64) ([]Submission, erro
 	return submissions, err
 }
 
-type sqlEntryModel struct {
-	db *sql.DB
-}
-
-func (model sqlEntryModel) Create(submissionID, labelID int64, txt string) (Entry, error) {
-	entry := Entry{LabelID: labelID, SubmissionID: submissionID, Txt: txt}
-	if err := model.db.QueryRow(
-		"INSERT INTO entries (label_id, submission_id, txt) VALUES (?, ?, ?) RETURNING entry_id",
-		labelID,
+// Query compiles the query into a single select, with every value of the
+// condition passed as an argument.
+func (model sqlSubmissionModel) Query(query SubmissionQuery) ([]Submission, error) {
+	selected, args, err := model.selectSubmissions(query)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := model.db.Query(selected, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	submissions := []Submission{}
+	for rows.Next() {
+		submission := Submission{}
+		if err := rows.Scan(&submission.ID, &submission.FormID, &submission.Version, &submission.CreateAt); err != nil {
+			return nil, err
+		}
+		submissions = append(submissions, submission)
+	}
+	return submissions, rows.Err()
+}
+func (model sqlSubmissionModel) QueryEntries(query SubmissionQuery) ([]Entry, error) {
+	selected, args, err := model.selectSubmissions(query)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := model.db.Query(
+		"WITH selected AS ("+selected+`)
+			SELECT entries.entry_id, entries.submission_id, entries.label_id, entries.txt FROM entries
+			JOIN selected ON selected.submission_id = entries.submission_id
+			ORDER BY selected.created_at ASC, selected.submission_id ASC, entries.entry_id ASC`,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	entries := []Entry{}
+	for rows.Next() {
+		entry := Entry{}
+		if err := rows.Scan(&entry.ID, &entry.SubmissionID, &entry.LabelID, &entry.Txt); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+// selectSubmissions builds the select of the submissions query selects, in
+// the order they were made.
+func (model sqlSubmissionModel) selectSubmissions(query SubmissionQuery) (string, []interface{}, error) {
+	formModel := sqlFormModel{db: model.db}
+	if _, err := formModel.GetByID(query.FormID); err != nil {
+		if err == sql.ErrNoRows {
+			return "", nil, fmt.Errorf("form with form_id:%v does not exists", query.FormID)
+		}
+		return "", nil, err
+	}
+	where := "form_id = ?"
+	args := []interface{}{query.FormID}
+	if !query.Since.IsZero() {
+		where += " AND created_at >= ?"
+		args = append(args, query.Since.UTC().Format("2006-01-02 15:04:05"))
+	}
+	if !query.Until.IsZero() {
+		where += " AND created_at < ?"
+		args = append(args, query.Until.UTC().Format("2006-01-02 15:04:05"))
+	}
+	if query.Where != nil {
+		current, err := queryLabels(model.db, query.FormID)
+		if err != nil {
+			return "", nil, err
+		}
+		labels := map[string]Label{}
+		for _, label := range current {
+			labels[label.Name] = label
+		}
+		condition, err := resolveCondition(*query.Where, labels)
+		if err != nil {
+			return "", nil, err
+		}
+		clause, conditionArgs := conditionSQL(condition, labels)
+		where += " AND " + clause
+		args = append(args, conditionArgs...)
+	}
+	limit := ""
+	if query.Limit > 0 {
+		limit = " LIMIT ?"
+		args = append(args, query.Limit)
+	}
+	return "SELECT submission_id, form_id, form_version, created_at FROM submissions WHERE " + where +
+		" ORDER BY created_at ASC, submission_id ASC" + limit, args, nil
+}
+
+// conditionSQL turns a resolved condition into sql. A comparison holds when
+// any entry of its label in the submission does.
+func conditionSQL(c Condition, labels map[string]Label) (string, []interface{}) {
+	switch c.Op {
+	case OpAnd, OpOr, OpNot:
+		clauses := []string{}
+		args := []interface{}{}
+		for _, condition := range c.Conditions {
+			clause, conditionArgs := conditionSQL(condition, labels)
+			clauses = append(clauses, clause)
+			args = append(args, conditionArgs...)
+		}
+		if c.Op == OpNot {
+			return "NOT " + clauses[0], args
+		}
+		return "(" + strings.Join(clauses, " "+strings.ToUpper(string(c.Op))+" ") + ")", args
+	}
+	label := labels[c.Label]
+	txt := "entries.txt"
+	var value interface{} = c.Value
+	var compare string
+	switch {
+	case c.Op == OpContains && label.Repeatable:
+		compare = txt + " = ?"
+	case c.Op == OpContains:
+		compare = "instr(" + txt + ", ?) > 0"
+	case numericLabel(label):
+		value, _ = strconv.ParseFloat(c.Value, 64)
+		compare = "CAST(" + txt + " AS REAL) " + string(c.Op) + " ?"
+	default:
+		compare = txt + " " + string(c.Op) + " ?"
+	}
+	return `EXISTS (SELECT 1 FROM entries WHERE entries.submission_id = submissions.submission_id
+		AND entries.label_id = ? AND ` + compare + ")", []interface{}{label.ID, value}
+}
+
+func (model sqlSubmissionModel) GetByID(id int64) (Submission, error) {
+	return querySubmission(model.db, id)
+}
+func (model sqlSubmissionModel) Edit(id int64, values map[int64][]string) (Submission, error) {
+	tx, err := model.db.Begin()
+	if err != nil {
+		return Submission{}, err
+	}
+	defer tx.Rollback()
+	submission, labels, err := querySubmissionLabels(tx, id)
+	if err != nil {
+		return Submission{}, err
+	}
+	current := entryValues(submission.Entries)
+	edits, err := ValidateEdit(labels, current, values)
+	if err != nil {
+		return Submission{}, err
+	}
+	for _, label := range labels {
+		if to, ok := edits[label.ID]; ok {
+			if err := replaceValues(tx, id, label.ID, current[label.ID], to); err != nil {
+				return Submission{}, err
+			}
+		}
+	}
+	if submission, err = querySubmission(tx, id); err != nil {
+		return Submission{}, err
+	}
+	return submission, tx.Commit()
+}
+func (model sqlSubmissionModel) DeleteByID(id int64) (Submission, error) {
+	tx, err := model.db.Begin()
+	if err != nil {
+		return Submission{}, err
+	}
+	defer tx.Rollback()
+	submission, err := querySubmission(tx, id)
+	if err != nil {
+		return Submission{}, err
+	}
+	if _, err := tx.Exec("DELETE FROM submissions WHERE submission_id = ?", id); err != nil {
+		return Submission{}, err
+	}
+	return submission, tx.Commit()
+}
+func (model sqlSubmissionModel) GetChanges(id int64) ([]SubmissionChange, error) {
+	if _, err := querySubmission(model.db, id); err != nil {
+		return nil, err
+	}
+	changes := []SubmissionChange{}
+	rows, err := model.db.Query(
+		`SELECT change_id, submission_id, label_id, changed_at, previous, current
+			FROM submission_changes WHERE submission_id = ? ORDER BY change_id ASC`,
+		id,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		change := SubmissionChange{}
+		var from, to string
+		if err := rows.Scan(&change.ID, &change.SubmissionID, &change.LabelID, &change.ChangedAt, &from, &to); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(from), &change.From); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(to), &change.To); err != nil {
+			return nil, err
+		}
+		changes = append(changes, change)
+	}
+	return changes, rows.Err()
+}
+
+// querySubmission reads a submission with its entries, in the order of the
+// labels of the submission's version.
+func querySubmission(q querier, id int64) (Submission, error) {
+	submission := Submission{Entries: []Entry{}}
+	if err := q.QueryRow(
+		"SELECT submission_id, form_id, form_version, created_at FROM submissions WHERE submission_id = ?",
+		id,
+	).Scan(&submission.ID, &submission.FormID, &submission.Version, &submission.CreateAt); err != nil {
+		return Submission{}, err
+	}
+	rows, err := q.Query(
+		`SELECT entries.entry_id, entries.submission_id, entries.label_id, entries.txt FROM entries
+			LEFT JOIN label_versions ON label_versions.label_id = entries.label_id
+				AND label_versions.form_id = ? AND label_versions.version = ?
+			WHERE entries.submission_id = ?
+			ORDER BY label_versions.position IS NULL, label_versions.position ASC, entries.entry_id ASC`,
+		submission.FormID,
+		submission.Version,
+		id,
+	)
+	if err != nil {
+		return Submission{}, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		entry := Entry{}
+		if err := rows.Scan(&entry.ID, &entry.SubmissionID, &entry.LabelID, &entry.Txt); err != nil {
+			return Submission{}, err
+		}
+		submission.Entries = append(submission.Entries, entry)
+	}
+	return submission, rows.Err()
+}
+
+// querySubmissionLabels reads a submission along with the labels of the
+// version it was made with, which its edits are validated against.
+func querySubmissionLabels(q querier, id int64) (Submission, []Label, error) {
+	submission, err := querySubmission(q, id)
+	if err != nil {
+		return Submission{}, nil, err
+	}
+	labels, err := queryVersionLabels(q, submission.FormID, submission.Version)
+	if err != nil {
+		return Submission{}, nil, err
+	}
+	return submission, labels, nil
+}
+
+// replaceValues swaps the entries of a label in a submission for new ones
+// and records the change, unless the values stay the same.
+func replaceValues(tx *sql.Tx, submissionID, labelID int64, from, to []string) error {
+	if sameValues(from, to) {
+		return nil
+	}
+	if _, err := tx.Exec("DELETE FROM entries WHERE submission_id = ? AND label_id = ?", submissionID, labelID); err != nil {
+		return err
+	}
+	for _, txt := range to {
+		if _, err := tx.Exec(
+			"INSERT INTO entries (label_id, submission_id, txt) VALUES (?, ?, ?)", labelID, submissionID, txt,
+		); err != nil {
+			return err
+		}
+	}
+	return recordChange(tx, submissionID, labelID, from, to)
+}
+
+func recordChange(tx *sql.Tx, submissionID, labelID int64, from, to []string) error {
+	if from == nil {
+		from = []string{}
+	}
+	if to == nil {
+		to = []string{}
+	}
+	previous, err := json.Marshal(from)
+	if err != nil {
+		return err
+	}
+	current, err := json.Marshal(to)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(
+		"INSERT INTO submission_changes (submission_id, label_id, previous, current) VALUES (?, ?, ?, ?)",
+		submissionID,
+		labelID,
+		string(previous),
+		string(current),
+	)
+	return err
+}
+
+type sqlEntryModel struct {
+	db *sql.DB
+	// fts5 is set when the entries have a search index
+	fts5 bool
+}
+
+func (model sqlEntryModel) Create(submissionID, labelID int64, txt string) (Entry, error) {
+	labelModel := sqlLabelModel{db: model.db}
+	label, err := labelModel.GetByID(labelID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return Entry{}, fmt.Errorf("label with label_id:%v does not exists", labelID)
+		}
+		return Entry{}, err
+	}
+	txt, err = ValidateEntry(label, txt)
+	if err != nil {
+		return Entry{}, err
+	}
+	entry := Entry{LabelID: labelID, SubmissionID: submissionID, Txt: txt}
+	if err := model.db.QueryRow(
+		"INSERT INTO entries (label_id, submission_id, txt) VALUES (?, ?, ?) RETURNING entry_id",
+		labelID,
 		submissionID,
 		txt,
 	).Scan(&entry.ID); err != nil {
@@ -397,3 +1189,493 @@ func (model sqlEntryModel) GetEntries(submissionID, labelID int64) ([]Entry, err
 	err = rows.Err()
 	return entries, err
 }
+
+func (model sqlEntryModel) CountEntries(labelID int64) (int64, error) {
+	var count int64
+	err := model.db.QueryRow("SELECT COUNT(*) FROM entries WHERE label_id = ?", labelID).Scan(&count)
+	return count, err
+}
+
+func (model sqlEntryModel) Update(id int64, txt string) (Entry, error) {
+	if txt == "" {
+		return Entry{}, ErrEmptyEntry
+	}
+	tx, err := model.db.Begin()
+	if err != nil {
+		return Entry{}, err
+	}
+	defer tx.Rollback()
+	entry, labels, current, i, err := queryEntry(tx, id)
+	if err != nil {
+		return Entry{}, err
+	}
+	from := current[entry.LabelID]
+	to := append([]string{}, from...)
+	to[i] = txt
+	edits, err := ValidateEdit(labels, current, map[int64][]string{entry.LabelID: to})
+	if err != nil {
+		return Entry{}, err
+	}
+	// a value that is not empty stays in its place when made canonical
+	entry.Txt = edits[entry.LabelID][i]
+	if _, err := tx.Exec("UPDATE entries SET txt = ? WHERE entry_id = ?", entry.Txt, id); err != nil {
+		return Entry{}, err
+	}
+	if !sameValues(from, edits[entry.LabelID]) {
+		if err := recordChange(tx, entry.SubmissionID, entry.LabelID, from, edits[entry.LabelID]); err != nil {
+			return Entry{}, err
+		}
+	}
+	return entry, tx.Commit()
+}
+func (model sqlEntryModel) DeleteByID(id int64) (Entry, error) {
+	tx, err := model.db.Begin()
+	if err != nil {
+		return Entry{}, err
+	}
+	defer tx.Rollback()
+	entry, labels, current, i, err := queryEntry(tx, id)
+	if err != nil {
+		return Entry{}, err
+	}
+	from := current[entry.LabelID]
+	to := append(append([]string{}, from[:i]...), from[i+1:]...)
+	edits, err := ValidateEdit(labels, current, map[int64][]string{entry.LabelID: to})
+	if err != nil {
+		return Entry{}, err
+	}
+	if sameValues(to, edits[entry.LabelID]) {
+		if _, err := tx.Exec("DELETE FROM entries WHERE entry_id = ?", id); err != nil {
+			return Entry{}, err
+		}
+		if err := recordChange(tx, entry.SubmissionID, entry.LabelID, from, to); err != nil {
+			return Entry{}, err
+		}
+		return entry, tx.Commit()
+	}
+	// the label's default takes the place of its last value
+	if err := replaceValues(tx, entry.SubmissionID, entry.LabelID, from, edits[entry.LabelID]); err != nil {
+		return Entry{}, err
+	}
+	return entry, tx.Commit()
+}
+
+// searchFrom joins an entry to its submission, form and label, limited by
+// the form and label names of the search options.
+const searchFrom = `
+	entries JOIN submissions ON submissions.submission_id = entries.submission_id
+	JOIN forms ON forms.form_id = submissions.form_id
+	JOIN labels ON labels.label_id = entries.label_id
+	WHERE (? = '' OR forms.name = ?) AND (? = '' OR labels.name = ?)`
+
+// Search ranks the entries with their fts5 index when sqlite is built with
+// fts5, the go-sqlite3 sqlite_fts5 build tag. Builds without it fall back
+// to narrowing the entries down with LIKE and matching the words of the
+// ones left in go, which reads every entry holding the words. Either way
+// the search is a single query, which sqlite runs in a read transaction of
+// its own that does not hold up writers.
+func (model sqlEntryModel) Search(query string, options SearchOptions) ([]SearchResult, error) {
+	terms, err := parseSearch(query)
+	if err != nil {
+		return nil, err
+	}
+	options.defaultMarks()
+	filters := []interface{}{options.Form, options.Form, options.Label, options.Label}
+	if !model.fts5 {
+		return likeSearch(model.db, terms, filters, options)
+	}
+	rows, err := model.db.Query(`
+		SELECT entries.entry_id, entries.submission_id, entries.label_id, entries.txt, forms.form_id, forms.name,
+			labels.name, submissions.created_at, highlight(entry_search, 0, ?, ?)
+		FROM entry_search JOIN`+searchFrom+` AND entries.entry_id = entry_search.rowid AND entry_search MATCH ?
+		ORDER BY bm25(entry_search), submissions.created_at DESC, entries.entry_id`,
+		append(append([]interface{}{options.MarkStart, options.MarkEnd}, filters...), ftsQuery(terms))...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	results := []SearchResult{}
+	for rows.Next() {
+		result := SearchResult{}
+		if err := rows.Scan(
+			&result.ID, &result.SubmissionID, &result.LabelID, &result.Txt, &result.FormID, &result.Form,
+			&result.Label, &result.CreateAt, &result.Highlight,
+		); err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+	return results, rows.Err()
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// likeSearch narrows the entries down with LIKE and matches the words of
+// the ones left in go.
+func likeSearch(q querier, terms []searchTerm, filters []interface{}, options SearchOptions) ([]SearchResult, error) {
+	query := `
+		SELECT entries.entry_id, entries.submission_id, entries.label_id, entries.txt, forms.form_id, forms.name,
+			labels.name, submissions.created_at
+		FROM` + searchFrom
+	args := append([]interface{}{}, filters...)
+	for _, term := range terms {
+		query += ` AND entries.txt LIKE ? ESCAPE '\'`
+		args = append(args, "%"+likeEscaper.Replace(term.word)+"%")
+	}
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	results := []SearchResult{}
+	matches := map[int64][][2]int{}
+	for rows.Next() {
+		result := SearchResult{}
+		if err := rows.Scan(
+			&result.ID, &result.SubmissionID, &result.LabelID, &result.Txt, &result.FormID, &result.Form,
+			&result.Label, &result.CreateAt,
+		); err != nil {
+			return nil, err
+		}
+		found, ok := matchTerms(result.Txt, terms)
+		if !ok {
+			continue
+		}
+		matches[result.ID] = found
+		result.Highlight = highlight(result.Txt, found, options.MarkStart, options.MarkEnd)
+		results = append(results, result)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rankResults(results, matches)
+	return results, nil
+}
+
+// queryEntry reads an entry along with the labels of its submission's
+// version, the submission's values and the entry's index among the values
+// of its label.
+func queryEntry(tx *sql.Tx, id int64) (Entry, []Label, map[int64][]string, int, error) {
+	entry := Entry{}
+	if err := tx.QueryRow(
+		"SELECT entry_id, submission_id, label_id, txt FROM entries WHERE entry_id = ?", id,
+	).Scan(&entry.ID, &entry.SubmissionID, &entry.LabelID, &entry.Txt); err != nil {
+		return Entry{}, nil, nil, 0, err
+	}
+	submission, labels, err := querySubmissionLabels(tx, entry.SubmissionID)
+	if err != nil {
+		return Entry{}, nil, nil, 0, err
+	}
+	return entry, labels, entryValues(submission.Entries), entryIndex(submission.Entries, entry), nil
+}
+
+type sqlHookModel struct {
+	db *sql.DB
+}
+
+func (model sqlHookModel) Create(hook Hook) (Hook, error) {
+	formModel := sqlFormModel{db: model.db}
+	if _, err := formModel.GetByID(hook.FormID); err != nil {
+		return Hook{}, err
+	}
+	hook, err := ValidateHook(hook)
+	if err != nil {
+		return Hook{}, err
+	}
+	if err := model.db.QueryRow(
+		"INSERT INTO hooks (form_id, event, command, timeout_ms, on_failure) VALUES (?, ?, ?, ?, ?) RETURNING hook_id",
+		hook.FormID,
+		hook.Event,
+		hook.Command,
+		hook.Timeout.Milliseconds(),
+		hook.OnFailure,
+	).Scan(&hook.ID); err != nil {
+		return Hook{}, err
+	}
+	return hook, nil
+}
+
+const hookColumns = "hook_id, form_id, event, command, timeout_ms, on_failure"
+
+func scanHook(row scanner) (Hook, error) {
+	hook := Hook{}
+	var timeout int64
+	if err := row.Scan(&hook.ID, &hook.FormID, &hook.Event, &hook.Command, &timeout, &hook.OnFailure); err != nil {
+		return Hook{}, err
+	}
+	hook.Timeout = time.Duration(timeout) * time.Millisecond
+	return hook, nil
+}
+
+func (model sqlHookModel) GetHooks(formID int64) ([]Hook, error) {
+	rows, err := model.db.Query("SELECT "+hookColumns+" FROM hooks WHERE form_id = ? ORDER BY hook_id", formID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	hooks := []Hook{}
+	for rows.Next() {
+		hook, err := scanHook(rows)
+		if err != nil {
+			return nil, err
+		}
+		hooks = append(hooks, hook)
+	}
+	return hooks, rows.Err()
+}
+
+func (model sqlHookModel) DeleteByID(id int64) (Hook, error) {
+	hook, err := scanHook(model.db.QueryRow("DELETE FROM hooks WHERE hook_id = ? RETURNING "+hookColumns, id))
+	if err != nil {
+		return Hook{}, err
+	}
+	return hook, nil
+}
+
+type sqlWebhookModel struct {
+	db *sql.DB
+}
+
+func (model sqlWebhookModel) Create(webhook Webhook) (Webhook, error) {
+	formModel := sqlFormModel{db: model.db}
+	if _, err := formModel.GetByID(webhook.FormID); err != nil {
+		return Webhook{}, err
+	}
+	webhook, err := ValidateWebhook(webhook)
+	if err != nil {
+		return Webhook{}, err
+	}
+	if err := model.db.QueryRow(
+		"INSERT INTO webhooks (form_id, url, secret) VALUES (?, ?, ?) RETURNING webhook_id",
+		webhook.FormID,
+		webhook.URL,
+		webhook.Secret,
+	).Scan(&webhook.ID); err != nil {
+		return Webhook{}, err
+	}
+	return webhook, nil
+}
+
+const webhookColumns = "webhook_id, form_id, url, secret"
+
+func scanWebhook(row scanner) (Webhook, error) {
+	webhook := Webhook{}
+	if err := row.Scan(&webhook.ID, &webhook.FormID, &webhook.URL, &webhook.Secret); err != nil {
+		return Webhook{}, err
+	}
+	return webhook, nil
+}
+
+func (model sqlWebhookModel) GetByID(id int64) (Webhook, error) {
+	return scanWebhook(model.db.QueryRow("SELECT "+webhookColumns+" FROM webhooks WHERE webhook_id = ?", id))
+}
+
+func (model sqlWebhookModel) GetWebhooks(formID int64) ([]Webhook, error) {
+	rows, err := model.db.Query("SELECT "+webhookColumns+" FROM webhooks WHERE form_id = ? ORDER BY webhook_id", formID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	webhooks := []Webhook{}
+	for rows.Next() {
+		webhook, err := scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, webhook)
+	}
+	return webhooks, rows.Err()
+}
+
+func (model sqlWebhookModel) DeleteByID(id int64) (Webhook, error) {
+	webhook, err := scanWebhook(model.db.QueryRow("DELETE FROM webhooks WHERE webhook_id = ? RETURNING "+webhookColumns, id))
+	if err != nil {
+		return Webhook{}, err
+	}
+	return webhook, nil
+}
+
+// deliveries are read with SELECT rather than RETURNING, which hands back
+// their times as text
+const deliveryColumns = `delivery_id, webhook_id, submission_id, payload, status, attempts, status_code, error,
+	created_at, next_attempt_at, delivered_at`
+
+func scanDelivery(row scanner) (WebhookDelivery, error) {
+	delivery := WebhookDelivery{}
+	var deliveredAt sql.NullTime
+	if err := row.Scan(
+		&delivery.ID,
+		&delivery.WebhookID,
+		&delivery.SubmissionID,
+		&delivery.Payload,
+		&delivery.Status,
+		&delivery.Attempts,
+		&delivery.StatusCode,
+		&delivery.Error,
+		&delivery.CreatedAt,
+		&delivery.NextAttemptAt,
+		&deliveredAt,
+	); err != nil {
+		return WebhookDelivery{}, err
+	}
+	delivery.DeliveredAt = deliveredAt.Time
+	return delivery, nil
+}
+
+func (model sqlWebhookModel) getDelivery(id int64) (WebhookDelivery, error) {
+	return scanDelivery(model.db.QueryRow("SELECT "+deliveryColumns+" FROM webhook_deliveries WHERE delivery_id = ?", id))
+}
+
+func (model sqlWebhookModel) Enqueue(formID, submissionID int64, payload string, now time.Time) ([]WebhookDelivery, error) {
+	var ids []int64
+	err := inTx(model.db, func(tx *sql.Tx) (err error) {
+		ids, err = enqueue(tx, formID, submissionID, payload, now)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	deliveries := []WebhookDelivery{}
+	for _, id := range ids {
+		delivery, err := model.getDelivery(id)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries, nil
+}
+
+// enqueue queues a pending delivery of payload to every webhook of a form
+// and returns their ids.
+func enqueue(db execer, formID, submissionID int64, payload string, now time.Time) ([]int64, error) {
+	rows, err := db.Query("SELECT webhook_id FROM webhooks WHERE form_id = ? ORDER BY webhook_id", formID)
+	if err != nil {
+		return nil, err
+	}
+	webhookIDs := []int64{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		webhookIDs = append(webhookIDs, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	at := now.UTC().Format("2006-01-02 15:04:05")
+	ids := []int64{}
+	for _, webhookID := range webhookIDs {
+		var id int64
+		if err := db.QueryRow(
+			`INSERT INTO webhook_deliveries (webhook_id, submission_id, payload, status, created_at, next_attempt_at)
+				VALUES (?, ?, ?, ?, ?, ?) RETURNING delivery_id`,
+			webhookID,
+			submissionID,
+			payload,
+			DeliveryPending,
+			at,
+			at,
+		).Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+func (model sqlWebhookModel) ClaimDelivery(now, until time.Time) (WebhookDelivery, error) {
+	at := now.UTC().Format("2006-01-02 15:04:05")
+	for {
+		delivery, err := scanDelivery(model.db.QueryRow(
+			"SELECT "+deliveryColumns+` FROM webhook_deliveries WHERE status = ? AND next_attempt_at <= ?
+				ORDER BY next_attempt_at, delivery_id LIMIT 1`,
+			DeliveryPending,
+			at,
+		))
+		if err != nil {
+			return WebhookDelivery{}, err
+		}
+		// another process may claim the delivery between the select and the
+		// update, in which case the update changes nothing
+		result, err := model.db.Exec(
+			"UPDATE webhook_deliveries SET next_attempt_at = ? WHERE delivery_id = ? AND status = ? AND next_attempt_at <= ?",
+			until.UTC().Format("2006-01-02 15:04:05"),
+			delivery.ID,
+			DeliveryPending,
+			at,
+		)
+		if err != nil {
+			return WebhookDelivery{}, err
+		}
+		if n, err := result.RowsAffected(); err != nil {
+			return WebhookDelivery{}, err
+		} else if n == 1 {
+			return model.getDelivery(delivery.ID)
+		}
+	}
+}
+
+func (model sqlWebhookModel) UpdateDelivery(delivery WebhookDelivery) (WebhookDelivery, error) {
+	var deliveredAt interface{}
+	if !delivery.DeliveredAt.IsZero() {
+		deliveredAt = delivery.DeliveredAt.UTC().Format("2006-01-02 15:04:05")
+	}
+	result, err := model.db.Exec(
+		`UPDATE webhook_deliveries SET status = ?, attempts = ?, status_code = ?, error = ?, next_attempt_at = ?, delivered_at = ?
+			WHERE delivery_id = ?`,
+		delivery.Status,
+		delivery.Attempts,
+		delivery.StatusCode,
+		delivery.Error,
+		delivery.NextAttemptAt.UTC().Format("2006-01-02 15:04:05"),
+		deliveredAt,
+		delivery.ID,
+	)
+	if err != nil {
+		return WebhookDelivery{}, err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return WebhookDelivery{}, err
+	} else if n == 0 {
+		return WebhookDelivery{}, sql.ErrNoRows
+	}
+	return model.getDelivery(delivery.ID)
+}
+
+func (model sqlWebhookModel) GetDeliveries(formID int64) ([]WebhookDelivery, error) {
+	rows, err := model.db.Query(
+		"SELECT "+deliveryColumns+` FROM webhook_deliveries
+			WHERE webhook_id IN (SELECT webhook_id FROM webhooks WHERE form_id = ?)
+			ORDER BY created_at DESC, delivery_id DESC`,
+		formID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	deliveries := []WebhookDelivery{}
+	for rows.Next() {
+		delivery, err := scanDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries, rows.Err()
+}
+
+func joinChoices(choices []string) string {
+	return strings.Join(choices, "\n")
+}
+
+func splitChoices(choices string) []string {
+	if choices == "" {
+		return nil
+	}
+	return strings.Split(choices, "\n")
+}
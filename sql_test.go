@@ -0,0 +1,66 @@
+package formly_test
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/pablothedeveloper/formly"
+	"github.com/pablothedeveloper/formly/formlytest"
+)
+
+func TestSqLiteEnv(t *testing.T) {
+	formlytest.TestEnv(t, func(t *testing.T) *formly.Env {
+		env, err := formly.NewSqLiteEnv(filepath.Join(t.TempDir(), "formly.db"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return env
+	})
+}
+
+func TestSqLiteMigrations(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "formly.db")
+	applied, err := formly.MigrateSqLite(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != formly.LatestSchemaVersion() {
+		t.Errorf("migrating a new database applied %v migrations, want %v", len(applied), formly.LatestSchemaVersion())
+	}
+	if applied, err = formly.MigrateSqLite(path); err != nil || len(applied) != 0 {
+		t.Errorf("migrating an up to date database = %v, %v, want nothing applied", applied, err)
+	}
+	current, migrations, err := formly.SqLiteMigrations(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if current != formly.LatestSchemaVersion() || len(migrations) != current {
+		t.Errorf("SqLiteMigrations = %v, %v, want every migration applied", current, migrations)
+	}
+	for _, migration := range migrations {
+		if migration.AppliedAt.IsZero() {
+			t.Errorf("migration %v is not applied", migration.Version)
+		}
+	}
+
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the search index is kept by the build on open, so every build
+	// migrates to the same schema
+	var search int
+	if err := db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE name LIKE 'entry_search%'").Scan(&search); err != nil || search != 0 {
+		t.Errorf("search index after migrating = %v, %v, want none", search, err)
+	}
+	_, err = db.Exec("INSERT INTO schema_version (version) VALUES (?)", formly.LatestSchemaVersion()+1)
+	db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := formly.NewSqLiteEnv(path); !errors.Is(err, formly.ErrSchemaTooNew) {
+		t.Errorf("opening a database of a newer formly = %v, want %v", err, formly.ErrSchemaTooNew)
+	}
+}
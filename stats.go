@@ -0,0 +1,314 @@
+package formly
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// StatsPeriod is the length of the periods submissions are counted in.
+type StatsPeriod string
+
+// Periods of FormStats.PerPeriod, weeks start on monday. Periods are in utc.
+const (
+	DayPeriod   StatsPeriod = "day"
+	WeekPeriod  StatsPeriod = "week"
+	MonthPeriod StatsPeriod = "month"
+)
+
+// ErrUnknownStatsPeriod ...
+var ErrUnknownStatsPeriod error = errors.New("unknown period, expected day, week or month")
+
+// MaxStatsPeriods is the most periods the window of a query can span, a
+// longer window is refused with ErrStatsWindowTooLong.
+const MaxStatsPeriods = 10000
+
+// ErrStatsWindowTooLong ...
+var ErrStatsWindowTooLong error = errors.New("window spans too many periods")
+
+// FormStats summarizes the submissions of a form selected by a query.
+type FormStats struct {
+	Form        Form
+	Submissions int
+	// First and Last are when the first and last submission were made, zero
+	// when there are none.
+	First, Last time.Time
+	Period      StatsPeriod
+	// PerPeriod counts the submissions of every period of the query's
+	// window, including the empty ones, see NewSeries.
+	PerPeriod []PeriodCount
+	// Labels holds the stats of the form's current labels, in order.
+	Labels []LabelStats
+}
+
+// PeriodCount ...
+type PeriodCount struct {
+	Start       time.Time
+	Submissions int
+}
+
+// LabelStats summarizes the values of a label. Int and float labels get
+// the numbers filled in, the other labels get frequencies.
+type LabelStats struct {
+	Label Label
+	// Count is the number of values, Submissions the number of submissions
+	// holding at least one.
+	Count, Submissions int
+	// Min, Max, Mean and Median are nil for labels without numbers or
+	// without values.
+	Min, Max, Mean, Median *float64
+	// Frequencies counts each value, the most frequent first.
+	Frequencies []ValueCount
+}
+
+// ValueCount ...
+type ValueCount struct {
+	Value string
+	Count int
+}
+
+// NewFormStats computes the stats of the submissions selected by query.
+func NewFormStats(env *Env, query SubmissionQuery, period StatsPeriod) (FormStats, error) {
+	if err := checkPeriod(period); err != nil {
+		return FormStats{}, err
+	}
+	form, err := env.FormModel.GetByID(query.FormID)
+	if err != nil {
+		return FormStats{}, err
+	}
+	labels, err := env.LabelModel.GetLabels(query.FormID)
+	if err != nil {
+		return FormStats{}, err
+	}
+	submissions, err := env.SubmissionModel.Query(query)
+	if err != nil {
+		return FormStats{}, err
+	}
+	entries, err := env.SubmissionModel.QueryEntries(query)
+	if err != nil {
+		return FormStats{}, err
+	}
+	stats := FormStats{
+		Form:        form,
+		Submissions: len(submissions),
+		Period:      period,
+		PerPeriod:   []PeriodCount{},
+		Labels:      []LabelStats{},
+	}
+	perPeriod := map[time.Time]int{}
+	for _, submission := range submissions {
+		if stats.First.IsZero() || submission.CreateAt.Before(stats.First) {
+			stats.First = submission.CreateAt
+		}
+		if submission.CreateAt.After(stats.Last) {
+			stats.Last = submission.CreateAt
+		}
+		perPeriod[periodStart(submission.CreateAt, period)]++
+	}
+	first, last := queryWindow(query, submissions)
+	starts, err := periodRange(first, last, period)
+	if err != nil {
+		return FormStats{}, err
+	}
+	for _, start := range starts {
+		stats.PerPeriod = append(stats.PerPeriod, PeriodCount{Start: start, Submissions: perPeriod[start]})
+	}
+	values := map[int64][]string{}
+	holding := map[int64]int{}
+	seen := map[[2]int64]bool{}
+	for _, entry := range entries {
+		values[entry.LabelID] = append(values[entry.LabelID], entry.Txt)
+		if key := [2]int64{entry.SubmissionID, entry.LabelID}; !seen[key] {
+			seen[key] = true
+			holding[entry.LabelID]++
+		}
+	}
+	for _, label := range labels {
+		stats.Labels = append(stats.Labels, newLabelStats(label, values[label.ID], holding[label.ID]))
+	}
+	return stats, nil
+}
+
+func newLabelStats(label Label, values []string, submissions int) LabelStats {
+	stats := LabelStats{Label: label, Count: len(values), Submissions: submissions, Frequencies: []ValueCount{}}
+	if label.Type != IntLabel && label.Type != FloatLabel {
+		counts := map[string]int{}
+		for _, value := range values {
+			if counts[value] == 0 {
+				stats.Frequencies = append(stats.Frequencies, ValueCount{Value: value})
+			}
+			counts[value]++
+		}
+		for i := range stats.Frequencies {
+			stats.Frequencies[i].Count = counts[stats.Frequencies[i].Value]
+		}
+		sort.SliceStable(stats.Frequencies, func(i, j int) bool {
+			if stats.Frequencies[i].Count != stats.Frequencies[j].Count {
+				return stats.Frequencies[i].Count > stats.Frequencies[j].Count
+			}
+			return stats.Frequencies[i].Value < stats.Frequencies[j].Value
+		})
+		return stats
+	}
+	numbers := []float64{}
+	for _, value := range values {
+		if number, err := strconv.ParseFloat(value, 64); err == nil {
+			numbers = append(numbers, number)
+		}
+	}
+	if len(numbers) == 0 {
+		return stats
+	}
+	sort.Float64s(numbers)
+	sum := 0.0
+	for _, number := range numbers {
+		sum += number
+	}
+	min, max, mean := numbers[0], numbers[len(numbers)-1], sum/float64(len(numbers))
+	median := numbers[len(numbers)/2]
+	if len(numbers)%2 == 0 {
+		median = (numbers[len(numbers)/2-1] + median) / 2
+	}
+	stats.Min, stats.Max, stats.Mean, stats.Median = &min, &max, &mean, &median
+	return stats
+}
+
+// SeriesPoint holds the submissions made in a period and the values of a
+// label they hold.
+type SeriesPoint struct {
+	Start       time.Time
+	Submissions int
+	Values      []string
+}
+
+// NewSeries lays the submissions selected by query out over the periods
+// of the query's window, empty periods included. The window runs from Since
+// to Until, a side the query leaves open ends at the first or the last
+// submission. The values of the label labelID are collected, zero collects
+// none.
+func NewSeries(env *Env, query SubmissionQuery, labelID int64, period StatsPeriod) ([]SeriesPoint, error) {
+	if err := checkPeriod(period); err != nil {
+		return nil, err
+	}
+	submissions, err := env.SubmissionModel.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	entries := []Entry{}
+	if labelID != 0 {
+		if entries, err = env.SubmissionModel.QueryEntries(query); err != nil {
+			return nil, err
+		}
+	}
+	points := []SeriesPoint{}
+	point := map[time.Time]int{}
+	first, last := queryWindow(query, submissions)
+	starts, err := periodRange(first, last, period)
+	if err != nil {
+		return nil, err
+	}
+	for _, start := range starts {
+		point[start] = len(points)
+		points = append(points, SeriesPoint{Start: start, Values: []string{}})
+	}
+	made := map[int64]time.Time{}
+	for _, submission := range submissions {
+		made[submission.ID] = submission.CreateAt
+		points[point[periodStart(submission.CreateAt, period)]].Submissions++
+	}
+	for _, entry := range entries {
+		createAt, ok := made[entry.SubmissionID]
+		if ok && entry.LabelID == labelID {
+			i := point[periodStart(createAt, period)]
+			points[i].Values = append(points[i].Values, entry.Txt)
+		}
+	}
+	return points, nil
+}
+
+// queryWindow is the first and last moment of the window of query, see
+// NewSeries. Both are zero when the window has neither a start nor an end.
+func queryWindow(query SubmissionQuery, submissions []Submission) (time.Time, time.Time) {
+	first, last := query.Since, time.Time{}
+	if !query.Until.IsZero() {
+		// Until itself is not part of the window
+		last = query.Until.Add(-time.Nanosecond)
+	}
+	// submissions come in the order they were made
+	if first.IsZero() && len(submissions) != 0 {
+		first = submissions[0].CreateAt
+	}
+	if last.IsZero() && len(submissions) != 0 {
+		last = submissions[len(submissions)-1].CreateAt
+	}
+	switch {
+	case first.IsZero():
+		first = last
+	case last.IsZero():
+		last = first
+	}
+	return first, last
+}
+
+func checkPeriod(period StatsPeriod) error {
+	switch period {
+	case DayPeriod, WeekPeriod, MonthPeriod:
+		return nil
+	}
+	return ErrUnknownStatsPeriod
+}
+
+// periodRange lists the starts of the periods from first's to last's, none
+// when both are zero. Ranges of more than MaxStatsPeriods are refused
+// before any is listed.
+func periodRange(first, last time.Time, period StatsPeriod) ([]time.Time, error) {
+	starts := []time.Time{}
+	if first.IsZero() && last.IsZero() {
+		return starts, nil
+	}
+	start, end := periodStart(first, period), periodStart(last, period)
+	if n := periodsBetween(start, end, period) + 1; n > MaxStatsPeriods {
+		return nil, fmt.Errorf("%w: %v %s periods, at most %v", ErrStatsWindowTooLong, n, period, MaxStatsPeriods)
+	}
+	for ; !start.After(end); start = nextPeriod(start, period) {
+		starts = append(starts, start)
+	}
+	return starts, nil
+}
+
+// periodsBetween counts the periods from the one starting at start up to
+// the one starting at end.
+func periodsBetween(start, end time.Time, period StatsPeriod) int64 {
+	switch period {
+	case WeekPeriod:
+		return int64(end.Sub(start).Hours()) / (24 * 7)
+	case MonthPeriod:
+		return int64(end.Year()-start.Year())*12 + int64(end.Month()-start.Month())
+	}
+	return int64(end.Sub(start).Hours()) / 24
+}
+
+// periodStart is the start of the period t falls in.
+func periodStart(t time.Time, period StatsPeriod) time.Time {
+	t = t.UTC()
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	switch period {
+	case WeekPeriod:
+		return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+	case MonthPeriod:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	}
+	return day
+}
+
+func nextPeriod(start time.Time, period StatsPeriod) time.Time {
+	switch period {
+	case WeekPeriod:
+		return start.AddDate(0, 0, 7)
+	case MonthPeriod:
+		return start.AddDate(0, 1, 0)
+	}
+	return start.AddDate(0, 0, 1)
+}
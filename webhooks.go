@@ -0,0 +1,170 @@
+package formly
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// WebhookTimeout bounds how long a webhook gets to answer a delivery.
+const WebhookTimeout = 10 * time.Second
+
+// WebhookRetryDelay is how long a failed delivery waits for its first
+// retry, every retry after waits twice as long as the one before.
+const WebhookRetryDelay = 30 * time.Second
+
+// MaxWebhookAttempts is how many times a delivery is tried before it fails
+// for good.
+const MaxWebhookAttempts = 10
+
+// webhookLease is how long a claimed delivery is kept from others draining
+// the queue, well over WebhookTimeout.
+const webhookLease = time.Minute
+
+// maxWebhookMessage bounds how much of a response ends up in an error.
+const maxWebhookMessage = 1 << 10
+
+// SignatureHeader is the header carrying the signature of a delivery.
+const SignatureHeader = "X-Formly-Signature"
+
+// ErrInvalidWebhookURL ...
+var ErrInvalidWebhookURL error = errors.New("webhook url is not an absolute http or https url")
+
+// ValidateWebhook checks a webhook and returns it with a random secret when
+// it has none.
+func ValidateWebhook(webhook Webhook) (Webhook, error) {
+	u, err := url.Parse(webhook.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return Webhook{}, ErrInvalidWebhookURL
+	}
+	if webhook.Secret == "" {
+		b := make([]byte, 32)
+		if _, err := rand.Read(b); err != nil {
+			return Webhook{}, err
+		}
+		webhook.Secret = hex.EncodeToString(b)
+	}
+	return webhook, nil
+}
+
+// SignWebhook returns the signature of a delivery's body, the value of its
+// X-Formly-Signature header: sha256= followed by the hex encoded HMAC-SHA256
+// of the body keyed with the webhook's secret.
+func SignWebhook(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyWebhook reports whether signature is the signature of body.
+func VerifyWebhook(secret string, body []byte, signature string) bool {
+	return hmac.Equal([]byte(SignWebhook(secret, body)), []byte(signature))
+}
+
+// webhookRetryDelay is how long a delivery waits after its attempts failed.
+func webhookRetryDelay(attempts int64) time.Duration {
+	return WebhookRetryDelay << uint(attempts-1)
+}
+
+// DeliverWebhooks drains the queue of deliveries, posting every delivery
+// due at now to its webhook once and returning them as they stand after.
+// A delivery the webhook does not answer with a 2xx status is retried
+// WebhookRetryDelay later, then twice as late every time, up to
+// MaxWebhookAttempts attempts. client defaults to one that gives up after
+// WebhookTimeout.
+func (env *Env) DeliverWebhooks(client *http.Client, now time.Time) ([]WebhookDelivery, error) {
+	return env.DeliverWebhooksContext(context.Background(), client, now)
+}
+
+// DeliverWebhooksContext delivers like DeliverWebhooks until ctx is done,
+// when it returns the deliveries made so far along with ctx's error. A
+// delivery cut short is left claimed and due again once its claim runs out.
+func (env *Env) DeliverWebhooksContext(ctx context.Context, client *http.Client, now time.Time) ([]WebhookDelivery, error) {
+	if client == nil {
+		client = &http.Client{Timeout: WebhookTimeout}
+	}
+	// times are stored to the second
+	now = now.UTC().Truncate(time.Second)
+	deliveries := []WebhookDelivery{}
+	for {
+		if err := ctx.Err(); err != nil {
+			return deliveries, err
+		}
+		delivery, err := env.WebhookModel.ClaimDelivery(now, now.Add(webhookLease))
+		if err == sql.ErrNoRows {
+			return deliveries, nil
+		}
+		if err != nil {
+			return deliveries, err
+		}
+		webhook, err := env.WebhookModel.GetByID(delivery.WebhookID)
+		if err != nil {
+			return deliveries, err
+		}
+		delivery = deliver(ctx, client, webhook, delivery, now)
+		if err := ctx.Err(); err != nil {
+			return deliveries, err
+		}
+		if delivery, err = env.WebhookModel.UpdateDelivery(delivery); err != nil {
+			return deliveries, err
+		}
+		deliveries = append(deliveries, delivery)
+	}
+}
+
+// deliver makes an attempt at a delivery and records how it went.
+func deliver(ctx context.Context, client *http.Client, webhook Webhook, delivery WebhookDelivery, now time.Time) WebhookDelivery {
+	delivery.Attempts++
+	delivery.StatusCode, delivery.Error = 0, ""
+	err := post(ctx, client, webhook, &delivery)
+	if err == nil {
+		delivery.Status, delivery.DeliveredAt = DeliveryDelivered, now
+		return delivery
+	}
+	delivery.Error = err.Error()
+	if delivery.Attempts >= MaxWebhookAttempts {
+		delivery.Status = DeliveryFailed
+		return delivery
+	}
+	delivery.NextAttemptAt = now.Add(webhookRetryDelay(delivery.Attempts))
+	return delivery
+}
+
+func post(ctx context.Context, client *http.Client, webhook Webhook, delivery *WebhookDelivery) error {
+	body := []byte(delivery.Payload)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "formly")
+	req.Header.Set("X-Formly-Delivery", strconv.FormatInt(delivery.ID, 10))
+	req.Header.Set(SignatureHeader, SignWebhook(webhook.Secret, body))
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	delivery.StatusCode = resp.StatusCode
+	txt, _ := ioutil.ReadAll(io.LimitReader(resp.Body, maxWebhookMessage))
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	if message := strings.TrimSpace(string(txt)); message != "" {
+		return fmt.Errorf("webhook answered %s: %s", resp.Status, message)
+	}
+	return fmt.Errorf("webhook answered %s", resp.Status)
+}